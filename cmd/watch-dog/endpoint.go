@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+
+	"watch-dog/internal/config"
+	"watch-dog/internal/docker"
+	"watch-dog/internal/journal"
+	"watch-dog/internal/status"
+	"watch-dog/supervisor"
+)
+
+// newSupervisors creates a supervisor per endpoint configured in c: one per entry of its endpoints
+// list, or the single host from DOCKER_HOST. Each has its own runtime client, recovery flow,
+// cooldowns, discovery graph, event subscription and polling loop, so a host that is unreachable
+// or has a broken compose file does not affect the others. Clients do not connect yet, so only
+// invalid settings fail here. j is the recovery journal (nil when disabled).
+func newSupervisors(ctx context.Context, c *config.Config, j *journal.Journal) ([]*supervisor.Supervisor, error) {
+	if len(c.Endpoints) == 0 {
+		rt, err := supervisor.NewRuntime(ctx, c.Runtime, docker.Endpoint{})
+		if err != nil {
+			return nil, err
+		}
+		return []*supervisor.Supervisor{newSupervisor(c, "", "", rt, tracker, j)}, nil
+	}
+	sups := make([]*supervisor.Supervisor, 0, len(c.Endpoints))
+	for _, e := range c.Endpoints {
+		rt, err := supervisor.NewRuntime(ctx, e.Runtime, docker.Endpoint{Name: e.Name, Host: e.Host, CertPath: e.CertPath})
+		if err != nil {
+			closeRuntimes(sups)
+			return nil, err
+		}
+		sups = append(sups, newSupervisor(c, e.Name, e.ComposePath, rt, tracker.Endpoint(e.Name, e.ComposePath), j))
+	}
+	return sups, nil
+}
+
+// newSupervisor returns the supervisor of the endpoint name. composePath is the endpoint's compose
+// file; "" means compose_path of the current configuration (single endpoint, follows reloads).
+func newSupervisor(c *config.Config, name, composePath string, rt supervisor.Runtime, st *status.Tracker, j *journal.Journal) *supervisor.Supervisor {
+	opts := supervisor.Options{
+		Name:    name,
+		Runtime: rt,
+		Source: supervisor.ComposeFile{Path: func() string {
+			if composePath != "" {
+				return composePath
+			}
+			return currentConfig().ComposePath
+		}},
+		Policy:   supervisorPolicy(c),
+		Status:   st,
+		SelfName: c.ContainerName,
+	}
+	if j != nil {
+		opts.Journal = j
+	}
+	if elector != nil {
+		opts.Leader = elector
+	}
+	return supervisor.New(opts)
+}
+
+// closeRuntimes closes the supervisors' runtime clients.
+func closeRuntimes(sups []*supervisor.Supervisor) {
+	for _, s := range sups {
+		s.Runtime().Close()
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"watch-dog/internal/discovery"
+)
+
+// runGraph implements the "graph" subcommand: it prints every dependency edge of the compose
+// file with its kind, condition, recovery semantics and where it is declared. Returns the exit code.
+func runGraph(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: watch-dog graph [flags]")
+		fmt.Fprintln(stderr, "Show the dependency edges watch-dog discovers in the compose file.")
+		fs.PrintDefaults()
+	}
+	c, ok := subcommandConfig("graph", stderr)
+	if !ok {
+		return 2
+	}
+	path := fs.String("compose", c.ComposePath, "compose file `path`")
+	format := fs.String("format", "table", "output `format`: table, json or dot")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *path == "" {
+		fmt.Fprintln(stderr, "graph: no compose file; set -compose, compose_path in the config file, WATCHDOG_COMPOSE_PATH or COMPOSE_FILE")
+		return 2
+	}
+	if *format != "table" && *format != "json" && *format != "dot" {
+		fmt.Fprintf(stderr, "graph: invalid -format %q (want table, json or dot)\n", *format)
+		return 2
+	}
+	f, err := discovery.ParseComposeFile(*path)
+	if err != nil {
+		fmt.Fprintf(stderr, "graph: read compose file: %v\n", err)
+		return 1
+	}
+	edges := f.ServiceEdges()
+	switch *format {
+	case "json":
+		return writeJSON(stdout, stderr, edges)
+	case "dot":
+		writeGraphDot(stdout, edges)
+	default:
+		writeGraphTable(stdout, edges)
+	}
+	return 0
+}
+
+func writeGraphTable(w io.Writer, edges []discovery.ServiceEdge) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEPENDENT\tPARENT\tKIND\tCONDITION\tRECOVERY\tSOURCE")
+	for _, e := range edges {
+		parent := e.Parent
+		if e.ParentIsContainer {
+			parent = "container:" + parent
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Dependent, parent, e.Kind, dash(e.Condition), e.Kind.Recovery(), e.Source)
+	}
+	tw.Flush()
+}
+
+// writeGraphDot renders edges as a Graphviz digraph (parent -> dependent), labeled with the edge kind.
+func writeGraphDot(w io.Writer, edges []discovery.ServiceEdge) {
+	fmt.Fprintln(w, "digraph watchdog {")
+	for _, e := range edges {
+		label := string(e.Kind)
+		if e.Condition != "" && e.Condition != discovery.ConditionStarted {
+			label += ` (` + e.Condition + `)`
+		}
+		parent := e.Parent
+		if e.ParentIsContainer {
+			parent = "container:" + parent
+		}
+		fmt.Fprintf(w, "  %q -> %q [label=%q];\n", parent, e.Dependent, label)
+	}
+	fmt.Fprintln(w, "}")
+}
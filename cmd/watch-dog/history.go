@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"watch-dog/internal/journal"
+)
+
+// runHistory implements the "history" subcommand: it reads the recovery journal, filters
+// attempts and prints them (or per-container stats) as a table or JSON. Returns the exit code.
+func runHistory(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: watch-dog history [flags]")
+		fmt.Fprintln(stderr, "Query past recoveries from the recovery journal (journal_path).")
+		fs.PrintDefaults()
+	}
+	c, ok := subcommandConfig("history", stderr)
+	if !ok {
+		return 2
+	}
+	path := fs.String("journal", c.JournalPath, "journal file `path`")
+	var filter journal.Filter
+	fs.StringVar(&filter.Container, "container", "", "only attempts where this container was restarted (as parent or dependent)")
+	fs.StringVar(&filter.Host, "host", "", "only attempts on this Docker endpoint (see endpoints in the config file)")
+	fs.StringVar(&filter.Project, "project", "", "only attempts for parents in this compose project")
+	fs.StringVar(&filter.Trigger, "trigger", "", "only attempts with this trigger (event, startup, takeover, polling, resume)")
+	fs.StringVar(&filter.Outcome, "outcome", "", "only attempts with this outcome (success, partial, failed, aborted, unfinished)")
+	since := fs.String("since", "", "only attempts started after this `time` (RFC 3339, YYYY-MM-DD, or a lookback like 24h or 7d)")
+	until := fs.String("until", "", "only attempts started before this `time` (same formats as -since)")
+	stats := fs.Bool("stats", false, "print per-container stats instead of attempts")
+	format := fs.String("format", "table", "output `format`: table or json")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *path == "" {
+		fmt.Fprintln(stderr, "history: no journal; set -journal, journal_path in the config file or WATCHDOG_JOURNAL_PATH")
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(stderr, "history: invalid -format %q (want table or json)\n", *format)
+		return 2
+	}
+	now := time.Now()
+	var err error
+	if filter.Since, err = parseHistoryTime(*since, now); err != nil {
+		fmt.Fprintf(stderr, "history: invalid -since: %v\n", err)
+		return 2
+	}
+	if filter.Until, err = parseHistoryTime(*until, now); err != nil {
+		fmt.Fprintf(stderr, "history: invalid -until: %v\n", err)
+		return 2
+	}
+
+	entries, err := journal.ReadAll(*path)
+	if err != nil {
+		fmt.Fprintf(stderr, "history: read journal: %v\n", err)
+		return 1
+	}
+	attempts := filter.Select(journal.Attempts(entries))
+
+	if *stats {
+		s := journal.Stats(attempts)
+		if filter.Container != "" {
+			s = onlyContainer(s, filter.Container)
+		}
+		if *format == "json" {
+			return writeJSON(stdout, stderr, s)
+		}
+		writeStatsTable(stdout, s)
+		return 0
+	}
+	if *format == "json" {
+		return writeJSON(stdout, stderr, attempts)
+	}
+	writeAttemptsTable(stdout, attempts)
+	return 0
+}
+
+// parseHistoryTime parses an absolute time (RFC 3339 or YYYY-MM-DD, local time) or a lookback
+// duration relative to now (Go duration such as 36h, or whole days such as 7d). Empty returns zero.
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a time, date or lookback duration", s)
+	}
+	return now.Add(-d), nil
+}
+
+func onlyContainer(stats []journal.ContainerStats, name string) []journal.ContainerStats {
+	for _, s := range stats {
+		if s.Container == name {
+			return []journal.ContainerStats{s}
+		}
+	}
+	return nil
+}
+
+func writeJSON(stdout, stderr io.Writer, v any) int {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(stderr, "history: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func writeAttemptsTable(w io.Writer, attempts []journal.Attempt) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	// The HOST column only appears for journals written with several endpoints.
+	hosts := slices.ContainsFunc(attempts, func(a journal.Attempt) bool { return a.Host != "" })
+	if hosts {
+		fmt.Fprint(tw, "HOST\t")
+	}
+	fmt.Fprintln(tw, "START\tPARENT\tPROJECT\tTRIGGER\tREASON\tOUTCOME\tTIME TO HEALTHY\tDEPENDENTS\tDETAILS")
+	for _, a := range attempts {
+		outcome := a.Outcome
+		if outcome == "" {
+			outcome = "unfinished"
+		}
+		if hosts {
+			fmt.Fprintf(tw, "%s\t", dash(a.Host))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			a.Start.Local().Format(time.DateTime), a.Parent, dash(a.Project), dash(a.Trigger), dash(a.Reason),
+			outcome, formatDuration(a.TimeToHealthy), formatDependents(a.Dependents), dash(a.Details))
+	}
+	tw.Flush()
+}
+
+func writeStatsTable(w io.Writer, stats []journal.ContainerStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTAINER\tRESTARTS\tFAILURES\tFAILURE RATE\tMEAN TIME TO HEALTHY\tMOST COMMON REASON")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f%%\t%s\t%s\n",
+			s.Container, s.Restarts, s.Failures, s.FailureRate*100, formatDuration(s.MeanTimeToHealthy), dash(s.MostCommonReason))
+	}
+	tw.Flush()
+}
+
+// formatDependents renders dependent outcomes as "name" for success and "name(outcome)" otherwise.
+func formatDependents(deps []journal.DependentRestart) string {
+	if len(deps) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(deps))
+	for _, d := range deps {
+		if d.Outcome == journal.OutcomeSuccess {
+			parts = append(parts, d.Name)
+		} else {
+			parts = append(parts, d.Name+"("+d.Outcome+")")
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
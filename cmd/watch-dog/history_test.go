@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"watch-dog/internal/journal"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{s: "", want: time.Time{}},
+		{s: "7d", want: now.AddDate(0, 0, -7)},
+		{s: "0d", want: now},
+		{s: "36h", want: now.Add(-36 * time.Hour)},
+		{s: "90m", want: now.Add(-90 * time.Minute)},
+		{s: "2026-10-01", want: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+		{s: "2026-10-01T08:30:00Z", want: time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC)},
+		{s: "2026-10-01T08:30:00+02:00", want: time.Date(2026, 10, 1, 6, 30, 0, 0, time.UTC)},
+		{s: "yesterday", wantErr: true},
+		{s: "-1d", wantErr: true},
+		{s: "-5h", wantErr: true},
+		{s: "7w", wantErr: true},
+		{s: "2026-13-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.s, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHistoryTime(%q) error = %v, want error %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+// writeHistoryJournal writes a journal with a successful event attempt for vpn ten days ago and
+// a failed polling attempt for db an hour ago, and returns its path.
+func writeHistoryJournal(t *testing.T) string {
+	t.Helper()
+	now := time.Now()
+	entries := []journal.Entry{
+		{Time: now.AddDate(0, 0, -10), Kind: journal.KindAttempt, AttemptID: "a1", Parent: "vpn", Trigger: "event", Reason: "unhealthy"},
+		{Time: now.AddDate(0, 0, -10).Add(time.Minute), Kind: journal.KindOutcome, AttemptID: "a1", Parent: "vpn", Outcome: journal.OutcomeSuccess},
+		{Time: now.Add(-time.Hour), Kind: journal.KindAttempt, AttemptID: "a2", Parent: "db", Trigger: "polling", Reason: "stopped"},
+		{Time: now.Add(-time.Hour).Add(time.Minute), Kind: journal.KindOutcome, AttemptID: "a2", Parent: "db", Outcome: journal.OutcomeFailed},
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunHistory(t *testing.T) {
+	t.Setenv("WATCHDOG_CONFIG", "")
+	t.Setenv("WATCHDOG_JOURNAL_PATH", "")
+	path := writeHistoryJournal(t)
+	fiveDaysAgo := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	twoHoursAgo := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    []string
+		notOut     []string
+		wantStderr string
+	}{
+		{name: "all", args: []string{"-journal", path}, wantOut: []string{"PARENT", "vpn", "db", "success", "failed"}},
+		{name: "since days", args: []string{"-journal", path, "-since", "7d"}, wantOut: []string{"db"}, notOut: []string{"vpn"}},
+		{name: "until date", args: []string{"-journal", path, "-until", fiveDaysAgo}, wantOut: []string{"vpn"}, notOut: []string{"db"}},
+		{name: "since RFC 3339", args: []string{"-journal", path, "-since", twoHoursAgo}, wantOut: []string{"db"}, notOut: []string{"vpn"}},
+		{name: "filters", args: []string{"-journal", path, "-trigger", "event", "-outcome", "success"}, wantOut: []string{"vpn"}, notOut: []string{"db"}},
+		{name: "json", args: []string{"-journal", path, "-format", "json", "-container", "db"}, wantOut: []string{`"parent": "db"`, `"outcome": "failed"`}, notOut: []string{"vpn"}},
+		{name: "stats", args: []string{"-journal", path, "-stats", "-container", "vpn"}, wantOut: []string{"vpn"}, notOut: []string{"db"}},
+		{name: "help", args: []string{"-h"}, wantStderr: "usage: watch-dog history"},
+		{name: "no journal", args: nil, wantCode: 2, wantStderr: "no journal"},
+		{name: "unknown flag", args: []string{"-journal", path, "-bogus"}, wantCode: 2, wantStderr: "flag provided but not defined"},
+		{name: "invalid format", args: []string{"-journal", path, "-format", "xml"}, wantCode: 2, wantStderr: `invalid -format "xml"`},
+		{name: "invalid since", args: []string{"-journal", path, "-since", "last week"}, wantCode: 2, wantStderr: "invalid -since"},
+		{name: "invalid until", args: []string{"-journal", path, "-until", "-3d"}, wantCode: 2, wantStderr: "invalid -until"},
+		{name: "unreadable journal", args: []string{"-journal", t.TempDir()}, wantCode: 1, wantStderr: "read journal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			if code := runHistory(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (stderr %q)", code, tt.wantCode, stderr.String())
+			}
+			for _, s := range tt.wantOut {
+				if !strings.Contains(stdout.String(), s) {
+					t.Errorf("stdout does not contain %q:\n%s", s, stdout.String())
+				}
+			}
+			for _, s := range tt.notOut {
+				if strings.Contains(stdout.String(), s) {
+					t.Errorf("stdout contains %q:\n%s", s, stdout.String())
+				}
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"watch-dog/internal/config"
+	"watch-dog/internal/docker"
+	"watch-dog/internal/leader"
+	"watch-dog/internal/status"
+	"watch-dog/supervisor"
+)
+
+// elector is this replica's leader election (nil when WATCHDOG_LEADER_LOCK is unset: always
+// leader). Set in main before any recovery can run.
+var elector *leader.Elector
+
+// newElector returns the election configured by c, or nil when it is disabled.
+func newElector(c *config.Config) *leader.Elector {
+	if c.LeaderLock == "" {
+		return nil
+	}
+	id := c.LeaderID
+	if id == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "watch-dog"
+		}
+		id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return leader.New(c.LeaderLock, id, c.LeaderLease)
+}
+
+// onLeaderChange returns the election callback: it updates the status role and, when this
+// replica takes over, has every endpoint resume the sequences interrupted before it started and
+// run a reconciliation pass, so parents that failed while it was standby are recovered without
+// waiting for the next poll (see supervisor.Supervisor.TakeOver).
+func onLeaderChange(ctx context.Context, endpoints []*supervisor.Supervisor) func(bool, string) {
+	wasLeader := false
+	return func(isLeader bool, holder string) {
+		role := status.RoleStandby
+		if isLeader {
+			role = status.RoleLeader
+		}
+		tracker.SetRole(role, holder)
+		switch {
+		case isLeader && !wasLeader:
+			docker.LogInfo("became leader, recovering containers", "id", elector.ID())
+			for _, s := range endpoints {
+				go s.TakeOver(ctx)
+			}
+		case !isLeader && wasLeader:
+			docker.LogWarn("no longer leader, standing by", "id", elector.ID(), "leader", holder)
+		case !isLeader:
+			docker.LogInfo("standing by", "id", elector.ID(), "leader", holder)
+		}
+		wasLeader = isLeader
+	}
+}
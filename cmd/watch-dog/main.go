@@ -7,344 +7,221 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"watch-dog/internal/discovery"
+	"watch-dog/internal/config"
 	"watch-dog/internal/docker"
+	"watch-dog/internal/journal"
 	"watch-dog/internal/recovery"
+	"watch-dog/internal/status"
 )
 
-var recoveryCooldown time.Duration
-var initialDiscoveryWait time.Duration
-var dependentRestartCooldown time.Duration
+// loadedConfig is the effective configuration, loaded in main and replaced on reload (see
+// internal/config and reload.go).
+var loadedConfig atomic.Pointer[config.Config]
 
-// initialDiscoveryPhaseEnd is set after first discovery; recovery is gated until time.Now() > initialDiscoveryPhaseEnd.
-var initialDiscoveryPhaseEnd time.Time
+// currentConfig returns the effective configuration.
+func currentConfig() *config.Config {
+	return loadedConfig.Load()
+}
 
-func init() {
-	const defaultCooldown = 2 * time.Minute
-	s := os.Getenv("RECOVERY_COOLDOWN")
-	if s == "" {
-		recoveryCooldown = defaultCooldown
-	} else {
-		d, err := time.ParseDuration(s)
-		if err != nil || d <= 0 {
-			reason := "must be positive"
-			if err != nil {
-				reason = err.Error()
-			}
-			docker.LogWarn("invalid RECOVERY_COOLDOWN, using default 2m", "value", s, "error", reason)
-			d = defaultCooldown
-		}
-		recoveryCooldown = d
-	}
+// tracker holds watch-dog's own state for the status API and healthcheck (set in main). With
+// several endpoints, each has its own tracker (see status.Tracker.Endpoint).
+var tracker *status.Tracker
 
-	const defaultInitialDiscoveryWait = 60 * time.Second
-	ws := os.Getenv("WATCHDOG_INITIAL_DISCOVERY_WAIT")
-	if ws == "" {
-		initialDiscoveryWait = defaultInitialDiscoveryWait
-	} else {
-		d, err := time.ParseDuration(ws)
-		if err != nil || d <= 0 {
-			reason := "must be positive"
-			if err != nil {
-				reason = err.Error()
-			}
-			docker.LogWarn("invalid WATCHDOG_INITIAL_DISCOVERY_WAIT, using default 60s", "value", ws, "error", reason)
-			d = defaultInitialDiscoveryWait
-		}
-		initialDiscoveryWait = d
+// subcommandConfig loads the configuration from the config file and environment for a
+// subcommand, which takes its defaults from it. Errors are written to stderr.
+func subcommandConfig(name string, stderr io.Writer) (*config.Config, bool) {
+	c, err := config.Load(nil, os.Getenv, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: invalid configuration:\n%v\n", name, err)
+		return nil, false
 	}
-
-	const defaultDependentRestartCooldown = 90 * time.Second
-	ds := os.Getenv("WATCHDOG_DEPENDENT_RESTART_COOLDOWN")
-	if ds == "" {
-		dependentRestartCooldown = defaultDependentRestartCooldown
-	} else {
-		// Zero is permitted: 0 disables the cooldown period, so dependents may restart on every eligible recovery.
-		d, err := time.ParseDuration(ds)
-		if err != nil || d < 0 {
-			reason := "must be non-negative"
-			if err != nil {
-				reason = err.Error()
-			}
-			docker.LogWarn("invalid WATCHDOG_DEPENDENT_RESTART_COOLDOWN, using default 90s", "value", ds, "error", reason)
-			d = defaultDependentRestartCooldown
-		}
-		dependentRestartCooldown = d
+	for _, w := range c.Warnings {
+		fmt.Fprintf(stderr, "%s: invalid environment variable ignored: %s\n", name, w)
 	}
+	return c, true
 }
 
-// isInitialDiscoveryComplete returns true after the initial discovery phase (first discovery + wait) has elapsed.
-// Until then, recovery and runStartupReconciliation must not run; see contracts/initial-discovery-behavior.md.
-func isInitialDiscoveryComplete() bool {
-	if initialDiscoveryPhaseEnd.IsZero() {
-		return false
-	}
-	return !time.Now().Before(initialDiscoveryPhaseEnd)
+// journalOptions returns the journal rotation and compaction settings of c.
+func journalOptions(c *config.Config) journal.Options {
+	return journal.Options{MaxSize: int64(c.JournalMaxSize), MaxFiles: c.JournalMaxFiles, Retention: c.JournalRetention}
 }
 
-// recoveryCooldownState tracks last recovery time and in-flight recovery per parent
-// to avoid re-running recovery on duplicate events (stop + die) or overlapping runs.
-type recoveryCooldownState struct {
-	mu       sync.Mutex
-	last     map[string]time.Time
-	inFlight map[string]bool
+// failureLogCapture returns the failure log capture settings of c, or nil when no directory is set.
+func failureLogCapture(c *config.Config) *recovery.LogCapture {
+	if c.FailureLogDir == "" {
+		return nil
+	}
+	return &recovery.LogCapture{
+		Dir:      c.FailureLogDir,
+		Tail:     c.FailureLogLines,
+		Since:    c.FailureLogSince,
+		MaxFiles: c.FailureLogMaxFiles,
+		MaxAge:   c.FailureLogMaxAge,
+	}
 }
 
-// StartRecovery checks cooldown and in-flight for parentName. If allowed, marks the parent
-// in-flight and updates last recovery time, then returns true. Caller must call EndRecovery
-// when recovery finishes (e.g. defer after StartRecovery returns true).
-func (s *recoveryCooldownState) StartRecovery(parentName string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.last == nil {
-		s.last = make(map[string]time.Time)
+// main initializes logging from env and runs a supervisor (see package supervisor) per endpoint:
+// it builds parent-to-dependents discovery from the endpoint's compose file and runs an initial
+// discovery phase (no recovery until phase end). After the phase, each supervisor runs startup
+// reconciliation once and subscribes to health-status events and polling, executing recovery when
+// a parent becomes unhealthy. See contracts/initial-discovery-behavior.md. "watch-dog history" queries the recovery journal and
+// "watch-dog graph" prints the discovered dependency edges instead.
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			os.Exit(runHistory(os.Args[2:], os.Stdout, os.Stderr))
+		case "graph":
+			os.Exit(runGraph(os.Args[2:], os.Stdout, os.Stderr))
+		case "healthcheck":
+			os.Exit(runHealthcheck(os.Stderr))
+		}
 	}
-	if s.inFlight == nil {
-		s.inFlight = make(map[string]bool)
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "watch-dog: invalid configuration:\n%v\n", err)
+		os.Exit(2)
 	}
-	if s.inFlight[parentName] {
-		return false
+	if cfg.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
-	if t, ok := s.last[parentName]; ok && time.Since(t) < recoveryCooldown {
-		return false
+	loadedConfig.Store(cfg)
+	if cfg.File != "" {
+		docker.LogInfo("configuration loaded", "file", cfg.File)
 	}
-	s.inFlight[parentName] = true
-	s.last[parentName] = time.Now()
-	return true
-}
-
-// EndRecovery clears the in-flight mark for parentName. Call when recovery for that parent finishes.
-func (s *recoveryCooldownState) EndRecovery(parentName string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.inFlight != nil {
-		delete(s.inFlight, parentName)
+	for _, w := range cfg.Warnings {
+		docker.LogWarn("invalid environment variable ignored", "setting", w)
 	}
-}
+	tracker = status.NewTracker(rootComposePath(cfg))
 
-// main initializes logging from env, creates the Docker client, builds parent-to-dependents
-// discovery from the compose file, and runs an initial discovery phase (no recovery until
-// phase end). After the phase, it runs startup reconciliation once and subscribes to
-// health-status events and polling, executing recovery when a parent becomes unhealthy.
-// See contracts/initial-discovery-behavior.md.
-func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	cli, err := docker.NewClient(ctx)
-	if err != nil {
-		docker.LogError("create docker client", "error", err)
-		os.Exit(1)
+	var j *journal.Journal
+	var entries []journal.Entry
+	if cfg.JournalPath != "" {
+		j, err = journal.Open(cfg.JournalPath, journalOptions(cfg))
+		if err != nil {
+			docker.LogError("open recovery journal", "path", cfg.JournalPath, "error", err)
+			os.Exit(1)
+		}
+		defer j.Close()
+		entries, err = j.Entries()
+		if err != nil {
+			docker.LogWarn("read recovery journal, cooldowns not restored", "path", cfg.JournalPath, "error", err)
+		}
+		docker.LogInfo("recovery journal opened", "path", cfg.JournalPath, "entries", len(entries))
 	}
-	defer cli.Close()
-
-	parentToDeps, err := discovery.BuildParentToDependents(ctx, cli)
-	if err != nil {
-		docker.LogError("build discovery", "error", err)
-		os.Exit(1)
+	if cfg.ContainerName == "" {
+		docker.LogWarn("WATCHDOG_CONTAINER_NAME not set: self-last-restart behavior disabled")
 	}
 
-	// Initial discovery phase: no recovery until first discovery + wait has elapsed (specs/004-child-deps-initial-restart).
-	initialDiscoveryPhaseEnd = time.Now().Add(initialDiscoveryWait)
-	docker.LogInfo("initial discovery started", "wait", initialDiscoveryWait.String())
+	// With leader election, only the replica holding the lease recovers containers. The lease is
+	// released after running recoveries are drained at shutdown (see below).
+	elector = newElector(cfg)
 
-	flow := &recovery.Flow{
-		Client:                   cli,
-		DependentRestartCooldown: dependentRestartCooldown,
+	endpoints, err := newSupervisors(ctx, cfg, j)
+	if err != nil {
+		docker.LogError("create container runtime client", "error", err)
+		os.Exit(1)
 	}
-	cooldown := &recoveryCooldownState{}
-	selfName := os.Getenv("WATCHDOG_CONTAINER_NAME")
-	if selfName == "" {
-		docker.LogWarn("WATCHDOG_CONTAINER_NAME not set: self-last-restart behavior disabled")
+	defer closeRuntimes(endpoints)
+	// Each endpoint restores only its own host's journal entries, so cooldowns and pending
+	// dependents of a container name on one host do not apply to the same name on another.
+	if j != nil {
+		for _, s := range endpoints {
+			s.Restore(journal.Restore(journal.ForHost(entries, s.Name())))
+		}
 	}
 
-	parentNames := parentToDeps.ParentNames()
-	if len(parentNames) == 0 {
-		docker.LogWarn("no parents discovered; set WATCHDOG_COMPOSE_PATH and mount the compose file", "path", discovery.ComposePathFromEnv())
-	} else {
-		docker.LogInfo("watch-dog started", "parents", parentNames)
-	}
+	applyConfig(cfg, endpoints)
+	reload := &reloader{args: os.Args[1:], endpoints: endpoints}
+	go reload.watchSIGHUP(ctx)
 
-	// Run startup reconciliation exactly once when initial discovery phase ends (not at startup).
-	// Post-phase: no cascade—reconciliation runs once; cooldown/in-flight prevent duplicate runs (contracts/initial-discovery-behavior.md).
-	go func() {
-		d := initialDiscoveryPhaseEnd.Sub(time.Now())
-		if d <= 0 {
-			d = 0
+	if cfg.StatusEnabled() {
+		// Anyone who can reach the status API could reload: without a token, only on loopback.
+		reloadFunc := status.ReloadFunc(reload.reload)
+		if cfg.ControlToken == "" && !status.Loopback(cfg.StatusAddr) {
+			docker.LogWarn("POST /reload disabled: status API is not on loopback and WATCHDOG_CONTROL_TOKEN is unset", "addr", cfg.StatusAddr)
+			reloadFunc = nil
 		}
-		select {
-		case <-ctx.Done():
-			docker.LogInfo("shutdown during initial discovery wait, skipping startup reconciliation")
-			return
-		case <-time.After(d):
-			// wait completed; proceed
-		}
-		docker.LogInfo("initial discovery complete, recovery enabled")
-		var built discovery.ParentToDependents
-		var lastErr error
-		backoff := 2 * time.Second
-		for attempt := 0; attempt < 5; attempt++ {
-			if ctx.Err() != nil {
-				return
-			}
-			var buildErr error
-			built, buildErr = discovery.BuildParentToDependents(ctx, cli)
-			if buildErr == nil {
-				runStartupReconciliation(ctx, cli, &built, flow, cooldown, selfName)
-				return
+		go func() {
+			if err := status.Serve(ctx, cfg.StatusAddr, status.Handler(tracker, reloadFunc, cfg.ControlToken)); err != nil {
+				docker.LogError("status server", "addr", cfg.StatusAddr, "error", err)
 			}
-			lastErr = buildErr
-			docker.LogError("startup reconciliation: build discovery", "error", buildErr, "attempt", attempt+1)
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(backoff):
-				backoff *= 2
-				if backoff > 30*time.Second {
-					backoff = 30 * time.Second
-				}
-			}
-		}
-		docker.LogError("startup reconciliation: gave up after retries, skipping runStartupReconciliation", "error", lastErr)
-	}()
-
-	healthCh := make(chan docker.HealthEvent, 8)
-	cli.SubscribeHealthStatus(ctx, healthCh)
-
-	go runPollingFallback(ctx, cli, flow, cooldown, selfName)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case ev, ok := <-healthCh:
-			if !ok {
-				return
-			}
-			if !isInitialDiscoveryComplete() {
-				continue
-			}
-			parentToDeps, err = discovery.BuildParentToDependents(ctx, cli)
-			if err != nil {
-				docker.LogError("refresh discovery", "error", err)
-				continue
-			}
-			if !parentToDeps.IsParent(ev.ContainerName) {
-				continue
-			}
-			tryRecoverParent(ctx, ev.ContainerID, ev.ContainerName, ev.Status, shortID(ev.ContainerID), "event", flow, cooldown, &parentToDeps, selfName)
-		}
-	}
-}
-
-// shortID returns the first 12 characters of a container ID.
-func shortID(id string) string {
-	if len(id) > 12 {
-		return id[:12]
+		}()
+	}
+
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
+	if elector != nil {
+		docker.LogInfo("leader election enabled", "lock", cfg.LeaderLock, "id", elector.ID(), "lease", cfg.LeaderLease.String())
+		changed := onLeaderChange(ctx, endpoints)
+		elector.Check(changed)
+		go func() {
+			elector.Run(electionCtx, changed)
+			close(electionDone)
+		}()
+	} else {
+		close(electionDone)
 	}
-	return id
-}
 
-// tryRecoverParent runs recovery for a parent if cooldown allows: StartRecovery, then defer EndRecovery, then RunFullSequence.
-// reason describes why recovery was triggered (e.g. "stop", "unhealthy"). idShort is the short container ID for logging.
-// trigger is "event", "startup", or "polling". INFO recovery log is emitted only when recovery actually runs (after cooldown check).
-func tryRecoverParent(ctx context.Context, parentID, parentName, reason, idShort, trigger string, flow *recovery.Flow, cooldown *recoveryCooldownState, parentToDeps *discovery.ParentToDependents, selfName string) {
-	if !cooldown.StartRecovery(parentName) {
-		docker.LogDebug("skipping recovery, in cooldown or in flight", "parent", parentName, "id", parentID)
-		return
-	}
-	defer cooldown.EndRecovery(parentName)
-	docker.LogInfoRecovery(fmt.Sprintf("recovery: attempting recovery for parent %q (reason: %s, trigger: %s)", parentName, reason, trigger), "parent", parentName, "reason", reason, "id_short", idShort, "trigger", trigger)
-	flow.RunFullSequence(ctx, parentID, parentName, reason, parentToDeps, selfName)
-}
+	// On shutdown, drain running recoveries before the journal and clients are closed.
+	drained := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		drainRecoveries(endpoints)
+		stopElection()
+		<-electionDone
+		close(drained)
+	}()
+	defer func() {
+		stop()
+		<-drained
+	}()
 
-// buildContainerMaps builds name→ID and name→state maps from the given containers.
-func buildContainerMaps(containers []docker.ContainerInfo) (nameToID, nameToState map[string]string) {
-	nameToID = make(map[string]string)
-	nameToState = make(map[string]string)
-	for _, c := range containers {
-		nameToID[c.Name] = c.ID
-		nameToState[c.Name] = c.State
+	// Endpoints run independently until shutdown; one failing does not stop the others.
+	var wg sync.WaitGroup
+	for _, s := range endpoints {
+		wg.Go(func() { s.Run(ctx) })
 	}
-	return nameToID, nameToState
+	wg.Wait()
 }
 
-// runStartupReconciliation finds parents that are already unhealthy or stopped and runs full recovery.
-func runStartupReconciliation(ctx context.Context, cli *docker.Client, m *discovery.ParentToDependents, flow *recovery.Flow, cooldown *recoveryCooldownState, selfName string) {
-	containers, err := cli.ListContainers(ctx, true)
-	if err != nil {
-		docker.LogError("startup list containers", "error", err)
-		return
+// runHealthcheck implements the "healthcheck" subcommand for the container HEALTHCHECK: it exits
+// non-zero when the status API reports watch-dog as degraded or does not answer. With the status
+// API disabled it always succeeds.
+func runHealthcheck(stderr io.Writer) int {
+	c, ok := subcommandConfig("healthcheck", stderr)
+	if !ok {
+		return 2
 	}
-	nameToID, nameToState := buildContainerMaps(containers)
-	for parentName := range *m {
-		id, ok := nameToID[parentName]
-		if !ok {
-			continue
-		}
-		state := nameToState[parentName]
-		if state != "running" {
-			tryRecoverParent(ctx, id, parentName, state, shortID(id), "startup", flow, cooldown, m, selfName)
-			continue
-		}
-		health, _, err := cli.Inspect(ctx, id)
-		if err != nil || health != "unhealthy" {
-			continue
-		}
-		tryRecoverParent(ctx, id, parentName, "unhealthy", shortID(id), "startup", flow, cooldown, m, selfName)
+	if !c.StatusEnabled() {
+		return 0
 	}
-}
-
-const pollInterval = 60 * time.Second
-
-// runPollingFallback periodically rechecks parent health and triggers recovery if unhealthy.
-// Recovery runs only after initial discovery phase is complete; see isInitialDiscoveryComplete().
-func runPollingFallback(ctx context.Context, cli *docker.Client, flow *recovery.Flow, cooldown *recoveryCooldownState, selfName string) {
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if !isInitialDiscoveryComplete() {
-				continue
-			}
-			parentToDeps, err := discovery.BuildParentToDependents(ctx, cli)
-			if err != nil {
-				continue
-			}
-			containers, err := cli.ListContainers(ctx, true)
-			if err != nil {
-				continue
-			}
-			nameToID, nameToState := buildContainerMaps(containers)
-			for parentName := range parentToDeps {
-				id, ok := nameToID[parentName]
-				if !ok {
-					continue
-				}
-				state := nameToState[parentName]
-				if state != "running" {
-					tryRecoverParent(ctx, id, parentName, state, shortID(id), "polling", flow, cooldown, &parentToDeps, selfName)
-					continue
-				}
-				health, _, err := cli.Inspect(ctx, id)
-				if err != nil {
-					docker.LogDebug("polling: inspect failed", "parent", parentName, "error", err)
-					continue
-				}
-				if health == "unhealthy" {
-					tryRecoverParent(ctx, id, parentName, "unhealthy", shortID(id), "polling", flow, cooldown, &parentToDeps, selfName)
-				}
-			}
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := status.Check(ctx, c.StatusAddr); err != nil {
+		fmt.Fprintf(stderr, "healthcheck: %v\n", err)
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"watch-dog/internal/dockertest"
+)
+
+// runMainEnv makes the test binary run main instead of the tests, so TestMain_* can run the
+// whole watch-dog binary as a subprocess.
+const runMainEnv = "WATCHDOG_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		os.Args = os.Args[:1]
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const testCompose = `
+services:
+  vpn:
+    image: vpn
+  torrent:
+    image: torrent
+    depends_on:
+      vpn:
+        condition: service_healthy
+  web:
+    image: web
+`
+
+// output collects the watch-dog subprocess output and reports when a line contains a string.
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// changed is closed and replaced on every write.
+	changed chan struct{}
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Write(p)
+	close(o.changed)
+	o.changed = make(chan struct{})
+	return len(p), nil
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// wait waits until the output contains s.
+func (o *output) wait(ctx context.Context, s string) error {
+	for {
+		o.mu.Lock()
+		found, changed := strings.Contains(o.buf.String(), s), o.changed
+		o.mu.Unlock()
+		if found {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// startWatchdog runs the watch-dog binary against d with testCompose until the test ends, and
+// returns once recovery is enabled.
+func startWatchdog(t *testing.T, d *dockertest.Daemon) *output {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(testCompose), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := &output{changed: make(chan struct{})}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(),
+		runMainEnv+"=1",
+		"DOCKER_HOST="+d.Host(),
+		"WATCHDOG_COMPOSE_PATH="+path,
+		"WATCHDOG_INITIAL_DISCOVERY_WAIT=100ms",
+		"WATCHDOG_STATUS_ADDR=off",
+		"WATCHDOG_CONFIG=",
+		"LOG_FORMAT=compact",
+	)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	t.Cleanup(func() {
+		cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case err := <-exited:
+			if err != nil {
+				t.Errorf("watch-dog exited with %v:\n%s", err, out)
+			}
+		case <-time.After(10 * time.Second):
+			cmd.Process.Kill()
+			t.Errorf("watch-dog did not exit on SIGTERM:\n%s", out)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.WaitSubscribers(ctx, 1); err != nil {
+		t.Fatalf("watch-dog did not subscribe to events: %v\n%s", err, out)
+	}
+	if err := out.wait(ctx, "recovery enabled"); err != nil {
+		t.Fatalf("initial discovery did not complete: %v\n%s", err, out)
+	}
+	return out
+}
+
+// composeService returns a running compose container of service on the fake daemon.
+func composeService(name, health string) dockertest.Container {
+	return dockertest.Container{Name: name, Health: health, Labels: map[string]string{"com.docker.compose.service": name}}
+}
+
+func TestMain_unhealthyParentRestartsDependentAfterHealthy(t *testing.T) {
+	d := dockertest.StartUnix(t, composeService("vpn", "healthy"), composeService("torrent", ""), composeService("web", ""))
+	out := startWatchdog(t, d)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d.SetHealth("vpn", "unhealthy")
+	if err := d.WaitCall(ctx, "restart vpn"); err != nil {
+		t.Fatalf("vpn not restarted: %v (calls %v)\n%s", err, d.Calls(), out)
+	}
+	// torrent waits for vpn to become healthy again, watching vpn's events.
+	if err := d.WaitSubscribers(ctx, 2); err != nil {
+		t.Fatalf("not waiting for vpn: %v\n%s", err, out)
+	}
+	if slices.Contains(d.Calls(), "restart torrent") {
+		t.Fatalf("torrent restarted before vpn was healthy: %v", d.Calls())
+	}
+	d.SetHealth("vpn", "healthy")
+	if err := d.WaitCall(ctx, "restart torrent"); err != nil {
+		t.Fatalf("torrent not restarted: %v (calls %v)\n%s", err, d.Calls(), out)
+	}
+	if got := d.Calls(); !slices.Equal(got, []string{"restart vpn", "restart torrent"}) {
+		t.Errorf("calls = %v, want vpn then torrent only", got)
+	}
+}
+
+func TestMain_reconcilesUnhealthyParentAtStartup(t *testing.T) {
+	d := dockertest.StartUnix(t, composeService("vpn", "unhealthy"), composeService("torrent", ""))
+	d.OnStart = func(name string) { d.SetHealth(name, "healthy") }
+	out := startWatchdog(t, d)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := d.WaitCall(ctx, "restart torrent"); err != nil {
+		t.Fatalf("torrent not restarted: %v (calls %v)\n%s", err, d.Calls(), out)
+	}
+	if got := d.Calls(); !slices.Equal(got, []string{"restart vpn", "restart torrent"}) {
+		t.Errorf("calls = %v, want vpn then torrent only", got)
+	}
+}
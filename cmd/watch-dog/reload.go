@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"watch-dog/internal/config"
+	"watch-dog/internal/docker"
+	"watch-dog/internal/recovery"
+	"watch-dog/internal/status"
+	"watch-dog/supervisor"
+)
+
+// reloader re-reads the configuration and the compose file (on SIGHUP or POST /reload) and swaps
+// in the new recovery policy, log level and dependency graphs. Cooldowns, recoveries in progress and
+// the event subscriptions are left alone.
+type reloader struct {
+	// args are the command-line flags, which keep overriding the config file on reload.
+	args      []string
+	endpoints []*supervisor.Supervisor
+
+	mu sync.Mutex // serializes reloads
+}
+
+// reload loads the configuration again and applies it. An invalid configuration is rejected as a
+// whole and the current one is kept.
+func (r *reloader) reload(ctx context.Context) (status.ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := currentConfig()
+	next, err := config.Load(r.args, os.Getenv, io.Discard)
+	if err != nil {
+		docker.LogError("reload: invalid configuration, keeping the current one", "error", err)
+		tracker.Reloaded(rootComposePath(old), err)
+		return status.ReloadResult{}, err
+	}
+	res := status.ReloadResult{Changed: old.Changed(next), RestartRequired: config.StartupOnly(old.Changed(next))}
+	applyConfig(next, r.endpoints)
+	loadedConfig.Store(next)
+	tracker.Reloaded(rootComposePath(next), nil)
+	docker.LogInfo("configuration reloaded", "changed", res.Changed)
+	if len(res.RestartRequired) > 0 {
+		docker.LogWarn("reload: some changed settings only take effect after a restart", "settings", res.RestartRequired)
+	}
+	// Pick up compose file changes now rather than at the next event or poll.
+	for _, s := range r.endpoints {
+		_, _ = s.Discover(ctx)
+	}
+	return res, nil
+}
+
+// watchSIGHUP reloads on every SIGHUP until ctx is done.
+func (r *reloader) watchSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			docker.LogInfo("SIGHUP received, reloading configuration")
+			_, _ = r.reload(ctx)
+		}
+	}
+}
+
+// applyConfig applies the settings of c that can change at runtime: logging, and the stop timeout
+// and policy of every supervisor.
+func applyConfig(c *config.Config, endpoints []*supervisor.Supervisor) {
+	docker.SetupLogging(c.LogLevel, c.LogFormat)
+	for _, s := range endpoints {
+		s.Runtime().SetStopTimeout(c.RestartTimeout)
+		s.SetPolicy(supervisorPolicy(c))
+	}
+}
+
+// supervisorPolicy returns the supervisor policy configured by c.
+func supervisorPolicy(c *config.Config) supervisor.Policy {
+	return supervisor.Policy{
+		InitialDiscoveryWait: c.InitialDiscoveryWait,
+		RecoveryCooldown:     c.RecoveryCooldown,
+		PollInterval:         c.PollInterval,
+		PollIntervalFast:     c.PollIntervalFast,
+		PollIntervalMax:      c.PollIntervalMax,
+		PollConcurrency:      c.PollConcurrency,
+		Recovery: recovery.Policy{
+			DependentRestartCooldown: c.DependentRestartCooldown,
+			WaitHealthyTimeout:       c.WaitHealthyTimeout,
+			Capture:                  failureLogCapture(c),
+			VerifyDependents:         c.VerifyDependents,
+			DependentVerifyTimeout:   c.DependentVerifyTimeout,
+			DependentConcurrency:     c.DependentConcurrency,
+			DependentRetries:         c.DependentRetries,
+			Strategy:                 c.RecoveryStrategy,
+		},
+	}
+}
+
+// rootComposePath returns the compose path reported by the root tracker: compose_path, or ""
+// with several endpoints, which each report their own.
+func rootComposePath(c *config.Config) string {
+	if len(c.Endpoints) > 0 {
+		return ""
+	}
+	return c.ComposePath
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"watch-dog/internal/docker"
+	"watch-dog/supervisor"
+)
+
+// abortGrace is how long shutdown waits for sequences to journal their pending dependents after
+// their context was canceled.
+const abortGrace = 5 * time.Second
+
+// drainRecoveries is the shutdown phase: no new recovery starts, running ones stop after their
+// current step (journaling the dependents they did not get to), and after WATCHDOG_SHUTDOWN_TIMEOUT
+// the steps still running are canceled. All endpoints drain at the same time.
+func drainRecoveries(endpoints []*supervisor.Supervisor) {
+	timeout := currentConfig().ShutdownTimeout
+	docker.LogInfo("shutting down, letting running recoveries finish their current step", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := drainAll(ctx, endpoints)
+	for _, s := range endpoints {
+		s.Abort()
+	}
+	if err == nil {
+		return
+	}
+	docker.LogWarn("shutdown timeout elapsed, aborting running recoveries")
+	ctx, cancel = context.WithTimeout(context.Background(), abortGrace)
+	defer cancel()
+	if err := drainAll(ctx, endpoints); err != nil {
+		docker.LogWarn("recoveries still running at exit, their journal entries may be incomplete")
+	}
+}
+
+// drainAll drains the supervisors of all endpoints concurrently until ctx is done.
+func drainAll(ctx context.Context, endpoints []*supervisor.Supervisor) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, s := range endpoints {
+		wg.Go(func() {
+			if err := s.Drain(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,192 @@
+// Package clocktest provides a fake clock.Clock for tests. Its time only moves when the test calls
+// Advance, and timers and tickers fire as virtual time passes them, so tests of cooldowns,
+// timeouts and polling neither sleep nor depend on scheduling.
+package clocktest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"watch-dog/internal/clock"
+)
+
+// Fake is a clock.Clock whose time only moves when Advance is called. It is safe for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+	// changed is closed and replaced whenever a timer is added or removed (see WaitTimers).
+	changed chan struct{}
+}
+
+var _ clock.Clock = (*Fake)(nil)
+
+// New returns a fake clock set to now.
+func New(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t.
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Until returns the fake time until t.
+func (f *Fake) Until(t time.Time) time.Duration {
+	return t.Sub(f.Now())
+}
+
+// After returns a channel that receives the fake time once d has passed.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer returns a timer that fires once d has passed.
+func (f *Fake) NewTimer(d time.Duration) clock.Timer {
+	t := &timer{f: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker returns a ticker that fires every d. Like time.NewTicker, it panics if d <= 0.
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for NewTicker")
+	}
+	t := &timer{f: f, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return ticker{t}
+}
+
+// Advance moves the fake time forward by d, firing the timers and tickers it passes in order of
+// their time. Like real ones, a ticker whose previous tick was not received drops the tick.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for len(f.timers) > 0 {
+		t := slices.MinFunc(f.timers, func(a, b *timer) int { return a.when.Compare(b.when) })
+		if t.when.After(end) {
+			break
+		}
+		f.now = t.when
+		t.fire()
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			f.remove(t)
+		}
+	}
+	f.now = end
+}
+
+// Timers returns the number of timers and tickers that have not fired or been stopped.
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// WaitTimers waits until at least n timers or tickers are pending, e.g. until the code under test
+// is waiting for a timeout, so a following Advance fires it. It returns ctx's error if ctx ends first.
+func (f *Fake) WaitTimers(ctx context.Context, n int) error {
+	for {
+		f.mu.Lock()
+		ok, changed := len(f.timers) >= n, f.changed
+		f.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up WaitTimers. Caller holds f.mu.
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// remove removes t from the pending timers and reports whether it was pending. Caller holds f.mu.
+func (f *Fake) remove(t *timer) bool {
+	i := slices.Index(f.timers, t)
+	if i < 0 {
+		return false
+	}
+	f.timers = slices.Delete(f.timers, i, i+1)
+	f.notify()
+	return true
+}
+
+// timer is a fake timer, or a ticker when period is set.
+type timer struct {
+	f      *Fake
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+// fire sends the fake time without blocking. Caller holds f.mu.
+func (t *timer) fire() {
+	select {
+	case t.c <- t.f.now:
+	default:
+	}
+}
+
+// Stop stops the timer and reports whether it was pending.
+func (t *timer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	return t.f.remove(t)
+}
+
+// Reset makes the timer fire once d has passed from now (a ticker every d), and reports whether
+// it was pending. A timer with d <= 0 fires right away.
+func (t *timer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	pending := t.f.remove(t)
+	if t.period > 0 {
+		t.period = d
+	}
+	t.when = t.f.now.Add(d)
+	if d <= 0 && t.period == 0 {
+		t.fire()
+		return pending
+	}
+	t.f.timers = append(t.f.timers, t)
+	t.f.notify()
+	return pending
+}
+
+// ticker adapts timer to clock.Ticker.
+type ticker struct{ *timer }
+
+func (t ticker) Stop() {
+	t.timer.Stop()
+}
+
+func (t ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for Ticker.Reset")
+	}
+	t.timer.Reset(d)
+}
@@ -0,0 +1,69 @@
+package clocktest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+
+func TestFake_timersFireInOrderDuringAdvance(t *testing.T) {
+	f := New(epoch)
+	late := f.NewTimer(2 * time.Second)
+	early := f.After(time.Second)
+	tick := f.NewTicker(750 * time.Millisecond)
+
+	f.Advance(999 * time.Millisecond)
+	select {
+	case <-early:
+		t.Fatal("timer fired before its time")
+	default:
+	}
+	if got := <-tick.C(); !got.Equal(epoch.Add(750 * time.Millisecond)) {
+		t.Errorf("tick at %v, want +750ms", got)
+	}
+
+	f.Advance(1001 * time.Millisecond)
+	if got := <-early; !got.Equal(epoch.Add(time.Second)) {
+		t.Errorf("early fired at %v, want +1s", got)
+	}
+	if got := <-late.C(); !got.Equal(epoch.Add(2 * time.Second)) {
+		t.Errorf("late fired at %v, want +2s", got)
+	}
+	if got := <-tick.C(); !got.Equal(epoch.Add(1500 * time.Millisecond)) {
+		t.Errorf("tick at %v, want +1.5s", got)
+	}
+	if f.Since(epoch) != 2*time.Second {
+		t.Errorf("Since = %v", f.Since(epoch))
+	}
+	if f.Timers() != 1 {
+		t.Errorf("Timers = %d, want only the ticker", f.Timers())
+	}
+	// A tick that is not received is dropped.
+	f.Advance(time.Second)
+	f.Advance(time.Second)
+	if got := <-tick.C(); !got.Equal(epoch.Add(2250 * time.Millisecond)) {
+		t.Errorf("tick at %v, want +2.25s", got)
+	}
+	tick.Stop()
+	if late.Stop() || f.Timers() != 0 {
+		t.Error("fired timer still pending")
+	}
+}
+
+func TestFake_waitTimers(t *testing.T) {
+	f := New(epoch)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Minute)
+		close(done)
+	}()
+	if err := f.WaitTimers(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	f.Advance(time.Minute)
+	<-done
+}
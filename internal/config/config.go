@@ -0,0 +1,583 @@
+// Package config loads watch-dog's settings. Each setting has a default and can be set in a YAML
+// file (-config or WATCHDOG_CONFIG), by environment variable and by command-line flag; later
+// sources win: defaults < file < environment < flags. All invalid values are reported together.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the effective configuration.
+type Config struct {
+	// File is the configuration file that was loaded ("" if none).
+	File string
+	// PrintConfig is set by --print-config: print the effective configuration and exit.
+	PrintConfig bool
+
+	ComposePath   string
+	ContainerName string
+	// Runtime is the container runtime backend: "docker" or "podman" (its Docker-compatible API).
+	Runtime   string
+	LogLevel  string
+	LogFormat string
+
+	RecoveryCooldown         time.Duration
+	InitialDiscoveryWait     time.Duration
+	DependentRestartCooldown time.Duration
+	RestartTimeout           time.Duration
+	ShutdownTimeout          time.Duration
+	WaitHealthyTimeout       time.Duration
+	RecoveryStrategy         string
+	DependentConcurrency     int
+	VerifyDependents         bool
+	DependentRetries         int
+	DependentVerifyTimeout   time.Duration
+
+	PollInterval     time.Duration
+	PollIntervalFast time.Duration
+	PollIntervalMax  time.Duration
+	PollConcurrency  int
+
+	// StatusAddr is the status API listen address; "off" disables it (see StatusEnabled).
+	StatusAddr string
+	// ControlToken is the bearer token POST /reload requires ("" serves it on loopback only).
+	ControlToken string
+
+	JournalPath      string
+	JournalMaxSize   int
+	JournalMaxFiles  int
+	JournalRetention time.Duration
+
+	// LeaderLock is the lease file for leader election among replicas ("" disables election).
+	LeaderLock  string
+	LeaderLease time.Duration
+	LeaderID    string
+
+	FailureLogDir      string
+	FailureLogLines    int
+	FailureLogSince    time.Duration
+	FailureLogMaxFiles int
+	FailureLogMaxAge   time.Duration
+
+	// Endpoints are the Docker hosts to supervise, from the endpoints list of the config file.
+	// Empty means the single host from DOCKER_HOST, with ComposePath.
+	Endpoints []Endpoint
+
+	// Warnings report invalid environment variables, which fall back to the config file's value
+	// or the default instead of failing Load, as they always have. Log them once logging is set up.
+	Warnings []string
+
+	// sources maps each setting key to where its value came from (see Write).
+	sources map[string]string
+}
+
+// Endpoint is one Docker host in the endpoints list of the config file, each with its own client,
+// compose file, discovery and recovery.
+type Endpoint struct {
+	// Name labels the endpoint in logs, metrics, /status and the journal.
+	Name string
+	// Host is the Docker daemon address: unix:///path (e.g. a socket forwarded over SSH) or
+	// tcp://host:port.
+	Host string
+	// CertPath is a directory with ca.pem, cert.pem and key.pem for TLS ("" for none).
+	CertPath string
+	// ComposePath is the endpoint's compose file, as mounted into watch-dog.
+	ComposePath string
+	// Runtime is the endpoint's container runtime; Load sets it to Config.Runtime when omitted.
+	Runtime string
+}
+
+// runtimes are the supported container runtime backends.
+var runtimes = []string{"docker", "podman"}
+
+// StatusEnabled reports whether the status API should be served.
+func (c *Config) StatusEnabled() bool {
+	return c.StatusAddr != "off"
+}
+
+// setting describes one configuration value. The file key is key, the flag is key with '_'
+// replaced by '-', and env lists the environment variables that set it (the first one set wins).
+type setting struct {
+	key   string
+	env   []string
+	def   string
+	usage string
+	value value
+	// fromEnv, if set, maps the raw value of env var name to the setting's value.
+	fromEnv func(name, raw string) string
+	// startup marks settings that are only read when watch-dog starts, not on reload.
+	startup bool
+	// secret marks settings whose value Write does not print.
+	secret bool
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// settings returns the table of settings bound to c's fields.
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "compose_path", env: []string{"WATCHDOG_COMPOSE_PATH", "COMPOSE_FILE"}, usage: "compose file used for discovery (COMPOSE_FILE: its first path)",
+			value: stringValue{p: &c.ComposePath}, fromEnv: firstComposePath},
+		{key: "container_name", env: []string{"WATCHDOG_CONTAINER_NAME"}, usage: "watch-dog's own container name, restarted last when it is a dependent",
+			value: stringValue{p: &c.ContainerName}, startup: true},
+		{key: "runtime", env: []string{"WATCHDOG_RUNTIME"}, def: "docker", usage: "container runtime: docker or podman",
+			value: stringValue{p: &c.Runtime, oneOf: runtimes}, startup: true},
+		{key: "log_level", env: []string{"LOG_LEVEL"}, def: "info", usage: "log level: debug, info, warn or error",
+			value: stringValue{p: &c.LogLevel, oneOf: []string{"debug", "info", "warn", "error"}, fold: true}},
+		{key: "log_format", env: []string{"LOG_FORMAT"}, def: "timestamp", usage: "log format: compact, timestamp or json",
+			value: stringValue{p: &c.LogFormat, oneOf: []string{"compact", "timestamp", "json"}, fold: true}},
+
+		{key: "recovery_cooldown", env: []string{"RECOVERY_COOLDOWN"}, def: "2m", usage: "minimum time between recoveries of the same parent",
+			value: durationValue{p: &c.RecoveryCooldown, positive: true}},
+		{key: "initial_discovery_wait", env: []string{"WATCHDOG_INITIAL_DISCOVERY_WAIT"}, def: "60s", usage: "time after startup before recovery is enabled",
+			value: durationValue{p: &c.InitialDiscoveryWait, positive: true}, startup: true},
+		{key: "dependent_restart_cooldown", env: []string{"WATCHDOG_DEPENDENT_RESTART_COOLDOWN"}, def: "90s", usage: "minimum time between restarts of the same dependent (0 disables)",
+			value: durationValue{p: &c.DependentRestartCooldown}},
+		{key: "restart_timeout", env: []string{"WATCHDOG_RESTART_TIMEOUT"}, def: "10s", usage: "how long a container may take to stop on restart or stop before it is killed",
+			value: durationValue{p: &c.RestartTimeout, positive: true}},
+		{key: "shutdown_timeout", env: []string{"WATCHDOG_SHUTDOWN_TIMEOUT"}, def: "30s", usage: "how long shutdown lets running recoveries finish their current step before aborting them",
+			value: durationValue{p: &c.ShutdownTimeout}},
+		{key: "wait_healthy_timeout", env: []string{"WATCHDOG_WAIT_HEALTHY_TIMEOUT"}, def: "5m", usage: "wait-healthy timeout for parents without a healthcheck",
+			value: durationValue{p: &c.WaitHealthyTimeout, positive: true}},
+		{key: "recovery_strategy", env: []string{"WATCHDOG_RECOVERY_STRATEGY"}, def: "restart", usage: "recovery strategy: restart or stop-first",
+			value: stringValue{p: &c.RecoveryStrategy, oneOf: []string{"restart", "stop-first"}}},
+		{key: "dependent_concurrency", env: []string{"WATCHDOG_DEPENDENT_CONCURRENCY"}, def: "1", usage: "dependents of one parent restarted at the same time",
+			value: intValue{p: &c.DependentConcurrency, min: 1}},
+		{key: "verify_dependents", env: []string{"WATCHDOG_VERIFY_DEPENDENTS"}, def: "false", usage: "wait for restarted dependents to come back and retry failures",
+			value: boolValue{p: &c.VerifyDependents}},
+		{key: "dependent_retries", env: []string{"WATCHDOG_DEPENDENT_RETRIES"}, def: "2", usage: "extra restarts of a dependent that fails verification",
+			value: intValue{p: &c.DependentRetries}},
+		{key: "dependent_verify_timeout", env: []string{"WATCHDOG_DEPENDENT_VERIFY_TIMEOUT"}, def: "0s", usage: "wait for one dependent to verify (0: derived from its healthcheck)",
+			value: durationValue{p: &c.DependentVerifyTimeout}},
+
+		{key: "poll_interval", env: []string{"WATCHDOG_POLL_INTERVAL"}, def: "60s", usage: "base interval of the polling fallback",
+			value: durationValue{p: &c.PollInterval, positive: true}},
+		{key: "poll_interval_fast", env: []string{"WATCHDOG_POLL_INTERVAL_FAST"}, def: "10s", usage: "polling interval while a parent needs attention (at most poll_interval)",
+			value: durationValue{p: &c.PollIntervalFast, positive: true}},
+		{key: "poll_interval_max", env: []string{"WATCHDOG_POLL_INTERVAL_MAX"}, def: "5m", usage: "maximum polling interval while the event stream is up (at least poll_interval)",
+			value: durationValue{p: &c.PollIntervalMax, positive: true}},
+		{key: "poll_concurrency", env: []string{"WATCHDOG_POLL_CONCURRENCY"}, def: "4", usage: "parents inspected concurrently per poll",
+			value: intValue{p: &c.PollConcurrency, min: 1}},
+
+		{key: "status_addr", env: []string{"WATCHDOG_STATUS_ADDR"}, def: "127.0.0.1:9090", usage: "status API listen address, or off",
+			value: stringValue{p: &c.StatusAddr, check: checkListenAddr}, startup: true},
+		{key: "control_token", env: []string{"WATCHDOG_CONTROL_TOKEN"}, usage: "bearer token required by POST /reload (empty: only served on a loopback status_addr)",
+			value: stringValue{p: &c.ControlToken}, startup: true, secret: true},
+
+		{key: "journal_path", env: []string{"WATCHDOG_JOURNAL_PATH"}, usage: "recovery journal file (empty disables the journal)",
+			value: stringValue{p: &c.JournalPath}, startup: true},
+		{key: "journal_max_size", env: []string{"WATCHDOG_JOURNAL_MAX_SIZE"}, def: "10485760", usage: "journal size in bytes at which it is rotated (0 never rotates)",
+			value: intValue{p: &c.JournalMaxSize}, startup: true},
+		{key: "journal_max_files", env: []string{"WATCHDOG_JOURNAL_MAX_FILES"}, def: "5", usage: "rotated journal files kept",
+			value: intValue{p: &c.JournalMaxFiles}, startup: true},
+		{key: "journal_retention", env: []string{"WATCHDOG_JOURNAL_RETENTION"}, def: "720h", usage: "attempts older than this are dropped from the journal (0 keeps all)",
+			value: durationValue{p: &c.JournalRetention}, startup: true},
+
+		{key: "leader_lock", env: []string{"WATCHDOG_LEADER_LOCK"}, usage: "lease file on a volume shared by replicas; only the leader recovers (empty disables election)",
+			value: stringValue{p: &c.LeaderLock}, startup: true},
+		{key: "leader_lease", env: []string{"WATCHDOG_LEADER_LEASE"}, def: "15s", usage: "how long the leader lease lasts without renewal",
+			value: durationValue{p: &c.LeaderLease, positive: true}, startup: true},
+		{key: "leader_id", env: []string{"WATCHDOG_LEADER_ID"}, usage: "this replica's ID in the lease (default: hostname)",
+			value: stringValue{p: &c.LeaderID}, startup: true},
+
+		{key: "failure_log_dir", env: []string{"WATCHDOG_FAILURE_LOG_DIR"}, usage: "directory for failing parents' logs (empty disables capture)",
+			value: stringValue{p: &c.FailureLogDir}},
+		{key: "failure_log_lines", env: []string{"WATCHDOG_FAILURE_LOG_LINES"}, def: "200", usage: "log lines captured (0: no limit)",
+			value: intValue{p: &c.FailureLogLines}},
+		{key: "failure_log_since", env: []string{"WATCHDOG_FAILURE_LOG_SINCE"}, def: "0s", usage: "only capture output from this long before the failure (0: no limit)",
+			value: durationValue{p: &c.FailureLogSince}},
+		{key: "failure_log_max_files", env: []string{"WATCHDOG_FAILURE_LOG_MAX_FILES"}, def: "50", usage: "captures kept (0: unlimited)",
+			value: intValue{p: &c.FailureLogMaxFiles}},
+		{key: "failure_log_max_age", env: []string{"WATCHDOG_FAILURE_LOG_MAX_AGE"}, def: "168h", usage: "captures older than this are removed (0: kept regardless of age)",
+			value: durationValue{p: &c.FailureLogMaxAge}},
+	}
+}
+
+// Load returns the effective configuration for the command-line arguments args (without the
+// program name), reading the environment through getenv. Usage and flag errors are written to
+// stderr; -h returns flag.ErrHelp. Every invalid value in the config file or a flag is reported,
+// joined with errors.Join; an invalid environment variable is added to Warnings instead.
+func Load(args []string, getenv func(string) string, stderr io.Writer) (*Config, error) {
+	c := &Config{sources: make(map[string]string)}
+	settings := c.settings()
+
+	fs := flag.NewFlagSet("watch-dog", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: watch-dog [flags]")
+		fmt.Fprintln(stderr, "       watch-dog history|graph|healthcheck [flags]")
+		fmt.Fprintln(stderr, "Flags override environment variables, which override the config file.")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.File, "config", getenv("WATCHDOG_CONFIG"), "YAML config `file` (default $WATCHDOG_CONFIG)")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		fs.Func(s.flagName(), s.usage+" (env "+s.env[0]+")", func(v string) error {
+			flags[s.key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var file map[string]string
+	var errs []error
+	if c.File != "" {
+		var err error
+		if file, c.Endpoints, err = readFile(c.File, settings); err != nil {
+			errs = append(errs, err)
+		}
+		if len(c.Endpoints) > 0 {
+			c.sources["endpoints"] = "file"
+		}
+	}
+
+	for _, s := range settings {
+		raw, source := s.def, "default"
+		if v, ok := file[s.key]; ok {
+			raw, source = v, "file"
+		}
+		fallback, fallbackSource := raw, source
+		for _, name := range s.env {
+			if v := getenv(name); v != "" {
+				if s.fromEnv != nil {
+					v = s.fromEnv(name, v)
+				}
+				raw, source = v, "env "+name
+				break
+			}
+		}
+		if v, ok := flags[s.key]; ok {
+			raw, source = v, "flag -"+s.flagName()
+		}
+		err := s.value.set(raw)
+		if err != nil && strings.HasPrefix(source, "env ") {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s (%s): %v; using the %s value %s", s.key, source, err, fallbackSource, strconv.Quote(fallback)))
+			raw, source = fallback, fallbackSource
+			err = s.value.set(raw)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", s.key, source, err))
+			continue
+		}
+		c.sources[s.key] = source
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// The fast and max polling intervals bracket the base interval.
+	c.PollIntervalFast = min(c.PollIntervalFast, c.PollInterval)
+	c.PollIntervalMax = max(c.PollIntervalMax, c.PollInterval)
+	for i := range c.Endpoints {
+		if c.Endpoints[i].Runtime == "" {
+			c.Endpoints[i].Runtime = c.Runtime
+		}
+	}
+	return c, nil
+}
+
+// readFile reads the YAML config file at path: a mapping of setting keys to scalar values, plus
+// the endpoints list. Unknown keys and non-scalar values are errors.
+func readFile(path string, settings []setting) (map[string]string, []Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read config file: %w", err)
+	}
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	out := make(map[string]string, len(doc))
+	var endpoints []Endpoint
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(doc)) {
+		node := doc[key]
+		switch {
+		case key == "endpoints":
+			var err error
+			endpoints, err = readEndpoints(&node, path)
+			errs = append(errs, err)
+		case !known[key]:
+			errs = append(errs, fmt.Errorf("%s (file %s line %d): unknown setting", key, path, node.Line))
+		case node.Kind != yaml.ScalarNode:
+			errs = append(errs, fmt.Errorf("%s (file %s line %d): must be a single value", key, path, node.Line))
+		case node.Tag != "!!null":
+			out[key] = node.Value
+		}
+	}
+	return out, endpoints, errors.Join(errs...)
+}
+
+// endpointName is the syntax of endpoint names, which appear as log and metric labels.
+var endpointName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// readEndpoints reads and validates the endpoints list of the config file at path.
+func readEndpoints(node *yaml.Node, path string) ([]Endpoint, error) {
+	if node.Tag == "!!null" {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("endpoints (file %s line %d): must be a list", path, node.Line)
+	}
+	var out []Endpoint
+	var errs []error
+	seen := make(map[string]bool)
+	for i, item := range node.Content {
+		where := func(field string, line int) string {
+			return fmt.Sprintf("endpoints[%d]%s (file %s line %d)", i, field, path, line)
+		}
+		if item.Kind != yaml.MappingNode {
+			errs = append(errs, fmt.Errorf("%s: must be a mapping with name, host and compose_path", where("", item.Line)))
+			continue
+		}
+		var e Endpoint
+		fields := map[string]*string{"name": &e.Name, "host": &e.Host, "cert_path": &e.CertPath, "compose_path": &e.ComposePath, "runtime": &e.Runtime}
+		for j := 0; j+1 < len(item.Content); j += 2 {
+			k, v := item.Content[j], item.Content[j+1]
+			p, ok := fields[k.Value]
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Errorf("%s: unknown field", where("."+k.Value, k.Line)))
+			case v.Kind != yaml.ScalarNode:
+				errs = append(errs, fmt.Errorf("%s: must be a single value", where("."+k.Value, v.Line)))
+			default:
+				*p = v.Value
+			}
+		}
+		switch {
+		case !endpointName.MatchString(e.Name):
+			errs = append(errs, fmt.Errorf("%s: %q is not a valid name (letters, digits, '.', '_', '-')", where(".name", item.Line), e.Name))
+		case seen[e.Name]:
+			errs = append(errs, fmt.Errorf("%s: duplicate name %q", where(".name", item.Line), e.Name))
+		}
+		seen[e.Name] = true
+		if err := checkDockerHost(e.Host); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", where(".host", item.Line), err))
+		}
+		if e.ComposePath == "" {
+			errs = append(errs, fmt.Errorf("%s: required", where(".compose_path", item.Line)))
+		}
+		if e.Runtime != "" && !slices.Contains(runtimes, e.Runtime) {
+			errs = append(errs, fmt.Errorf("%s: %q is not one of %s", where(".runtime", item.Line), e.Runtime, strings.Join(runtimes, ", ")))
+		}
+		out = append(out, e)
+	}
+	return out, errors.Join(errs...)
+}
+
+// checkDockerHost accepts the daemon addresses the Docker client supports without extra tools.
+func checkDockerHost(host string) error {
+	scheme, rest, ok := strings.Cut(host, "://")
+	switch {
+	case host == "":
+		return errors.New("required")
+	case !ok || rest == "":
+		return fmt.Errorf("%q: want unix:///path or tcp://host:port", host)
+	case scheme == "ssh":
+		return fmt.Errorf("%q: ssh is not supported, forward the socket (ssh -L /run/host.sock:/var/run/docker.sock) and use unix://", host)
+	case scheme != "unix" && scheme != "tcp" && scheme != "http" && scheme != "https":
+		return fmt.Errorf("%q: unsupported scheme %s (want unix or tcp)", host, scheme)
+	}
+	return nil
+}
+
+// Changed returns the keys of the settings whose values differ between c and next, in the
+// order of the -h listing.
+func (c *Config) Changed(next *Config) []string {
+	var keys []string
+	other := next.settings()
+	for i, s := range c.settings() {
+		if s.value.String() != other[i].value.String() {
+			keys = append(keys, s.key)
+		}
+	}
+	if !slices.Equal(c.Endpoints, next.Endpoints) {
+		keys = append(keys, "endpoints")
+	}
+	return keys
+}
+
+// StartupOnly returns the keys among keys whose settings are only read when watch-dog starts,
+// so a reload does not apply them (e.g. status_addr, journal_path).
+func StartupOnly(keys []string) []string {
+	var out []string
+	for _, s := range (&Config{}).settings() {
+		if s.startup && slices.Contains(keys, s.key) {
+			out = append(out, s.key)
+		}
+	}
+	// Endpoints are connected once at startup.
+	if slices.Contains(keys, "endpoints") {
+		out = append(out, "endpoints")
+	}
+	return out
+}
+
+// Write prints the effective configuration as a YAML config file, with each value's source
+// (default, file, env VAR or flag -name) as a comment.
+func (c *Config) Write(w io.Writer) error {
+	if c.File != "" {
+		if _, err := fmt.Fprintf(w, "# config file: %s\n", c.File); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.settings() {
+		v := s.value.String()
+		if s.secret && v != `""` {
+			v = "<redacted>"
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s # %s\n", s.key, v, c.sources[s.key]); err != nil {
+			return err
+		}
+	}
+	if len(c.Endpoints) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "endpoints: # %s\n", c.sources["endpoints"]); err != nil {
+		return err
+	}
+	for _, e := range c.Endpoints {
+		if _, err := fmt.Fprintf(w, "  - name: %s\n    host: %s\n", strconv.Quote(e.Name), strconv.Quote(e.Host)); err != nil {
+			return err
+		}
+		if e.CertPath != "" {
+			if _, err := fmt.Fprintf(w, "    cert_path: %s\n", strconv.Quote(e.CertPath)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "    compose_path: %s\n    runtime: %s\n", strconv.Quote(e.ComposePath), e.Runtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstComposePath returns the first path of a colon-separated COMPOSE_FILE, like docker compose.
+func firstComposePath(name, raw string) string {
+	if name == "COMPOSE_FILE" {
+		raw, _, _ = strings.Cut(raw, ":")
+	}
+	return raw
+}
+
+func checkListenAddr(s string) error {
+	if s == "off" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		return fmt.Errorf("want host:port or off: %w", err)
+	}
+	return nil
+}
+
+// value parses a raw setting into a Config field and formats it back.
+type value interface {
+	set(raw string) error
+	String() string
+}
+
+type stringValue struct {
+	p     *string
+	oneOf []string
+	fold  bool // compare oneOf case-insensitively and store lowercase
+	check func(string) error
+}
+
+func (v stringValue) set(raw string) error {
+	if v.fold {
+		raw = strings.ToLower(strings.TrimSpace(raw))
+	}
+	if v.oneOf != nil && !slices.Contains(v.oneOf, raw) {
+		return fmt.Errorf("%q is not one of %s", raw, strings.Join(v.oneOf, ", "))
+	}
+	if v.check != nil {
+		if err := v.check(raw); err != nil {
+			return err
+		}
+	}
+	*v.p = raw
+	return nil
+}
+
+func (v stringValue) String() string { return strconv.Quote(*v.p) }
+
+type durationValue struct {
+	p        *time.Duration
+	positive bool
+}
+
+func (v durationValue) set(raw string) error {
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	if d < 0 || (v.positive && d == 0) {
+		if v.positive {
+			return fmt.Errorf("%q must be positive", raw)
+		}
+		return fmt.Errorf("%q must be non-negative", raw)
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string { return v.p.String() }
+
+type intValue struct {
+	p   *int
+	min int
+}
+
+func (v intValue) set(raw string) error {
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", raw)
+	}
+	if n < v.min {
+		return fmt.Errorf("%d must be at least %d", n, v.min)
+	}
+	*v.p = n
+	return nil
+}
+
+func (v intValue) String() string { return strconv.Itoa(*v.p) }
+
+type boolValue struct {
+	p *bool
+}
+
+func (v boolValue) set(raw string) error {
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", raw)
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
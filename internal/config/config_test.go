@@ -0,0 +1,305 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envOf(m map[string]string) func(string) string {
+	return func(name string) string { return m[name] }
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "watch-dog.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_defaults(t *testing.T) {
+	c, err := Load(nil, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RecoveryCooldown != 2*time.Minute || c.RestartTimeout != 10*time.Second || c.PollInterval != time.Minute ||
+		c.DependentConcurrency != 1 || c.RecoveryStrategy != "restart" || c.StatusAddr != "127.0.0.1:9090" || c.LogFormat != "timestamp" {
+		t.Errorf("defaults = %+v", c)
+	}
+	if c.JournalPath != "" || c.FailureLogDir != "" || c.ComposePath != "" {
+		t.Errorf("optional paths should default to empty: %+v", c)
+	}
+}
+
+func TestLoad_precedence(t *testing.T) {
+	path := writeFile(t, `
+poll_interval: 30s
+poll_concurrency: 8
+recovery_strategy: stop-first
+journal_path: /var/lib/watch-dog/journal.jsonl
+`)
+	env := envOf(map[string]string{
+		"WATCHDOG_CONFIG":           path,
+		"WATCHDOG_POLL_INTERVAL":    "20s",
+		"WATCHDOG_POLL_CONCURRENCY": "6",
+	})
+	c, err := Load([]string{"-poll-concurrency", "2"}, env, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.File != path {
+		t.Errorf("File = %q, want %q", c.File, path)
+	}
+	if c.RecoveryStrategy != "stop-first" || c.JournalPath != "/var/lib/watch-dog/journal.jsonl" {
+		t.Errorf("file values not applied: %+v", c)
+	}
+	if c.PollInterval != 20*time.Second {
+		t.Errorf("PollInterval = %s, want env 20s over file 30s", c.PollInterval)
+	}
+	if c.PollConcurrency != 2 {
+		t.Errorf("PollConcurrency = %d, want flag 2 over env 6 and file 8", c.PollConcurrency)
+	}
+	// The fast interval is capped by the base interval.
+	if c.PollIntervalFast != 10*time.Second || c.PollIntervalMax != 5*time.Minute {
+		t.Errorf("poll bounds = %s/%s", c.PollIntervalFast, c.PollIntervalMax)
+	}
+}
+
+func TestLoad_composeFileFallback(t *testing.T) {
+	c, err := Load(nil, envOf(map[string]string{"COMPOSE_FILE": "first.yml:second.yml"}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ComposePath != "first.yml" {
+		t.Errorf("ComposePath = %q, want first.yml", c.ComposePath)
+	}
+	c, err = Load(nil, envOf(map[string]string{"COMPOSE_FILE": "first.yml", "WATCHDOG_COMPOSE_PATH": "/app/compose.yml"}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ComposePath != "/app/compose.yml" {
+		t.Errorf("ComposePath = %q, want WATCHDOG_COMPOSE_PATH", c.ComposePath)
+	}
+}
+
+func TestLoad_aggregatesErrors(t *testing.T) {
+	path := writeFile(t, `
+poll_intervall: 30s
+dependent_concurrency: 0
+failure_log: {dir: /tmp}
+`)
+	env := envOf(map[string]string{
+		"WATCHDOG_CONFIG": path,
+		// Invalid environment variables are warnings (see TestLoad_invalidEnvFallsBack).
+		"RECOVERY_COOLDOWN": "soon",
+		// Overridden by a valid flag, so not reported.
+		"WATCHDOG_POLL_INTERVAL": "-1s",
+	})
+	_, err := Load([]string{"--poll-interval=1m", "-verify-dependents=maybe"}, env, io.Discard)
+	if err == nil {
+		t.Fatal("Load succeeded, want errors")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"poll_intervall (file " + path + " line 2): unknown setting",
+		"failure_log (file " + path + " line 4): unknown setting",
+		"dependent_concurrency (file): 0 must be at least 1",
+		`verify_dependents (flag -verify-dependents): "maybe" is not a boolean`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "poll_interval (") || strings.Contains(msg, "recovery_cooldown") {
+		t.Errorf("env value reported as an error:\n%s", msg)
+	}
+}
+
+func TestLoad_invalidEnvFallsBack(t *testing.T) {
+	path := writeFile(t, "recovery_cooldown: 5m\n")
+	c, err := Load(nil, envOf(map[string]string{
+		"WATCHDOG_CONFIG":            path,
+		"RECOVERY_COOLDOWN":          "abc",
+		"WATCHDOG_RECOVERY_STRATEGY": "reboot",
+		"WATCHDOG_STATUS_ADDR":       "9090",
+	}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RecoveryCooldown != 5*time.Minute || c.RecoveryStrategy != "restart" || c.StatusAddr != "127.0.0.1:9090" {
+		t.Errorf("RecoveryCooldown = %v, RecoveryStrategy = %q, StatusAddr = %q, want the file value and defaults", c.RecoveryCooldown, c.RecoveryStrategy, c.StatusAddr)
+	}
+	want := []string{
+		`recovery_cooldown (env RECOVERY_COOLDOWN): time: invalid duration "abc"; using the file value "5m"`,
+		`recovery_strategy (env WATCHDOG_RECOVERY_STRATEGY): "reboot" is not one of restart, stop-first; using the default value "restart"`,
+		`status_addr (env WATCHDOG_STATUS_ADDR): want host:port or off`,
+	}
+	if len(c.Warnings) != len(want) {
+		t.Fatalf("Warnings = %q, want %d", c.Warnings, len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(c.Warnings[i], w) {
+			t.Errorf("warning %d = %q, want %q", i, c.Warnings[i], w)
+		}
+	}
+	var out bytes.Buffer
+	if err := c.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "recovery_cooldown: 5m0s # file") {
+		t.Errorf("source of the fallback not shown:\n%s", out.String())
+	}
+}
+
+func TestLoad_missingFile(t *testing.T) {
+	_, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yml")}, envOf(nil), io.Discard)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
+
+func TestLoad_help(t *testing.T) {
+	var usage bytes.Buffer
+	_, err := Load([]string{"-h"}, envOf(nil), &usage)
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("err = %v, want flag.ErrHelp", err)
+	}
+	if !strings.Contains(usage.String(), "-restart-timeout") || !strings.Contains(usage.String(), "WATCHDOG_RESTART_TIMEOUT") {
+		t.Errorf("usage does not list settings:\n%s", usage.String())
+	}
+}
+
+func TestWrite_roundTrip(t *testing.T) {
+	c, err := Load([]string{"-status-addr", "off", "-restart-timeout", "30s"}, envOf(map[string]string{"LOG_LEVEL": "DEBUG", "WATCHDOG_CONTROL_TOKEN": "s3cret"}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := c.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`status_addr: "off" # flag -status-addr`,
+		"restart_timeout: 30s # flag -restart-timeout",
+		`log_level: "debug" # env LOG_LEVEL`,
+		"poll_interval: 1m0s # default",
+		"control_token: <redacted> # env WATCHDOG_CONTROL_TOKEN",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "s3cret") {
+		t.Errorf("output shows the control token:\n%s", out.String())
+	}
+
+	// The dump is itself a valid config file with the same values.
+	again, err := Load([]string{"-config", writeFile(t, out.String())}, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.StatusEnabled() || again.RestartTimeout != 30*time.Second || again.LogLevel != "debug" {
+		t.Errorf("round trip = %+v", again)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	old, err := Load(nil, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := Load([]string{"-log-level", "debug", "-status-addr", ":9191", "-recovery-cooldown", "5m"}, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := old.Changed(next)
+	if strings.Join(changed, ",") != "log_level,recovery_cooldown,status_addr" {
+		t.Errorf("Changed = %v", changed)
+	}
+	if got := StartupOnly(changed); strings.Join(got, ",") != "status_addr" {
+		t.Errorf("StartupOnly = %v, want [status_addr]", got)
+	}
+	if len(old.Changed(old)) != 0 {
+		t.Error("config differs from itself")
+	}
+}
+
+func TestLoad_endpoints(t *testing.T) {
+	path := writeFile(t, `
+endpoints:
+  - name: nas
+    host: tcp://nas.lan:2376
+    cert_path: /certs/nas
+    compose_path: /stacks/nas/compose.yml
+  - name: pi
+    host: unix:///run/pi-podman.sock
+    compose_path: /stacks/pi/compose.yml
+    runtime: podman
+`)
+	c, err := Load([]string{"-config", path}, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Endpoint{
+		{Name: "nas", Host: "tcp://nas.lan:2376", CertPath: "/certs/nas", ComposePath: "/stacks/nas/compose.yml", Runtime: "docker"},
+		{Name: "pi", Host: "unix:///run/pi-podman.sock", ComposePath: "/stacks/pi/compose.yml", Runtime: "podman"},
+	}
+	if len(c.Endpoints) != 2 || c.Endpoints[0] != want[0] || c.Endpoints[1] != want[1] {
+		t.Fatalf("Endpoints = %+v", c.Endpoints)
+	}
+
+	var out bytes.Buffer
+	if err := c.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Load([]string{"-config", writeFile(t, out.String())}, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("reload written config: %v\n%s", err, out.String())
+	}
+	if len(again.Changed(c)) != 0 {
+		t.Errorf("round trip changed %v", again.Changed(c))
+	}
+	base, err := Load(nil, envOf(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := StartupOnly(base.Changed(c)); len(got) != 1 || got[0] != "endpoints" {
+		t.Errorf("StartupOnly = %v, want [endpoints]", got)
+	}
+}
+
+func TestLoad_invalidEndpoints(t *testing.T) {
+	path := writeFile(t, `
+endpoints:
+  - name: nas
+    host: ssh://root@nas
+    compose_path: /stacks/nas.yml
+  - name: nas
+    host: tcp://nas2:2376
+    tls: true
+    runtime: containerd
+`)
+	_, err := Load([]string{"-config", path}, envOf(nil), io.Discard)
+	if err == nil {
+		t.Fatal("Load succeeded, want errors")
+	}
+	for _, want := range []string{
+		"endpoints[0].host (file " + path + " line 3): \"ssh://root@nas\": ssh is not supported",
+		"endpoints[1].tls (file " + path + " line 8): unknown field",
+		`endpoints[1].name (file ` + path + ` line 6): duplicate name "nas"`,
+		"endpoints[1].compose_path (file " + path + " line 6): required",
+		`endpoints[1].runtime (file ` + path + ` line 6): "containerd" is not one of docker, podman`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
@@ -1,27 +1,45 @@
-// Package discovery provides compose path resolution from environment variables
-// and builds the parent-to-dependents map from root-level depends_on.
+// Package discovery builds the dependency graph of the running containers from a compose file's
+// relations (depends_on, network_mode, links, volumes_from), mapped to containers by their compose
+// labels.
 package discovery
 
 import (
 	"context"
-	"os"
+	"slices"
 
-	"gopkg.in/yaml.v3"
-
-	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
 )
 
 // ComposeFile represents the minimal structure needed to read root-level depends_on.
-// Only "services" and each service's "depends_on" are used.
+// Only "services" and each service's dependency fields (see ServiceEdges) and "extends" are used.
 type ComposeFile struct {
-	// Services maps service name to service definition.
+	// Services maps service name to service definition (active services only, see LoadComposeFile).
 	Services map[string]ComposeService `yaml:"services"`
+
+	// source is the file path relative to the root compose file's directory.
+	source string
+	// external maps the absolute path of an extends file (the services' extends "file" values are
+	// resolved to it when loading) to the loaded file.
+	external map[string]*ComposeFile
+	// inactive holds services dropped because their profiles are not enabled.
+	inactive map[string]ComposeService
 }
 
-// ComposeService holds a single service's depends_on (and optional fields we ignore).
+// ComposeService holds a single service's dependencies (and optional fields we ignore).
 type ComposeService struct {
 	// DependsOn is short form ([]string) or long form (map[string]DependsOnEntry).
 	DependsOn interface{} `yaml:"depends_on"`
+	// NetworkMode is e.g. "service:vpn" or "container:vpn" when the service shares another
+	// container's network namespace; that service or container is an implicit parent.
+	NetworkMode string `yaml:"network_mode"`
+	// VolumesFrom lists "service[:mode]" or "container:name[:mode]" entries; each is an implicit parent.
+	VolumesFrom []string `yaml:"volumes_from"`
+	// Links lists legacy "service[:alias]" links; each is an implicit parent.
+	Links []string `yaml:"links"`
+	// Extends is "base" or {service: base[, file: other.yml]}; the base service's dependencies are inherited.
+	Extends interface{} `yaml:"extends"`
+	// Profiles lists the profiles that enable the service; none means always enabled.
+	Profiles []string `yaml:"profiles"`
 }
 
 // DependsOnEntry is the long-form value (condition, restart, etc.).
@@ -32,24 +50,14 @@ type DependsOnEntry struct {
 	Restart *bool `yaml:"restart"`
 }
 
-// ParseComposeFile reads and parses a compose YAML file into ComposeFile.
+// ParseComposeFile reads and parses a compose YAML file into ComposeFile, with interpolation
+// from the process environment, includes, extends files and profiles (see LoadComposeFile).
 // Returns nil if the file cannot be read or parsed.
 func ParseComposeFile(path string) (*ComposeFile, error) {
 	if path == "" {
 		return nil, nil
 	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var f ComposeFile
-	if err := yaml.Unmarshal(data, &f); err != nil {
-		return nil, err
-	}
-	if f.Services == nil {
-		f.Services = make(map[string]ComposeService)
-	}
-	return &f, nil
+	return LoadComposeFile(path, EnvMap())
 }
 
 // ServiceParents returns the list of parent service names for a given service's depends_on value.
@@ -95,6 +103,34 @@ func serviceParentsLong(m map[string]interface{}) []string {
 	return out
 }
 
+// ServiceConditions returns the depends_on condition for each parent service in a service's
+// depends_on value. Short-form entries and long-form entries without a condition map to
+// ConditionStarted.
+func ServiceConditions(dependsOn interface{}) map[string]string {
+	out := make(map[string]string)
+	switch v := dependsOn.(type) {
+	case []interface{}:
+		for _, name := range serviceParentsShort(v) {
+			out[name] = ConditionStarted
+		}
+	case map[string]interface{}:
+		for name, entry := range v {
+			name = trim(name)
+			if name == "" {
+				continue
+			}
+			cond := ConditionStarted
+			if e, ok := entry.(map[string]interface{}); ok {
+				if c, _ := e["condition"].(string); trim(c) != "" {
+					cond = trim(c)
+				}
+			}
+			out[name] = cond
+		}
+	}
+	return out
+}
+
 func trim(s string) string {
 	const space = " \t"
 	start := 0
@@ -109,26 +145,18 @@ func trim(s string) string {
 }
 
 // BuildServiceParentToDependents builds a map from parent service name to list of dependent service names
-// from the parsed compose file. Used together with running containers (compose labels) to build
-// ParentToDependents keyed by container name (see BuildParentToDependentsFromCompose).
+// from the parsed compose file (every kind of edge, see ServiceEdges). Used together with running
+// containers (compose labels) to build ParentToDependents keyed by container name (see BuildParentToDependentsFromCompose).
 func BuildServiceParentToDependents(f *ComposeFile) map[string][]string {
 	if f == nil || len(f.Services) == 0 {
 		return nil
 	}
-	// Set of valid service names (parents must be defined as services)
-	serviceNames := make(map[string]bool)
-	for name := range f.Services {
-		serviceNames[name] = true
-	}
 	m := make(map[string][]string)
-	for depName, svc := range f.Services {
-		parents := ServiceParents(svc.DependsOn)
-		for _, parent := range parents {
-			if !serviceNames[parent] {
-				continue
-			}
-			m[parent] = append(m[parent], depName)
+	for _, e := range f.ServiceEdges() {
+		if e.ParentIsContainer || slices.Contains(m[e.Parent], e.Dependent) {
+			continue
 		}
+		m[e.Parent] = append(m[e.Parent], e.Dependent)
 	}
 	return m
 }
@@ -142,17 +170,29 @@ const (
 // service-level parent→dependents map, maps service names to running container names
 // using com.docker.compose.service (and project) labels, and returns ParentToDependents
 // keyed by container name. Services with no running container are ignored.
-func BuildParentToDependentsFromCompose(ctx context.Context, cli *docker.Client, composePath string) (ParentToDependents, error) {
+func BuildParentToDependentsFromCompose(ctx context.Context, cli engine.Runtime, composePath string) (ParentToDependents, error) {
+	g, err := BuildGraphFromCompose(ctx, cli, composePath)
+	if err != nil || g == nil {
+		return nil, err
+	}
+	return g.ParentToDependents, nil
+}
+
+// BuildGraphFromCompose is like BuildParentToDependentsFromCompose but keeps every typed edge
+// (see ServiceEdges) with its depends_on condition and source, and which dependents share a
+// parent's network namespace. Edges that reference a container by name ("container:<name>")
+// apply when that container exists.
+func BuildGraphFromCompose(ctx context.Context, cli engine.Runtime, composePath string) (*Graph, error) {
 	if composePath == "" {
-		return make(ParentToDependents), nil
+		return NewGraph(make(ParentToDependents)), nil
 	}
 	f, err := ParseComposeFile(composePath)
 	if err != nil || f == nil {
 		return nil, err
 	}
-	svcParentToDeps := BuildServiceParentToDependents(f)
-	if len(svcParentToDeps) == 0 {
-		return make(ParentToDependents), nil
+	edges := f.ServiceEdges()
+	if len(edges) == 0 {
+		return NewGraph(make(ParentToDependents)), nil
 	}
 	// Include stopped containers so we still see parent services when a parent is stopped.
 	containers, err := cli.ListContainers(ctx, true)
@@ -161,30 +201,32 @@ func BuildParentToDependentsFromCompose(ctx context.Context, cli *docker.Client,
 	}
 	// service name -> container names (one service can have multiple replicas)
 	serviceToContainers := make(map[string][]string)
+	existing := make(map[string]bool, len(containers))
 	for _, c := range containers {
+		existing[c.Name] = true
 		svc, ok := c.Labels[labelComposeService]
 		if !ok || svc == "" {
 			continue
 		}
 		serviceToContainers[svc] = append(serviceToContainers[svc], c.Name)
 	}
-	// parent container name -> dependent container names
-	m := make(ParentToDependents)
-	for parentSvc, depSvcs := range svcParentToDeps {
-		parentContainers := serviceToContainers[parentSvc]
-		if len(parentContainers) == 0 {
-			continue
-		}
-		var allDepContainers []string
-		for _, depSvc := range depSvcs {
-			allDepContainers = append(allDepContainers, serviceToContainers[depSvc]...)
-		}
-		if len(allDepContainers) == 0 {
-			continue
+	for _, names := range serviceToContainers {
+		slices.Sort(names)
+	}
+	g := NewGraph(make(ParentToDependents))
+	for _, e := range edges {
+		parents := serviceToContainers[e.Parent]
+		if e.ParentIsContainer {
+			parents = nil
+			if existing[e.Parent] {
+				parents = []string{e.Parent}
+			}
 		}
-		for _, parentName := range parentContainers {
-			m[parentName] = append(m[parentName], allDepContainers...)
+		for _, dep := range serviceToContainers[e.Dependent] {
+			for _, parent := range parents {
+				g.AddEdge(Edge{Parent: parent, Dependent: dep, Kind: e.Kind, Condition: e.Condition, Source: e.Source})
+			}
 		}
 	}
-	return m, nil
+	return g, nil
 }
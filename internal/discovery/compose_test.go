@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"watch-dog/internal/docker"
+	"watch-dog/internal/dockertest"
+)
+
+func TestBuildParentToDependentsFromCompose_fakeDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	compose := `
+services:
+  vpn:
+    image: vpn
+  torrent:
+    image: torrent
+    deploy:
+      replicas: 2
+    depends_on:
+      vpn:
+        condition: service_healthy
+  web:
+    image: web
+    depends_on: [db]
+  db:
+    image: db
+`
+	if err := os.WriteFile(path, []byte(compose), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	service := func(name, svc, status string) dockertest.Container {
+		return dockertest.Container{Name: name, Status: status, Labels: map[string]string{
+			"com.docker.compose.project": "media",
+			"com.docker.compose.service": svc,
+		}}
+	}
+	// vpn is stopped but still a parent; web has no container, so db has no dependents.
+	d := dockertest.StartUnix(t,
+		service("media-vpn-1", "vpn", "exited"),
+		service("media-torrent-2", "torrent", ""),
+		service("media-torrent-1", "torrent", ""),
+		service("media-db-1", "db", ""),
+		dockertest.Container{Name: "unrelated"},
+	)
+	cli, err := docker.NewEndpointClient(context.Background(), docker.Endpoint{Host: d.Host()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := BuildParentToDependentsFromCompose(ctx, cli, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ParentToDependents{"media-vpn-1": {"media-torrent-1", "media-torrent-2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildParentToDependentsFromCompose() = %v, want %v", got, want)
+	}
+}
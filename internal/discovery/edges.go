@@ -0,0 +1,191 @@
+package discovery
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EdgeKind is the compose construct a dependency edge comes from.
+type EdgeKind string
+
+const (
+	// EdgeDependsOn is a depends_on entry (short or long form).
+	EdgeDependsOn EdgeKind = "depends_on"
+	// EdgeNetworkMode is network_mode: service:<parent> or container:<parent>; the dependent
+	// shares the parent's network namespace.
+	EdgeNetworkMode EdgeKind = "network_mode"
+	// EdgeVolumesFrom is a volumes_from entry.
+	EdgeVolumesFrom EdgeKind = "volumes_from"
+	// EdgeLinks is a legacy links entry.
+	EdgeLinks EdgeKind = "links"
+)
+
+// BypassesCooldown reports whether a dependent reached through this kind of edge is restarted
+// even within the dependent restart cooldown. Only network_mode does: the dependent has no network
+// until it rejoins the parent's new namespace, so skipping it would leave it broken. depends_on,
+// volumes_from and links dependents keep working across a parent restart (volumes persist, link
+// host entries are updated by Docker) and are restarted under the cooldown.
+func (k EdgeKind) BypassesCooldown() bool {
+	return k == EdgeNetworkMode
+}
+
+// Recovery describes how a dependent reached through this kind of edge is recovered after its parent.
+func (k EdgeKind) Recovery() string {
+	switch k {
+	case EdgeDependsOn:
+		return "restart after parent; later stages wait for its condition"
+	case EdgeNetworkMode:
+		return "restart ignoring cooldown; recreate if parent ID changed"
+	default:
+		return "restart after parent"
+	}
+}
+
+// ServiceEdge is a dependency between two compose services (or a service and a named container).
+type ServiceEdge struct {
+	// Parent is the parent service name, or a container name when ParentIsContainer is set.
+	Parent string `json:"parent"`
+	// ParentIsContainer is set for "container:<name>" references in network_mode and volumes_from.
+	ParentIsContainer bool `json:"parent_is_container,omitempty"`
+	// Dependent is the dependent service name.
+	Dependent string `json:"dependent"`
+	// Kind is the construct the edge comes from.
+	Kind EdgeKind `json:"kind"`
+	// Condition is the depends_on condition (depends_on edges only).
+	Condition string `json:"condition,omitempty"`
+	// Source is where the edge is declared, e.g. "services.api.depends_on", with
+	// " via services.<name>.extends" when it is inherited.
+	Source string `json:"source"`
+}
+
+// ServiceEdges returns every dependency edge declared in the compose file: depends_on,
+// network_mode, volumes_from and links, including those a service inherits through extends
+// (in the same file or, when loaded with LoadComposeFile, another file). A service's own edge
+// wins over an inherited one of the same kind to the same parent. Edges to services that are not
+// defined (or not enabled by a profile) are dropped. Sorted by dependent, parent and kind.
+func (f *ComposeFile) ServiceEdges() []ServiceEdge {
+	if f == nil {
+		return nil
+	}
+	var out []ServiceEdge
+	type key struct {
+		parent, dependent string
+		kind              EdgeKind
+	}
+	seen := make(map[key]bool)
+	for name := range f.Services {
+		file, base := f, name
+		visited := map[string]bool{file.source + "#" + base: true}
+		via := ""
+		for {
+			svc, ok := file.service(base)
+			if !ok {
+				break
+			}
+			for _, e := range svc.edges(base) {
+				e.Dependent = name
+				if file != f {
+					e.Source = file.source + ": " + e.Source
+				}
+				if via != "" {
+					e.Source += " via " + via
+				}
+				if !e.ParentIsContainer && !f.hasService(e.Parent) {
+					continue
+				}
+				k := key{e.Parent, e.Dependent, e.Kind}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				out = append(out, e)
+			}
+			extFile, next := svc.extendsRef()
+			if next == "" {
+				break
+			}
+			if extFile != "" {
+				if file = file.external[extFile]; file == nil {
+					break
+				}
+			}
+			if visited[file.source+"#"+next] {
+				break
+			}
+			visited[file.source+"#"+next] = true
+			if via == "" {
+				via = "services." + name + ".extends"
+			}
+			base = next
+		}
+	}
+	slices.SortFunc(out, func(a, b ServiceEdge) int {
+		return cmp.Or(strings.Compare(a.Dependent, b.Dependent), strings.Compare(a.Parent, b.Parent), strings.Compare(string(a.Kind), string(b.Kind)))
+	})
+	return out
+}
+
+// service returns a service by name, including services disabled by profiles (extends bases).
+func (f *ComposeFile) service(name string) (ComposeService, bool) {
+	if svc, ok := f.Services[name]; ok {
+		return svc, true
+	}
+	svc, ok := f.inactive[name]
+	return svc, ok
+}
+
+func (f *ComposeFile) hasService(name string) bool {
+	_, ok := f.Services[name]
+	return ok
+}
+
+// edges returns the service's own edges, with Dependent and Source set for service name.
+func (s ComposeService) edges(name string) []ServiceEdge {
+	var out []ServiceEdge
+	source := func(field string) string { return fmt.Sprintf("services.%s.%s", name, field) }
+	conditions := ServiceConditions(s.DependsOn)
+	for _, parent := range ServiceParents(s.DependsOn) {
+		out = append(out, ServiceEdge{Parent: parent, Dependent: name, Kind: EdgeDependsOn, Condition: conditions[parent], Source: source("depends_on")})
+	}
+	mode := trim(s.NetworkMode)
+	if parent, ok := strings.CutPrefix(mode, "service:"); ok && trim(parent) != "" {
+		out = append(out, ServiceEdge{Parent: trim(parent), Dependent: name, Kind: EdgeNetworkMode, Source: source("network_mode")})
+	} else if parent, ok := strings.CutPrefix(mode, "container:"); ok && trim(parent) != "" {
+		out = append(out, ServiceEdge{Parent: trim(parent), ParentIsContainer: true, Dependent: name, Kind: EdgeNetworkMode, Source: source("network_mode")})
+	}
+	for _, v := range s.VolumesFrom {
+		e := ServiceEdge{Dependent: name, Kind: EdgeVolumesFrom, Source: source("volumes_from")}
+		v = trim(v)
+		if rest, ok := strings.CutPrefix(v, "container:"); ok {
+			v, e.ParentIsContainer = rest, true
+		}
+		e.Parent, _, _ = strings.Cut(v, ":")
+		if e.Parent = trim(e.Parent); e.Parent != "" {
+			out = append(out, e)
+		}
+	}
+	for _, l := range s.Links {
+		// "service" or "service:alias"
+		parent, _, _ := strings.Cut(trim(l), ":")
+		if parent = trim(parent); parent != "" {
+			out = append(out, ServiceEdge{Parent: parent, Dependent: name, Kind: EdgeLinks, Source: source("links")})
+		}
+	}
+	return out
+}
+
+// extendsRef returns the file ("" for the same file) and service named by extends
+// ("extends: base" or "extends: {service: base, file: other.yml}"); service is "" without extends.
+func (s ComposeService) extendsRef() (file, service string) {
+	switch v := s.Extends.(type) {
+	case string:
+		return "", trim(v)
+	case map[string]interface{}:
+		file, _ = v["file"].(string)
+		service, _ = v["service"].(string)
+		return trim(file), trim(service)
+	}
+	return "", ""
+}
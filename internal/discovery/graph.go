@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"maps"
+	"slices"
+
+	"watch-dog/internal/docker"
+)
+
+// depends_on conditions (long form). A short-form entry means ConditionStarted.
+const (
+	ConditionStarted               = "service_started"
+	ConditionHealthy               = "service_healthy"
+	ConditionCompletedSuccessfully = "service_completed_successfully"
+)
+
+// Edge is a typed dependency between two containers.
+type Edge struct {
+	// Parent and Dependent are container names.
+	Parent    string `json:"parent"`
+	Dependent string `json:"dependent"`
+	// Kind is the compose construct the edge comes from.
+	Kind EdgeKind `json:"kind"`
+	// Condition is the depends_on condition (depends_on edges only).
+	Condition string `json:"condition,omitempty"`
+	// Source is where the edge is declared in the compose file (see ServiceEdge.Source).
+	Source string `json:"source,omitempty"`
+}
+
+// Graph is the container-level dependency graph: which containers depend on which parents,
+// through which kind of edge and under which depends_on condition.
+type Graph struct {
+	ParentToDependents
+	// Edges holds every typed edge, in the order added.
+	Edges []Edge
+	// Conditions maps dependent container name -> parent container name -> depends_on condition.
+	// A missing entry means ConditionStarted.
+	Conditions map[string]map[string]string
+	// NetworkParents maps dependent container name -> parent container name whose network
+	// namespace it joins (network_mode: service:<parent> or container:<parent>).
+	NetworkParents map[string]string
+}
+
+// NewGraph returns a graph with the given edges, no conditions (all ConditionStarted) and no
+// shared network namespaces.
+func NewGraph(m ParentToDependents) *Graph {
+	return &Graph{ParentToDependents: m, Conditions: make(map[string]map[string]string), NetworkParents: make(map[string]string)}
+}
+
+// AddEdge records e: the dependent is added to the parent's dependents (once), a depends_on
+// condition other than service_started is kept, and a network_mode edge marks the network parent
+// (the first one added wins).
+func (g *Graph) AddEdge(e Edge) {
+	g.Edges = append(g.Edges, e)
+	if !slices.Contains(g.ParentToDependents[e.Parent], e.Dependent) {
+		g.ParentToDependents[e.Parent] = append(g.ParentToDependents[e.Parent], e.Dependent)
+	}
+	switch e.Kind {
+	case EdgeDependsOn:
+		g.setCondition(e.Dependent, e.Parent, e.Condition)
+	case EdgeNetworkMode:
+		if _, ok := g.NetworkParents[e.Dependent]; !ok {
+			g.NetworkParents[e.Dependent] = e.Parent
+		}
+	}
+}
+
+// BypassesCooldown reports whether dependent is linked to parent by an edge whose kind restarts
+// it even within the dependent restart cooldown (see EdgeKind.BypassesCooldown).
+func (g *Graph) BypassesCooldown(dependent, parent string) bool {
+	if g.SharesNetwork(dependent, parent) {
+		return true
+	}
+	return slices.ContainsFunc(g.Edges, func(e Edge) bool {
+		return e.Parent == parent && e.Dependent == dependent && e.Kind.BypassesCooldown()
+	})
+}
+
+// SharesNetwork reports whether dependent joins parent's network namespace.
+func (g *Graph) SharesNetwork(dependent, parent string) bool {
+	return g.NetworkParents[dependent] == parent
+}
+
+// Condition returns the depends_on condition under which dependent depends on parent
+// (ConditionStarted when unknown).
+func (g *Graph) Condition(dependent, parent string) string {
+	if c := g.Conditions[dependent][parent]; c != "" {
+		return c
+	}
+	return ConditionStarted
+}
+
+func (g *Graph) setCondition(dependent, parent, condition string) {
+	if condition == "" || condition == ConditionStarted {
+		return
+	}
+	if g.Conditions[dependent] == nil {
+		g.Conditions[dependent] = make(map[string]string)
+	}
+	g.Conditions[dependent][parent] = condition
+}
+
+// Stages orders names topologically by the edges among them, of every kind (depends_on,
+// network_mode, volumes_from and links, as compose orders startup): every stage holds the names
+// whose parents (within names) are all in earlier stages, sorted by name. Names in a dependency
+// cycle are put together in a final stage, sorted by name.
+func (g *Graph) Stages(names []string) [][]string {
+	in := make(map[string]bool, len(names))
+	for _, n := range names {
+		in[n] = true
+	}
+	indegree := make(map[string]int, len(names))
+	children := make(map[string][]string, len(names))
+	for n := range in {
+		seen := make(map[string]bool)
+		for _, d := range g.GetDependents(n) {
+			if !in[d] || d == n || seen[d] {
+				continue
+			}
+			seen[d] = true
+			children[n] = append(children[n], d)
+			indegree[d]++
+		}
+	}
+	remaining := slices.Sorted(maps.Keys(in))
+	var stages [][]string
+	for len(remaining) > 0 {
+		var stage, rest []string
+		for _, n := range remaining {
+			if indegree[n] == 0 {
+				stage = append(stage, n)
+			} else {
+				rest = append(rest, n)
+			}
+		}
+		if len(stage) == 0 {
+			docker.LogWarn("dependency cycle among dependents, restarting them together", "containers", rest)
+			return append(stages, rest)
+		}
+		for _, n := range stage {
+			for _, d := range children[n] {
+				indegree[d]--
+			}
+		}
+		stages = append(stages, stage)
+		remaining = rest
+	}
+	return stages
+}
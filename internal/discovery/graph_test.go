@@ -0,0 +1,152 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGraphStages_dependencyOrderWithNameTieBreak(t *testing.T) {
+	// All depend on vpn; api depends on db and cache, which depend on network-gw.
+	g := NewGraph(ParentToDependents{
+		"vpn":        {"api", "cache", "db", "network-gw", "worker"},
+		"network-gw": {"cache", "db"},
+		"db":         {"api"},
+		"cache":      {"api"},
+	})
+
+	got := g.Stages([]string{"api", "cache", "db", "network-gw", "worker"})
+
+	want := [][]string{{"network-gw", "worker"}, {"cache", "db"}, {"api"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stages() = %v, want %v", got, want)
+	}
+}
+
+func TestGraphStages_cycleGoesLast(t *testing.T) {
+	g := NewGraph(ParentToDependents{"a": {"b"}, "b": {"a"}, "c": {"a"}})
+
+	got := g.Stages([]string{"a", "b", "c"})
+
+	want := [][]string{{"c"}, {"a", "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stages() = %v, want %v", got, want)
+	}
+}
+
+func TestGraphStages_everyEdgeKindOrders(t *testing.T) {
+	g := NewGraph(make(ParentToDependents))
+	g.AddEdge(Edge{Parent: "vpn", Dependent: "proxy", Kind: EdgeNetworkMode})
+	g.AddEdge(Edge{Parent: "proxy", Dependent: "web", Kind: EdgeLinks})
+	g.AddEdge(Edge{Parent: "web", Dependent: "backup", Kind: EdgeVolumesFrom})
+	g.AddEdge(Edge{Parent: "backup", Dependent: "report", Kind: EdgeDependsOn})
+
+	got := g.Stages([]string{"report", "backup", "web", "proxy"})
+
+	want := [][]string{{"proxy"}, {"web"}, {"backup"}, {"report"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stages() = %v, want %v", got, want)
+	}
+}
+
+func TestServiceConditions(t *testing.T) {
+	var f ComposeFile
+	data := `
+services:
+  db: {}
+  migrate: {}
+  cache: {}
+  api:
+    depends_on:
+      db:
+        condition: service_healthy
+      migrate:
+        condition: service_completed_successfully
+      cache: {}
+  web:
+    depends_on: [api]
+`
+	if err := yaml.Unmarshal([]byte(data), &f); err != nil {
+		t.Fatal(err)
+	}
+
+	got := ServiceConditions(f.Services["api"].DependsOn)
+	want := map[string]string{"db": ConditionHealthy, "migrate": ConditionCompletedSuccessfully, "cache": ConditionStarted}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ServiceConditions(api) = %v, want %v", got, want)
+	}
+	if got := ServiceConditions(f.Services["web"].DependsOn); !reflect.DeepEqual(got, map[string]string{"api": ConditionStarted}) {
+		t.Errorf("ServiceConditions(web) = %v", got)
+	}
+}
+
+func TestServiceEdges_typedEdgesWithSources(t *testing.T) {
+	var f ComposeFile
+	data := `
+services:
+  vpn: {}
+  db: {}
+  data: {}
+  base:
+    depends_on:
+      db:
+        condition: service_healthy
+  app:
+    extends: base
+    network_mode: service:vpn
+    volumes_from: ["data:ro", "container:legacy-store:rw"]
+    links: ["db:database"]
+  worker:
+    extends:
+      service: app
+`
+	if err := yaml.Unmarshal([]byte(data), &f); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []ServiceEdge
+	for _, e := range f.ServiceEdges() {
+		if e.Dependent == "app" {
+			got = append(got, e)
+		}
+	}
+	want := []ServiceEdge{
+		{Parent: "data", Dependent: "app", Kind: EdgeVolumesFrom, Source: "services.app.volumes_from"},
+		{Parent: "db", Dependent: "app", Kind: EdgeDependsOn, Condition: ConditionHealthy, Source: "services.base.depends_on via services.app.extends"},
+		{Parent: "db", Dependent: "app", Kind: EdgeLinks, Source: "services.app.links"},
+		{Parent: "legacy-store", ParentIsContainer: true, Dependent: "app", Kind: EdgeVolumesFrom, Source: "services.app.volumes_from"},
+		{Parent: "vpn", Dependent: "app", Kind: EdgeNetworkMode, Source: "services.app.network_mode"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("app edges =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// extends chains: worker inherits app's edges, which include base's.
+	var workerSources []string
+	for _, e := range f.ServiceEdges() {
+		if e.Dependent == "worker" && e.Kind == EdgeDependsOn {
+			workerSources = append(workerSources, e.Source)
+		}
+	}
+	if !reflect.DeepEqual(workerSources, []string{"services.base.depends_on via services.worker.extends"}) {
+		t.Errorf("worker depends_on sources = %v", workerSources)
+	}
+}
+
+func TestGraphAddEdge_networkModeBypassesCooldown(t *testing.T) {
+	g := NewGraph(make(ParentToDependents))
+	g.AddEdge(Edge{Parent: "vpn", Dependent: "torrent", Kind: EdgeNetworkMode})
+	g.AddEdge(Edge{Parent: "vpn", Dependent: "torrent", Kind: EdgeDependsOn, Condition: ConditionHealthy})
+	g.AddEdge(Edge{Parent: "db", Dependent: "torrent", Kind: EdgeLinks})
+
+	if got := g.GetDependents("vpn"); !reflect.DeepEqual(got, []string{"torrent"}) {
+		t.Errorf("GetDependents(vpn) = %v, want [torrent]", got)
+	}
+	if !g.SharesNetwork("torrent", "vpn") || g.Condition("torrent", "vpn") != ConditionHealthy {
+		t.Errorf("network parent %q, condition %q", g.NetworkParents["torrent"], g.Condition("torrent", "vpn"))
+	}
+	if !g.BypassesCooldown("torrent", "vpn") || g.BypassesCooldown("torrent", "db") {
+		t.Error("only the network_mode edge should bypass the cooldown")
+	}
+}
@@ -0,0 +1,386 @@
+package discovery
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadComposeFile loads the compose file at path the way docker compose reads it, as far as
+// dependencies are concerned:
+//   - ${VAR}, $VAR, ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error}, ${VAR:+alt},
+//     ${VAR+alt} and $$ are interpolated from env, falling back to the .env file next to each file;
+//   - top-level include entries are loaded relative to the including file and their services merged;
+//   - extends with a file is loaded relative to the extending file (see ServiceEdges);
+//   - services whose profiles are not enabled by COMPOSE_PROFILES (env, then the root .env) are dropped.
+//
+// env is typically the process environment (see EnvMap).
+func LoadComposeFile(path string, env map[string]string) (*ComposeFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &composeLoader{env: env, rootDir: filepath.Dir(abs), files: make(map[string]*ComposeFile), loading: make(map[string]bool)}
+	f, err := l.load(abs)
+	if err != nil {
+		return nil, err
+	}
+	profiles := l.envFor(l.rootDir)["COMPOSE_PROFILES"]
+	f.applyProfiles(strings.Split(profiles, ","))
+	return f, nil
+}
+
+// EnvMap returns the process environment as a map.
+func EnvMap() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+type composeLoader struct {
+	env     map[string]string
+	rootDir string
+	// files caches loaded files by absolute path; loading detects include/extends cycles.
+	files   map[string]*ComposeFile
+	loading map[string]bool
+}
+
+// load reads, interpolates and parses one file, following its includes and extends files.
+func (l *composeLoader) load(abs string) (*ComposeFile, error) {
+	if f, ok := l.files[abs]; ok {
+		return f, nil
+	}
+	if l.loading[abs] {
+		return nil, fmt.Errorf("%s: include or extends cycle", abs)
+	}
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	dir := filepath.Dir(abs)
+	interpolated, err := interpolateValue(raw, l.envFor(dir))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	raw, _ = interpolated.(map[string]interface{})
+	includes := includePaths(raw["include"])
+	delete(raw, "include")
+	out, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	f := &ComposeFile{}
+	if err := yaml.Unmarshal(out, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	if f.Services == nil {
+		f.Services = make(map[string]ComposeService)
+	}
+	if rel, err := filepath.Rel(l.rootDir, abs); err == nil {
+		f.source = rel
+	} else {
+		f.source = abs
+	}
+
+	// Extends files are relative to the file that names them; resolve them before the services of
+	// includes (whose extends are resolved already) are merged in, and key them by absolute path
+	// so same-named files in different directories do not collide.
+	for name, svc := range f.Services {
+		file, _ := svc.extendsRef()
+		if file == "" {
+			continue
+		}
+		extAbs := resolvePath(dir, file)
+		ext, err := l.load(extAbs)
+		if err != nil {
+			return nil, fmt.Errorf("service %q extends %s: %w", name, file, err)
+		}
+		f.Services[name] = svc.withExtendsFile(extAbs)
+		f.addExternal(map[string]*ComposeFile{extAbs: ext})
+	}
+	for _, inc := range includes {
+		sub, err := l.load(resolvePath(dir, inc))
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", inc, err)
+		}
+		for name, svc := range sub.Services {
+			if _, dup := f.Services[name]; dup {
+				return nil, fmt.Errorf("%s: service %q is also defined by include %s", abs, name, inc)
+			}
+			f.Services[name] = svc
+		}
+		f.addExternal(sub.external)
+	}
+	l.files[abs] = f
+	return f, nil
+}
+
+// envFor returns env with variables from dir/.env added where env does not set them.
+func (l *composeLoader) envFor(dir string) map[string]string {
+	env := make(map[string]string, len(l.env))
+	for k, v := range readDotEnv(filepath.Join(dir, ".env")) {
+		env[k] = v
+	}
+	for k, v := range l.env {
+		env[k] = v
+	}
+	return env
+}
+
+// withExtendsFile returns s with the file of its extends replaced by file.
+func (s ComposeService) withExtendsFile(file string) ComposeService {
+	if m, ok := s.Extends.(map[string]interface{}); ok {
+		m = maps.Clone(m)
+		m["file"] = file
+		s.Extends = m
+	}
+	return s
+}
+
+func (f *ComposeFile) addExternal(m map[string]*ComposeFile) {
+	for k, v := range m {
+		if f.external == nil {
+			f.external = make(map[string]*ComposeFile)
+		}
+		f.external[k] = v
+	}
+}
+
+// applyProfiles drops services that declare profiles none of which is in enabled ("*" enables all).
+// Dropped services stay available as extends bases.
+func (f *ComposeFile) applyProfiles(enabled []string) {
+	for name, svc := range f.Services {
+		if len(svc.Profiles) == 0 || slices.Contains(enabled, "*") || slices.ContainsFunc(svc.Profiles, func(p string) bool { return slices.Contains(enabled, p) }) {
+			continue
+		}
+		if f.inactive == nil {
+			f.inactive = make(map[string]ComposeService)
+		}
+		f.inactive[name] = svc
+		delete(f.Services, name)
+	}
+}
+
+// includePaths returns the files of a top-level include: "path", {path: "path"} or {path: [...]}.
+func includePaths(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, item := range list {
+		switch it := item.(type) {
+		case string:
+			out = append(out, it)
+		case map[string]interface{}:
+			switch p := it["path"].(type) {
+			case string:
+				out = append(out, p)
+			case []interface{}:
+				for _, s := range p {
+					if s, ok := s.(string); ok {
+						out = append(out, s)
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+func resolvePath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// readDotEnv parses KEY=VALUE lines (optionally prefixed by "export", values optionally quoted);
+// a missing or unreadable file yields no variables.
+func readDotEnv(path string) map[string]string {
+	env := make(map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return env
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		} else if i := strings.Index(v, " #"); i >= 0 {
+			v = strings.TrimSpace(v[:i])
+		}
+		env[k] = v
+	}
+	return env
+}
+
+// interpolateValue interpolates every string (map keys included) in a decoded YAML value.
+func interpolateValue(v interface{}, env map[string]string) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return interpolate(t, env)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			key, err := interpolate(k, env)
+			if err != nil {
+				return nil, err
+			}
+			if out[key], err = interpolateValue(val, env); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			var err error
+			if out[i], err = interpolateValue(val, env); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// interpolate substitutes variables in s (see LoadComposeFile for the supported forms).
+func interpolate(s string, env map[string]string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("invalid interpolation format for %q: missing }", s)
+			}
+			val, err := expand(s[i+2:end], env)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			b.WriteString(env[s[i+1:j]])
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// expand evaluates the inside of ${...}: a name optionally followed by an operator and a word,
+// which is itself interpolated.
+func expand(expr string, env map[string]string) (string, error) {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" {
+		return "", fmt.Errorf("invalid interpolation format for ${%s}", expr)
+	}
+	val, set := env[name]
+	var op string
+	for _, o := range []string{":-", ":?", ":+", "-", "?", "+"} {
+		if strings.HasPrefix(rest, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" && rest != "" {
+		return "", fmt.Errorf("invalid interpolation format for ${%s}", expr)
+	}
+	word := func() (string, error) { return interpolate(rest[len(op):], env) }
+	empty := !set || (strings.HasPrefix(op, ":") && val == "")
+	switch op {
+	case ":-", "-":
+		if empty {
+			return word()
+		}
+	case ":?", "?":
+		if empty {
+			msg, err := word()
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				return "", errors.New("required variable " + name + " is missing a value")
+			}
+			return "", errors.New("required variable " + name + " is missing a value: " + msg)
+		}
+	case ":+", "+":
+		if empty {
+			return "", nil
+		}
+		return word()
+	}
+	return val, nil
+}
+
+// matchingBrace returns the index of the } closing the { at open, honoring nested ${...}.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"SET": "value", "EMPTY": ""}
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"$SET and ${SET}", "value and value"},
+		{"${UNSET:-def}", "def"},
+		{"${EMPTY:-def}", "def"},
+		{"${EMPTY-def}", ""},
+		{"${UNSET-${SET}}", "value"},
+		{"${SET:+alt}", "alt"},
+		{"${UNSET:+alt}", ""},
+		{"cost $$5", "cost $5"},
+		{"$UNSET", ""},
+	}
+	for _, tt := range tests {
+		got, err := interpolate(tt.in, env)
+		if err != nil || got != tt.want {
+			t.Errorf("interpolate(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := interpolate("${EMPTY:?must be set}", env); err == nil || !strings.Contains(err.Error(), "EMPTY is missing a value: must be set") {
+		t.Errorf("${EMPTY:?...} error = %v", err)
+	}
+	if _, err := interpolate("${EMPTY?must be set}", env); err != nil {
+		t.Errorf("${EMPTY?...} with empty value: unexpected error %v", err)
+	}
+}
+
+func TestLoadComposeFile_interpolationIncludeExtendsProfiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".env": "GATEWAY=vpn\nCOMPOSE_PROFILES=media\n",
+		"compose.yml": `
+include:
+  - infra/compose.yml
+services:
+  app:
+    extends:
+      file: common/base.yml
+      service: base
+    network_mode: service:${GATEWAY}
+  torrent:
+    profiles: [media]
+    depends_on: ["${GATEWAY}"]
+  debug:
+    profiles: [debug]
+    depends_on: [app]
+`,
+		"infra/compose.yml": `
+services:
+  vpn: {}
+  db: {}
+`,
+		"common/base.yml": `
+services:
+  base:
+    depends_on:
+      db:
+        condition: service_healthy
+`,
+	})
+
+	f, err := LoadComposeFile(filepath.Join(dir, "compose.yml"), map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range f.ServiceEdges() {
+		got = append(got, e.Dependent+"<-"+e.Parent+" "+string(e.Kind)+" "+e.Source)
+	}
+	want := []string{
+		"app<-db depends_on " + filepath.Join("common", "base.yml") + ": services.base.depends_on via services.app.extends",
+		"app<-vpn network_mode services.app.network_mode",
+		"torrent<-vpn depends_on services.torrent.depends_on",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("edges =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadComposeFile_extendsFilesWithTheSameNameInDifferentDirectories(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"compose.yml": `
+include:
+  - sub/compose.yml
+services:
+  db: {}
+  cache: {}
+  app:
+    extends: {file: base.yml, service: base}
+`,
+		"base.yml": "services:\n  base:\n    depends_on: [db]\n",
+		"sub/compose.yml": `
+services:
+  worker:
+    extends: {file: base.yml, service: base}
+`,
+		"sub/base.yml": "services:\n  base:\n    depends_on: [cache]\n",
+	})
+
+	f, err := LoadComposeFile(filepath.Join(dir, "compose.yml"), map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range f.ServiceEdges() {
+		got = append(got, e.Dependent+"<-"+e.Parent+" "+e.Source)
+	}
+	want := []string{
+		"app<-db base.yml: services.base.depends_on via services.app.extends",
+		"worker<-cache " + filepath.Join("sub", "base.yml") + ": services.base.depends_on via services.worker.extends",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("edges =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadComposeFile_environmentOverridesDotEnvAndRequiredVariable(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".env":        "PARENT=db\n",
+		"compose.yml": "services:\n  db: {}\n  cache: {}\n  app:\n    depends_on: [\"${PARENT}\"]\n  web:\n    depends_on: [\"${WEB_PARENT:?set WEB_PARENT}\"]\n",
+	})
+	path := filepath.Join(dir, "compose.yml")
+
+	if _, err := LoadComposeFile(path, map[string]string{"PARENT": "cache"}); err == nil || !strings.Contains(err.Error(), "WEB_PARENT") {
+		t.Fatalf("missing required variable: err = %v", err)
+	}
+	f, err := LoadComposeFile(path, map[string]string{"PARENT": "cache", "WEB_PARENT": "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := BuildServiceParentToDependents(f)["cache"]; !reflect.DeepEqual(got, []string{"app"}) {
+		t.Errorf("dependents of cache = %v, want [app] (environment wins over .env)", got)
+	}
+}
@@ -1,30 +1,46 @@
-// Package docker provides a Docker API client for listing containers, inspecting
-// health status, and restarting containers, plus logging and health-status event subscription.
+// Package docker is the Docker Engine API backend of engine.Runtime: it lists containers, inspects
+// health status, restarts, stops, starts and recreates containers, reads their logs and subscribes
+// to health-status events. It also provides watch-dog's logging.
 package docker
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"watch-dog/internal/engine"
 )
 
 // Client wraps the Docker API for listing containers, inspecting health, and restarting.
+// It implements engine.Runtime.
 type Client struct {
 	cli *client.Client
+	// log labels the client's own log lines with its endpoint (nil for the DOCKER_HOST client).
+	log *Logger
+	// stopTimeoutNS is how long Restart, Stop and Recreate let a container stop before it is
+	// killed, in nanoseconds (see SetStopTimeout). Zero means the default of 10s.
+	stopTimeoutNS atomic.Int64
 }
 
-// ContainerInfo holds minimal container data for discovery.
-type ContainerInfo struct {
-	// ID is the container ID.
-	ID string
-	// Name is the container name (without leading slash).
-	Name string
-	// Labels holds container labels (e.g. com.docker.compose.service).
-	Labels map[string]string
-	// State is "running", "exited", etc.; only set when ListContainers is called with all=true.
-	State string
+var _ engine.Runtime = (*Client)(nil)
+
+// SetStopTimeout sets how long Restart, Stop and Recreate let a container stop before it is
+// killed (rounded up to whole seconds; zero restores the default of 10s). Safe to call while
+// containers are being restarted.
+func (c *Client) SetStopTimeout(d time.Duration) {
+	c.stopTimeoutNS.Store(int64(d))
 }
 
 // NewClient creates a Docker client using DOCKER_HOST (default unix socket).
@@ -36,16 +52,46 @@ func NewClient(ctx context.Context) (*Client, error) {
 	return &Client{cli: cli}, nil
 }
 
+// Endpoint is a named Docker daemon to connect to instead of DOCKER_HOST.
+type Endpoint struct {
+	// Name labels the client's log lines (host=<name>).
+	Name string
+	// Host is the daemon address, e.g. unix:///run/nas-docker.sock (an SSH-forwarded socket) or
+	// tcp://nas:2376.
+	Host string
+	// CertPath, if set, is a directory with ca.pem, cert.pem and key.pem for TLS, like
+	// DOCKER_CERT_PATH; the daemon's certificate is verified against ca.pem.
+	CertPath string
+}
+
+// NewEndpointClient creates a Docker client for e. Like NewClient it does not connect yet, so an
+// unreachable daemon only shows up as errors from the client's calls.
+func NewEndpointClient(ctx context.Context, e Endpoint) (*Client, error) {
+	opts := []client.Opt{client.WithHost(e.Host), client.WithAPIVersionNegotiation()}
+	if e.CertPath != "" {
+		opts = append(opts, client.WithTLSClientConfig(
+			filepath.Join(e.CertPath, "ca.pem"),
+			filepath.Join(e.CertPath, "cert.pem"),
+			filepath.Join(e.CertPath, "key.pem"),
+		))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %s: %w", e.Name, err)
+	}
+	return &Client{cli: cli, log: HostLogger(e.Name)}, nil
+}
+
 // ListContainers returns containers with their labels (name without leading /).
 // If all is false, only running containers are returned and State is not set.
 // If all is true, all containers are returned and State is set ("running", "exited", etc.).
-func (c *Client) ListContainers(ctx context.Context, all bool) ([]ContainerInfo, error) {
+func (c *Client) ListContainers(ctx context.Context, all bool) ([]engine.ContainerInfo, error) {
 	opts := container.ListOptions{All: all}
 	list, err := c.cli.ContainerList(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]ContainerInfo, 0, len(list))
+	out := make([]engine.ContainerInfo, 0, len(list))
 	for _, cnt := range list {
 		if len(cnt.Names) == 0 {
 			continue
@@ -54,7 +100,7 @@ func (c *Client) ListContainers(ctx context.Context, all bool) ([]ContainerInfo,
 		if len(name) > 0 && name[0] == '/' {
 			name = name[1:]
 		}
-		info := ContainerInfo{
+		info := engine.ContainerInfo{
 			ID:     cnt.ID,
 			Name:   name,
 			Labels: cnt.Labels,
@@ -67,24 +113,168 @@ func (c *Client) ListContainers(ctx context.Context, all bool) ([]ContainerInfo,
 	return out, nil
 }
 
-// Inspect returns health status and labels for a container by ID or name.
-// Health is "healthy", "unhealthy", "starting", or "" if no healthcheck.
-func (c *Client) Inspect(ctx context.Context, containerID string) (health string, labels map[string]string, err error) {
+// healthLogLimit is the number of most recent healthcheck results kept in engine.ContainerState.HealthLog.
+const healthLogLimit = 3
+
+// healthOutputLimit caps the bytes of healthcheck output kept per engine.HealthLogEntry.
+const healthOutputLimit = 512
+
+// Docker's defaults for unset healthcheck fields.
+const (
+	defaultHealthcheckInterval = 30 * time.Second
+	defaultHealthcheckTimeout  = 30 * time.Second
+	defaultHealthcheckRetries  = 3
+)
+
+// Inspect returns a state snapshot (health, labels, exit details, recent healthcheck results)
+// for a container by ID or name.
+func (c *Client) Inspect(ctx context.Context, containerID string) (engine.ContainerState, error) {
 	inspect, err := c.cli.ContainerInspect(ctx, containerID)
 	if err != nil {
-		return "", nil, err
+		return engine.ContainerState{}, err
 	}
-	labels = inspect.Config.Labels
-	if inspect.State.Health != nil {
-		health = inspect.State.Health.Status
+	var st engine.ContainerState
+	if inspect.Config != nil {
+		st.Labels = inspect.Config.Labels
+		st.Healthcheck = healthcheckConfig(inspect.Config.Healthcheck)
 	}
-	return health, labels, nil
+	if inspect.ContainerJSONBase != nil {
+		st.RestartCount = inspect.RestartCount
+		if inspect.HostConfig != nil {
+			st.NetworkMode = string(inspect.HostConfig.NetworkMode)
+		}
+	}
+	if inspect.ContainerJSONBase == nil || inspect.State == nil {
+		return st, nil
+	}
+	s := inspect.State
+	st.Status = s.Status
+	st.ExitCode = s.ExitCode
+	st.OOMKilled = s.OOMKilled
+	st.Error = s.Error
+	st.StartedAt = parseDockerTime(s.StartedAt)
+	st.FinishedAt = parseDockerTime(s.FinishedAt)
+	if s.Health != nil {
+		st.Health = s.Health.Status
+		logs := s.Health.Log
+		if len(logs) > healthLogLimit {
+			logs = logs[len(logs)-healthLogLimit:]
+		}
+		for _, r := range logs {
+			if r == nil {
+				continue
+			}
+			st.HealthLog = append(st.HealthLog, engine.HealthLogEntry{
+				Start:    r.Start,
+				End:      r.End,
+				ExitCode: r.ExitCode,
+				Output:   truncate(strings.TrimSpace(r.Output), healthOutputLimit),
+			})
+		}
+	}
+	return st, nil
+}
+
+// healthcheckConfig converts the inspected healthcheck to engine.HealthcheckConfig with Docker defaults
+// applied. Returns nil when there is no healthcheck or it is disabled (test "NONE").
+func healthcheckConfig(hc *container.HealthConfig) *engine.HealthcheckConfig {
+	if hc == nil || len(hc.Test) == 0 || hc.Test[0] == "NONE" {
+		return nil
+	}
+	out := &engine.HealthcheckConfig{
+		Interval:    hc.Interval,
+		Timeout:     hc.Timeout,
+		StartPeriod: hc.StartPeriod,
+		Retries:     hc.Retries,
+	}
+	if out.Interval <= 0 {
+		out.Interval = defaultHealthcheckInterval
+	}
+	if out.Timeout <= 0 {
+		out.Timeout = defaultHealthcheckTimeout
+	}
+	if out.Retries <= 0 {
+		out.Retries = defaultHealthcheckRetries
+	}
+	return out
+}
+
+// parseDockerTime parses an RFC 3339 timestamp from inspect. Docker reports
+// "0001-01-01T00:00:00Z" for never-set times; that and parse errors return the zero time.
+func parseDockerTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t
+}
+
+// truncate returns s cut to at most n bytes, with "..." appended when cut. It cuts before the
+// character that would cross n, so multi-byte UTF-8 output stays valid.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
 }
 
 // Restart restarts the container (idempotent).
 func (c *Client) Restart(ctx context.Context, containerID string) error {
+	return c.cli.ContainerRestart(ctx, containerID, container.StopOptions{Signal: "", Timeout: c.stopTimeout()})
+}
+
+// Stop stops the container by ID or name, waiting up to the stop timeout before killing it.
+func (c *Client) Stop(ctx context.Context, containerID string) error {
+	return c.cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: c.stopTimeout()})
+}
+
+// stopTimeout returns the stop timeout in whole seconds (rounded up) for the Engine API.
+func (c *Client) stopTimeout() *int {
 	timeout := 10
-	return c.cli.ContainerRestart(ctx, containerID, container.StopOptions{Signal: "", Timeout: &timeout})
+	if d := time.Duration(c.stopTimeoutNS.Load()); d > 0 {
+		timeout = int((d + time.Second - 1) / time.Second)
+	}
+	return &timeout
+}
+
+// Start starts a stopped container by ID or name (a no-op if it is already running).
+func (c *Client) Start(ctx context.Context, containerID string) error {
+	return c.cli.ContainerStart(ctx, containerID, container.StartOptions{})
+}
+
+// Logs returns the container's combined stdout and stderr with timestamps, limited to the
+// last tail lines (0 = all) and to output since the given time (zero = no limit). Multiplexed
+// streams (containers without a TTY) are demultiplexed so the result is plain text.
+func (c *Client) Logs(ctx context.Context, containerID string, tail int, since time.Time) ([]byte, error) {
+	inspect, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	opts := container.LogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true}
+	if tail > 0 {
+		opts.Tail = strconv.Itoa(tail)
+	}
+	if !since.IsZero() {
+		opts.Since = strconv.FormatInt(since.Unix(), 10)
+	}
+	rc, err := c.cli.ContainerLogs(ctx, containerID, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var buf bytes.Buffer
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(&buf, rc)
+	} else {
+		_, err = stdcopy.StdCopy(&buf, &buf, rc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // Close closes the underlying client.
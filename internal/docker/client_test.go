@@ -0,0 +1,240 @@
+package docker
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/docker/docker/api/types/container"
+
+	"watch-dog/internal/dockertest"
+	"watch-dog/internal/engine"
+)
+
+// newTestClient returns a client of a fake daemon serving containers on a unix socket.
+func newTestClient(t *testing.T, containers ...dockertest.Container) (*Client, *dockertest.Daemon) {
+	t.Helper()
+	d := dockertest.StartUnix(t, containers...)
+	c, err := NewEndpointClient(context.Background(), Endpoint{Name: "fake", Host: d.Host()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, d
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestClient_listContainers(t *testing.T) {
+	c, _ := newTestClient(t,
+		dockertest.Container{Name: "vpn", Labels: map[string]string{"com.docker.compose.service": "vpn"}},
+		dockertest.Container{Name: "old", Status: "exited"},
+	)
+	ctx := testContext(t)
+
+	running, err := c.ListContainers(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 1 || running[0].Name != "vpn" || running[0].ID != "vpn-id" ||
+		running[0].Labels["com.docker.compose.service"] != "vpn" || running[0].State != "" {
+		t.Errorf("running = %+v", running)
+	}
+	all, err := c.ListContainers(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[1].Name != "old" || all[1].State != "exited" {
+		t.Errorf("all = %+v", all)
+	}
+}
+
+func TestClient_inspect(t *testing.T) {
+	c, _ := newTestClient(t, dockertest.Container{
+		Name:        "vpn",
+		Health:      "unhealthy",
+		Healthcheck: &container.HealthConfig{Test: []string{"CMD", "ping", "-c1", "1.1.1.1"}, Interval: 5 * time.Second},
+		NetworkMode: "host",
+	})
+	ctx := testContext(t)
+
+	st, err := c.Inspect(ctx, "vpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "running" || st.Health != "unhealthy" || st.NetworkMode != "host" || st.StartedAt.IsZero() || !st.FinishedAt.IsZero() {
+		t.Errorf("state = %+v", st)
+	}
+	if st.Healthcheck == nil || st.Healthcheck.Interval != 5*time.Second || st.Healthcheck.Retries != defaultHealthcheckRetries {
+		t.Errorf("healthcheck = %+v, want interval 5s and default retries", st.Healthcheck)
+	}
+	if _, err := c.Inspect(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Errorf("inspect missing: err = %v", err)
+	}
+}
+
+func TestClient_restartAndEvents(t *testing.T) {
+	c, d := newTestClient(t, dockertest.Container{Name: "vpn", Health: "healthy"}, dockertest.Container{Name: "web"})
+	ctx := testContext(t)
+	all := make(chan engine.HealthEvent, 8)
+	c.SubscribeHealthStatus(ctx, all)
+	one := make(chan engine.HealthEvent, 8)
+	c.SubscribeContainer(ctx, "vpn-id", one)
+	if err := d.WaitSubscribers(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	d.SetHealth("vpn", "unhealthy")
+	if ev := <-all; ev.Status != engine.EventUnhealthy || ev.ContainerName != "vpn" || ev.ContainerID != "vpn-id" {
+		t.Errorf("event = %+v, want vpn unhealthy", ev)
+	}
+	if err := c.Restart(ctx, "vpn-id"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{engine.EventDie, engine.EventStop} {
+		if ev := <-all; ev.Status != want || ev.ContainerName != "vpn" {
+			t.Errorf("event = %+v, want %s of vpn", ev, want)
+		}
+	}
+	d.Kill("web", 137)
+	if ev := <-all; ev.Status != engine.EventDie || ev.ContainerName != "web" {
+		t.Errorf("event = %+v, want web die", ev)
+	}
+	d.SetHealth("vpn", "healthy")
+	for _, want := range []string{engine.EventUnhealthy, engine.EventDie, engine.EventHealthy} {
+		if ev := <-one; ev.Status != want || ev.ContainerName != "vpn" {
+			t.Errorf("container event = %+v, want %s of vpn", ev, want)
+		}
+	}
+	if got := d.Calls(); !slices.Equal(got, []string{"restart vpn"}) {
+		t.Errorf("calls = %v", got)
+	}
+
+	d.EndEvents()
+	for range all {
+	}
+}
+
+func TestClient_logs(t *testing.T) {
+	c, _ := newTestClient(t,
+		dockertest.Container{Name: "vpn", Logs: "one\ntwo\nthree\n"},
+		dockertest.Container{Name: "tty", Logs: "a\nb\n", Tty: true},
+	)
+	ctx := testContext(t)
+
+	out, err := c.Logs(ctx, "vpn", 2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "two\nthree\n" {
+		t.Errorf("logs = %q, want the last two lines", out)
+	}
+	out, err = c.Logs(ctx, "tty", 0, time.Time{})
+	if err != nil || string(out) != "a\nb\n" {
+		t.Errorf("tty logs = %q, %v", out, err)
+	}
+}
+
+func TestClient_recreate(t *testing.T) {
+	c, d := newTestClient(t,
+		dockertest.Container{Name: "vpn", Health: "healthy"},
+		dockertest.Container{Name: "torrent", Labels: map[string]string{"com.docker.compose.service": "torrent"}, NetworkMode: "container:old-vpn-id"},
+	)
+	ctx := testContext(t)
+
+	id, err := c.Recreate(ctx, "torrent-id", "container:vpn-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"stop torrent", "rename torrent", "create torrent", "start torrent", "remove torrent-watchdog-old"}; !slices.Equal(d.Calls(), want) {
+		t.Errorf("calls = %v, want %v", d.Calls(), want)
+	}
+	got, ok := d.Container("torrent")
+	if !ok || got.ID != id || got.Status != "running" || got.NetworkMode != "container:vpn-id" || got.Labels["com.docker.compose.service"] != "torrent" {
+		t.Errorf("recreated = %+v (id %s)", got, id)
+	}
+	if _, ok := d.Container("torrent-watchdog-old"); ok {
+		t.Error("replaced container not removed")
+	}
+}
+
+func TestClient_recreateRollsBack(t *testing.T) {
+	tests := []struct {
+		fail, wantErr string
+		want          []string
+	}{
+		{"create torrent", "create:", []string{"stop torrent", "rename torrent", "create torrent", "rename torrent-watchdog-old", "start torrent"}},
+		{"start torrent", "start:", []string{"stop torrent", "rename torrent", "create torrent", "start torrent", "remove torrent", "rename torrent-watchdog-old", "start torrent"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fail, func(t *testing.T) {
+			c, d := newTestClient(t, dockertest.Container{Name: "torrent", NetworkMode: "container:old-vpn-id"})
+			d.Fail(tt.fail)
+
+			_, err := c.Recreate(testContext(t), "torrent-id", "container:vpn-id")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want the %s error", err, tt.fail)
+			}
+			if !slices.Equal(d.Calls(), tt.want) {
+				t.Errorf("calls = %v, want %v", d.Calls(), tt.want)
+			}
+			got, ok := d.Container("torrent")
+			if !ok || got.ID != "torrent-id" || got.Status != "running" || got.NetworkMode != "container:old-vpn-id" {
+				t.Errorf("torrent = %+v, want the original container running again", got)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("é", healthOutputLimit) // 2 bytes each
+	tests := []struct {
+		name, s string
+		n       int
+		want    string
+	}{
+		{"short", "connection refused", 512, "connection refused"},
+		{"exact", "abc", 3, "abc"},
+		{"ascii", "abcdef", 3, "abc..."},
+		{"multi-byte boundary", "aéb", 2, "a..."},
+		{"multi-byte health log", long, healthOutputLimit, strings.Repeat("é", healthOutputLimit/2) + "..."},
+		{"four-byte rune", "🐳🐳", 5, "🐳..."},
+		{"empty", "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", tt.s, tt.n, got)
+			}
+		})
+	}
+}
+
+func TestParseDockerTime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2026-01-01T03:00:00.123456789Z", time.Date(2026, 1, 1, 3, 0, 0, 123456789, time.UTC)},
+		{"2026-01-01T04:00:00+01:00", time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{"0001-01-01T00:00:00Z", time.Time{}},
+		{"", time.Time{}},
+		{"yesterday", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseDockerTime(tt.s); !got.Equal(tt.want) {
+			t.Errorf("parseDockerTime(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
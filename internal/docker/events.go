@@ -1,29 +1,42 @@
-// Package docker provides a Docker API client for listing containers, inspecting
-// health status, and restarting containers, plus logging and health-status event subscription.
+// Package docker is the Docker Engine API backend of engine.Runtime: it lists containers, inspects
+// health status, restarts, stops, starts and recreates containers, reads their logs and subscribes
+// to health-status events. It also provides watch-dog's logging.
 package docker
 
 import (
 	"context"
+	"slices"
 
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+
+	"watch-dog/internal/engine"
 )
 
-// HealthEvent is emitted when a container's health status changes.
-type HealthEvent struct {
-	// ContainerID is the container ID.
-	ContainerID string
-	// ContainerName is the container name.
-	ContainerName string
-	// Status is the event action (e.g. "health_status: unhealthy").
-	Status string
+// SubscribeHealthStatus subscribes to Docker container events: health_status (unhealthy and
+// healthy), die, and stop. When a parent container goes unhealthy or stops, the event is sent to
+// the channel so recovery can run; when it becomes healthy, dependents held stopped can be started.
+// The context cancels the subscription. The channel is closed when the subscription ends.
+func (c *Client) SubscribeHealthStatus(ctx context.Context, out chan<- engine.HealthEvent) {
+	c.subscribe(ctx, newRecoveryEventFilter(), []events.Action{engine.EventUnhealthy, engine.EventHealthy, engine.EventDie, engine.EventStop}, out)
 }
 
-// SubscribeHealthStatus subscribes to Docker container events: health_status (unhealthy), die, and stop.
-// When a parent container goes unhealthy or stops, the event is sent to the channel so recovery can run.
-// The context cancels the subscription. The channel is closed when the subscription ends.
-func (c *Client) SubscribeHealthStatus(ctx context.Context, out chan<- HealthEvent) {
-	opts := events.ListOptions{Filters: newRecoveryEventFilter()}
+// SubscribeContainer subscribes to health_status (healthy and unhealthy) and die events for a
+// single container, e.g. to wait for it to become healthy after a restart. The context cancels
+// the subscription. The channel is closed when the subscription ends.
+func (c *Client) SubscribeContainer(ctx context.Context, containerID string, out chan<- engine.HealthEvent) {
+	f := filters.NewArgs()
+	f.Add("type", "container")
+	f.Add("container", containerID)
+	f.Add("event", "health_status")
+	f.Add("event", "die")
+	c.subscribe(ctx, f, []events.Action{engine.EventHealthy, engine.EventUnhealthy, engine.EventDie}, out)
+}
+
+// subscribe streams container events matching the filter and one of actions to out until ctx is
+// canceled or the stream fails; out is closed when the subscription ends.
+func (c *Client) subscribe(ctx context.Context, f filters.Args, actions []events.Action, out chan<- engine.HealthEvent) {
+	opts := events.ListOptions{Filters: f}
 	msgs, errs := c.cli.Events(ctx, opts)
 	go func() {
 		defer close(out)
@@ -32,8 +45,8 @@ func (c *Client) SubscribeHealthStatus(ctx context.Context, out chan<- HealthEve
 			case <-ctx.Done():
 				return
 			case err := <-errs:
-				if err != nil {
-					LogError("docker events", "error", err)
+				if err != nil && ctx.Err() == nil {
+					c.log.Error("docker events", "error", err)
 				}
 				return
 			case e, ok := <-msgs:
@@ -44,7 +57,11 @@ func (c *Client) SubscribeHealthStatus(ctx context.Context, out chan<- HealthEve
 					continue
 				}
 				action := e.Action
-				if action != "health_status: unhealthy" && action != "die" && action != "stop" {
+				// Podman's compat API reports the status in an attribute instead of the action.
+				if action == "health_status" && e.Actor.Attributes["health_status"] != "" {
+					action = events.Action("health_status: " + e.Actor.Attributes["health_status"])
+				}
+				if !slices.Contains(actions, action) {
 					continue
 				}
 				// For health_status the attribute is "health_status"; for die/stop use "name"
@@ -53,7 +70,7 @@ func (c *Client) SubscribeHealthStatus(ctx context.Context, out chan<- HealthEve
 					name = e.Actor.ID
 				}
 				select {
-				case out <- HealthEvent{
+				case out <- engine.HealthEvent{
 					ContainerID:   e.Actor.ID,
 					ContainerName: name,
 					Status:        string(action),
@@ -1,5 +1,6 @@
-// Package docker provides a Docker API client for listing containers, inspecting
-// health status, and restarting containers, plus logging and health-status event subscription.
+// Package docker is the Docker Engine API backend of engine.Runtime: it lists containers, inspects
+// health status, restarts, stops, starts and recreates containers, reads their logs and subscribes
+// to health-status events. It also provides watch-dog's logging.
 package docker
 
 import (
@@ -7,6 +8,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -16,25 +18,36 @@ func init() {
 	InitLogging()
 }
 
+// logLevel is the level of every handler installed by SetupLogging, so a level change also applies
+// to loggers derived from an earlier default logger.
+var logLevel slog.LevelVar
+
 // InitLogging reads LOG_LEVEL and LOG_FORMAT from the environment and sets the
-// default slog handler. Call at process startup before any log output.
-// LOG_LEVEL: DEBUG, INFO, WARN, ERROR (default INFO). Case-insensitive.
-// LOG_FORMAT: compact, timestamp, json (default timestamp). Case-insensitive.
+// default slog handler (see SetupLogging). It runs at package init so early output is
+// formatted; watch-dog calls SetupLogging again once its configuration is loaded.
 func InitLogging() {
-	level := levelFromEnv()
-	format := strings.TrimSpace(strings.ToLower(os.Getenv("LOG_FORMAT")))
-	switch format {
+	SetupLogging(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+}
+
+// SetupLogging sets the default slog handler. It may be called again at runtime (configuration
+// reload); the level changes for all loggers, the format for the default logger.
+// level: DEBUG, INFO, WARN, ERROR (default INFO). Case-insensitive.
+// format: compact, timestamp, json (default timestamp). Case-insensitive.
+func SetupLogging(level, format string) {
+	logLevel.Set(parseLevel(level))
+	opts := &slog.HandlerOptions{Level: &logLevel}
+	switch strings.TrimSpace(strings.ToLower(format)) {
 	case "compact":
-		slog.SetDefault(slog.New(newCompactHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+		slog.SetDefault(slog.New(newCompactHandler(os.Stdout, opts)))
 	case "json":
-		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, opts)))
 	default:
-		slog.SetDefault(slog.New(newTimestampHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+		slog.SetDefault(slog.New(newTimestampHandler(os.Stdout, opts)))
 	}
 }
 
-func levelFromEnv() slog.Level {
-	switch strings.TrimSpace(strings.ToUpper(os.Getenv("LOG_LEVEL"))) {
+func parseLevel(s string) slog.Level {
+	switch strings.TrimSpace(strings.ToUpper(s)) {
 	case "DEBUG":
 		return slog.LevelDebug
 	case "WARN":
@@ -248,3 +261,58 @@ func LogWarnRecovery(msg string, recoveryAttrs ...any) {
 func LogErrorRecovery(msg string, recoveryAttrs ...any) {
 	slog.Error(msg, slog.Group("recovery", recoveryAttrs...))
 }
+
+// Logger logs through the default slog logger like the Log functions above, adding its attrs to
+// every record, e.g. host=<endpoint> for messages about one Docker endpoint. A nil *Logger adds
+// nothing, so code without an endpoint can use it unchanged.
+type Logger struct {
+	attrs []any
+}
+
+// NewLogger returns a Logger that adds the key-value pairs attrs to every record.
+func NewLogger(attrs ...any) *Logger {
+	return &Logger{attrs: attrs}
+}
+
+// HostLogger returns a Logger labeling records with host=name, or nil when name is "" (the single
+// endpoint from DOCKER_HOST, whose logs stay unlabeled).
+func HostLogger(name string) *Logger {
+	if name == "" {
+		return nil
+	}
+	return NewLogger("host", name)
+}
+
+func (l *Logger) args(args []any) []any {
+	if l == nil || len(l.attrs) == 0 {
+		return args
+	}
+	return append(slices.Clip(l.attrs), args...)
+}
+
+// Debug logs a debug message with key-value pairs.
+func (l *Logger) Debug(msg string, args ...any) { slog.Debug(msg, l.args(args)...) }
+
+// Info logs an info message with key-value pairs.
+func (l *Logger) Info(msg string, args ...any) { slog.Info(msg, l.args(args)...) }
+
+// Warn logs a warning with key-value pairs.
+func (l *Logger) Warn(msg string, args ...any) { slog.Warn(msg, l.args(args)...) }
+
+// Error logs an error with key-value pairs.
+func (l *Logger) Error(msg string, args ...any) { slog.Error(msg, l.args(args)...) }
+
+// InfoRecovery is LogInfoRecovery with the Logger's attrs outside the "recovery" group.
+func (l *Logger) InfoRecovery(msg string, recoveryAttrs ...any) {
+	slog.Info(msg, l.args([]any{slog.Group("recovery", recoveryAttrs...)})...)
+}
+
+// WarnRecovery is LogWarnRecovery with the Logger's attrs outside the "recovery" group.
+func (l *Logger) WarnRecovery(msg string, recoveryAttrs ...any) {
+	slog.Warn(msg, l.args([]any{slog.Group("recovery", recoveryAttrs...)})...)
+}
+
+// ErrorRecovery is LogErrorRecovery with the Logger's attrs outside the "recovery" group.
+func (l *Logger) ErrorRecovery(msg string, recoveryAttrs ...any) {
+	slog.Error(msg, l.args([]any{slog.Group("recovery", recoveryAttrs...)})...)
+}
@@ -0,0 +1,78 @@
+// Package docker is the Docker Engine API backend of engine.Runtime: it lists containers, inspects
+// health status, restarts, stops, starts and recreates containers, reads their logs and subscribes
+// to health-status events. It also provides watch-dog's logging.
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// Recreate replaces the container with a new one of the same name and configuration, but with
+// the given network mode (e.g. "container:<parent id>" to rejoin a recreated parent's network
+// namespace, which a restart cannot do). The old container is stopped and renamed, the new one
+// is created and started, then the old one is removed. If creating or starting the new container
+// fails, the new one is removed and the old one is renamed back and, if it was running, started
+// again. Anonymous volumes are not carried over. Returns the new container ID.
+func (c *Client) Recreate(ctx context.Context, containerID, networkMode string) (string, error) {
+	old, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	if old.ContainerJSONBase == nil || old.Config == nil || old.HostConfig == nil {
+		return "", errors.New("incomplete container inspect")
+	}
+	name := strings.TrimPrefix(old.Name, "/")
+	cfg := *old.Config
+	hostCfg := *old.HostConfig
+	hostCfg.NetworkMode = container.NetworkMode(networkMode)
+	netCfg := &network.NetworkingConfig{}
+	if hostCfg.NetworkMode.IsContainer() {
+		// The daemon rejects these with a container network mode; they belong to the shared namespace.
+		cfg.Hostname, cfg.Domainname, cfg.ExposedPorts = "", "", nil
+		hostCfg.PortBindings, hostCfg.PublishAllPorts = nil, false
+		hostCfg.DNS, hostCfg.DNSOptions, hostCfg.DNSSearch, hostCfg.ExtraHosts = nil, nil, nil, nil
+	} else if old.NetworkSettings != nil {
+		netCfg.EndpointsConfig = old.NetworkSettings.Networks
+	}
+
+	wasRunning := old.State != nil && old.State.Running
+	if err := c.cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: c.stopTimeout()}); err != nil {
+		return "", fmt.Errorf("stop: %w", err)
+	}
+	oldName := name + "-watchdog-old"
+	if err := c.cli.ContainerRename(ctx, old.ID, oldName); err != nil {
+		return "", fmt.Errorf("rename: %w", err)
+	}
+	rollback := func(newID string) {
+		if newID != "" {
+			_ = c.cli.ContainerRemove(ctx, newID, container.RemoveOptions{Force: true})
+		}
+		if err := c.cli.ContainerRename(ctx, old.ID, name); err != nil {
+			c.log.Error("recreate: failed to restore original container name", "container", oldName, "name", name, "error", err)
+		}
+		if wasRunning {
+			if err := c.cli.ContainerStart(ctx, old.ID, container.StartOptions{}); err != nil {
+				c.log.Error("recreate: failed to start original container again", "container", old.ID, "error", err)
+			}
+		}
+	}
+	created, err := c.cli.ContainerCreate(ctx, &cfg, &hostCfg, netCfg, nil, name)
+	if err != nil {
+		rollback("")
+		return "", fmt.Errorf("create: %w", err)
+	}
+	if err := c.cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		rollback(created.ID)
+		return "", fmt.Errorf("start: %w", err)
+	}
+	if err := c.cli.ContainerRemove(ctx, old.ID, container.RemoveOptions{}); err != nil {
+		c.log.Warn("recreate: failed to remove replaced container", "container", oldName, "error", err)
+	}
+	return created.ID, nil
+}
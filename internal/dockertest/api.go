@@ -0,0 +1,361 @@
+package dockertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// routes registers the Engine API endpoints. The client pings the unversioned /_ping, then uses
+// versioned paths ("/v1.46/containers/json"); any version is accepted.
+func (d *Daemon) routes() {
+	mux := http.NewServeMux()
+	handle := func(method, path string, h http.HandlerFunc) {
+		mux.HandleFunc(method+" /{version}"+path, h)
+	}
+	mux.HandleFunc("/_ping", d.ping)
+	mux.HandleFunc("/{version}/_ping", d.ping)
+	handle("GET", "/containers/json", d.list)
+	handle("GET", "/containers/{id}/json", d.inspect)
+	handle("GET", "/containers/{id}/logs", d.logs)
+	handle("POST", "/containers/{id}/restart", d.restart)
+	handle("POST", "/containers/{id}/stop", d.stop)
+	handle("POST", "/containers/{id}/start", d.startContainer)
+	handle("POST", "/containers/{id}/rename", d.rename)
+	handle("POST", "/containers/create", d.create)
+	handle("DELETE", "/containers/{id}", d.remove)
+	handle("GET", "/events", d.events)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("dockertest: %s %s is not implemented", r.Method, r.URL.Path))
+	})
+	d.mux = mux
+}
+
+// writeJSON writes v with status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an Engine API error, which the client returns as an error with msg.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"message": msg})
+}
+
+func (d *Daemon) ping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("API-Version", APIVersion)
+	w.Header().Set("OSType", "linux")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write([]byte("OK"))
+	}
+}
+
+// lookup returns the container of the {id} path value, or writes a 404. It locks d.mu, which
+// the caller unlocks when c is not nil.
+func (d *Daemon) lookup(w http.ResponseWriter, r *http.Request) *Container {
+	id := r.PathValue("id")
+	d.mu.Lock()
+	c := d.find(id)
+	if c == nil {
+		d.mu.Unlock()
+		writeError(w, http.StatusNotFound, "No such container: "+id)
+	}
+	return c
+}
+
+func (d *Daemon) list(w http.ResponseWriter, r *http.Request) {
+	all := r.URL.Query().Get("all") == "1" || r.URL.Query().Get("all") == "true"
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := []types.Container{}
+	for _, c := range d.containers {
+		if !all && c.Status != "running" {
+			continue
+		}
+		out = append(out, types.Container{
+			ID:     c.ID,
+			Names:  []string{"/" + c.Name},
+			Image:  c.Image,
+			Labels: c.Labels,
+			State:  c.Status,
+			Status: c.Status,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (d *Daemon) inspect(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	defer d.mu.Unlock()
+	state := &types.ContainerState{
+		Status:     c.Status,
+		Running:    c.Status == "running",
+		ExitCode:   c.ExitCode,
+		OOMKilled:  c.OOMKilled,
+		StartedAt:  formatTime(c.startedAt),
+		FinishedAt: formatTime(c.finishedAt),
+	}
+	if hasHealthcheck(c) && c.Health != "" {
+		state.Health = &types.Health{Status: c.Health}
+	}
+	writeJSON(w, http.StatusOK, types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         c.ID,
+			Name:       "/" + c.Name,
+			Image:      c.Image,
+			State:      state,
+			HostConfig: &container.HostConfig{NetworkMode: container.NetworkMode(c.NetworkMode)},
+		},
+		Config: &container.Config{
+			Image:       c.Image,
+			Labels:      c.Labels,
+			Healthcheck: c.Healthcheck,
+			Tty:         c.Tty,
+		},
+		NetworkSettings: &types.NetworkSettings{},
+	})
+}
+
+// formatTime formats t like inspect, which reports never-set times as the zero time.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// hasHealthcheck reports whether c has an enabled healthcheck.
+func hasHealthcheck(c *Container) bool {
+	return c.Healthcheck != nil && len(c.Healthcheck.Test) > 0 && c.Healthcheck.Test[0] != "NONE"
+}
+
+func (d *Daemon) logs(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	d.record("logs", c)
+	lines, tty := strings.SplitAfter(c.Logs, "\n"), c.Tty
+	d.mu.Unlock()
+
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("tail")); err == nil && n >= 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+	w.WriteHeader(http.StatusOK)
+	var out io.Writer = w
+	if !tty {
+		out = stdcopy.NewStdWriter(w, stdcopy.Stdout)
+	}
+	for _, line := range lines {
+		out.Write([]byte(line))
+	}
+}
+
+func (d *Daemon) restart(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	d.record("restart", c)
+	if c.Status == "running" {
+		d.exit(c, 0)
+		d.emit(c, events.ActionStop, nil)
+	}
+	d.start(c)
+	d.emit(c, events.ActionRestart, nil)
+	name := c.Name
+	d.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	d.started(name)
+}
+
+func (d *Daemon) stop(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	defer d.mu.Unlock()
+	d.record("stop", c)
+	if c.Status != "running" {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	d.exit(c, 0)
+	d.emit(c, events.ActionStop, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Daemon) startContainer(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	if d.recordName("start", c.Name) {
+		d.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, "dockertest: failed to start "+c.Name)
+		return
+	}
+	if c.Status == "running" {
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	d.start(c)
+	name := c.Name
+	d.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	d.started(name)
+}
+
+func (d *Daemon) rename(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	defer d.mu.Unlock()
+	name := strings.TrimPrefix(r.URL.Query().Get("name"), "/")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "new name is required")
+		return
+	}
+	if other := d.find(name); other != nil && other != c {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Conflict. The container name %q is already in use by container %q.", "/"+name, other.ID))
+		return
+	}
+	d.record("rename", c)
+	old := c.Name
+	c.Name = name
+	d.emit(c, events.ActionRename, map[string]string{"oldName": "/" + old})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createRequest is the body of POST /containers/create.
+type createRequest struct {
+	container.Config
+	HostConfig       *container.HostConfig
+	NetworkingConfig *network.NetworkingConfig
+}
+
+func (d *Daemon) create(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid create request: "+err.Error())
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Query().Get("name"), "/")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if name != "" && d.find(name) != nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Conflict. The container name %q is already in use.", "/"+name))
+		return
+	}
+	id := d.newID()
+	if name == "" {
+		name = id[:12]
+	}
+	if d.recordName("create", name) {
+		writeError(w, http.StatusInternalServerError, "dockertest: failed to create "+name)
+		return
+	}
+	c := &Container{
+		ID:          id,
+		Name:        name,
+		Image:       req.Image,
+		Labels:      req.Labels,
+		Status:      "created",
+		Healthcheck: req.Healthcheck,
+		NetworkMode: "bridge",
+		Tty:         req.Tty,
+	}
+	if req.HostConfig != nil && req.HostConfig.NetworkMode != "" {
+		c.NetworkMode = string(req.HostConfig.NetworkMode)
+	}
+	d.containers = append(d.containers, c)
+	d.emit(c, events.ActionCreate, nil)
+	writeJSON(w, http.StatusCreated, container.CreateResponse{ID: c.ID, Warnings: []string{}})
+}
+
+func (d *Daemon) remove(w http.ResponseWriter, r *http.Request) {
+	c := d.lookup(w, r)
+	if c == nil {
+		return
+	}
+	defer d.mu.Unlock()
+	force := r.URL.Query().Get("force") == "1" || r.URL.Query().Get("force") == "true"
+	if c.Status == "running" && !force {
+		writeError(w, http.StatusConflict, fmt.Sprintf("You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.ID))
+		return
+	}
+	d.record("remove", c)
+	if c.Status == "running" {
+		d.exit(c, 137)
+	}
+	d.emit(c, events.ActionDestroy, nil)
+	for i, other := range d.containers {
+		if other == c {
+			d.containers = append(d.containers[:i], d.containers[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// events streams matching events as JSON until the client disconnects or EndEvents is called.
+func (d *Daemon) events(w http.ResponseWriter, r *http.Request) {
+	f, err := filters.FromJSON(r.URL.Query().Get("filters"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s := newSubscription(f)
+	d.mu.Lock()
+	d.subs[s] = true
+	d.notify()
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.subs, s)
+		d.notify()
+		d.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	enc := json.NewEncoder(w)
+	for {
+		msgs, ok := s.next(r.Context())
+		if !ok {
+			return
+		}
+		for _, m := range msgs {
+			if err := enc.Encode(m); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
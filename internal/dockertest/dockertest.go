@@ -0,0 +1,415 @@
+// Package dockertest simulates a Docker daemon for end-to-end tests. A Daemon serves the subset of
+// the Engine HTTP API watch-dog uses (ping; container list, inspect, restart, stop, start, create,
+// rename, remove and logs; events) over httptest (Start) or a unix socket (StartUnix), so the real
+// Docker client, discovery and the watch-dog binary can run against it. Tests add containers with
+// labels, status and health, script failures (SetHealth, Kill, Fail) and observe what the client did
+// (Calls, WaitCall). Containers send the events a daemon would for each change.
+package dockertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// APIVersion is the Engine API version the daemon reports in /_ping.
+const APIVersion = "1.46"
+
+// Container is a simulated container.
+type Container struct {
+	// ID is the container ID; Add sets it to "<name>-id" when empty.
+	ID string
+	// Name is the container name, without the leading slash.
+	Name string
+	// Image is the image name (default "<name>:latest").
+	Image string
+	// Labels are the container labels (e.g. com.docker.compose.service).
+	Labels map[string]string
+	// Status is "running" (the default), "created", "exited", etc.
+	Status string
+	// Health is "" for a container without healthcheck, or "starting", "healthy" or "unhealthy".
+	// A container with a health but no Healthcheck gets a default healthcheck.
+	Health string
+	// Healthcheck is the healthcheck configuration Inspect reports.
+	Healthcheck *container.HealthConfig
+	// ExitCode is the exit code of the last run.
+	ExitCode int
+	// OOMKilled marks the last run as killed by the OOM killer.
+	OOMKilled bool
+	// NetworkMode is the host config network mode (default "bridge").
+	NetworkMode string
+	// Tty makes Logs return a raw stream instead of a multiplexed one.
+	Tty bool
+	// Logs is the container output returned by the logs endpoint, one line per line.
+	Logs string
+
+	startedAt, finishedAt time.Time
+}
+
+// Daemon is a simulated Docker daemon. It is safe for concurrent use.
+type Daemon struct {
+	// OnStart, if set, is called after a container is started or restarted, without locks held,
+	// e.g. to make it healthy again: func(name string) { d.SetHealth(name, "healthy") }.
+	OnStart func(name string)
+
+	mu         sync.Mutex
+	containers []*Container
+	subs       map[*subscription]bool
+	calls      []string
+	failures   map[string]bool
+	nextID     int
+	// changed is closed and replaced whenever calls or subs change (see wait).
+	changed chan struct{}
+
+	mux  *http.ServeMux
+	host string
+}
+
+// New returns a daemon with the given containers that is not served yet; use it as an
+// http.Handler, or call Start or StartUnix instead.
+func New(containers ...Container) *Daemon {
+	d := &Daemon{subs: make(map[*subscription]bool), changed: make(chan struct{})}
+	for _, c := range containers {
+		d.Add(c)
+	}
+	d.routes()
+	return d
+}
+
+// Start serves a new daemon with the given containers over httptest until the test ends. Host
+// returns its tcp:// address.
+func Start(t testing.TB, containers ...Container) *Daemon {
+	d := New(containers...)
+	srv := httptest.NewServer(d)
+	d.host = "tcp://" + srv.Listener.Addr().String()
+	t.Cleanup(func() {
+		d.EndEvents()
+		srv.Close()
+	})
+	return d
+}
+
+// StartUnix serves a new daemon with the given containers on a unix socket in a temporary
+// directory until the test ends. Host returns its unix:// address.
+func StartUnix(t testing.TB, containers ...Container) *Daemon {
+	d := New(containers...)
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("dockertest: listen: %v", err)
+	}
+	srv := &http.Server{Handler: d}
+	go srv.Serve(l)
+	d.host = "unix://" + sock
+	t.Cleanup(func() {
+		d.EndEvents()
+		srv.Close()
+	})
+	return d
+}
+
+// Host returns the daemon address for DOCKER_HOST ("" when not started).
+func (d *Daemon) Host() string {
+	return d.host
+}
+
+// ServeHTTP serves the Engine API.
+func (d *Daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+// Add adds a container.
+func (d *Daemon) Add(c Container) {
+	if c.ID == "" {
+		c.ID = c.Name + "-id"
+	}
+	if c.Image == "" {
+		c.Image = c.Name + ":latest"
+	}
+	if c.Status == "" {
+		c.Status = "running"
+	}
+	if c.NetworkMode == "" {
+		c.NetworkMode = "bridge"
+	}
+	if c.Health != "" && c.Healthcheck == nil {
+		c.Healthcheck = &container.HealthConfig{Test: []string{"CMD", "true"}}
+	}
+	if c.Status == "running" {
+		c.startedAt = time.Now()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.containers = append(d.containers, &c)
+}
+
+// Container returns a copy of the named container.
+func (d *Daemon) Container(name string) (Container, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.find(name)
+	if c == nil {
+		return Container{}, false
+	}
+	return *c, true
+}
+
+// SetHealth sets the named container's health and sends its health_status event.
+func (d *Daemon) SetHealth(name, health string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.mustFind(name)
+	c.Health = health
+	d.emit(c, events.Action("health_status: "+health), nil)
+}
+
+// Kill makes the named container exit with exitCode and sends the die event.
+func (d *Daemon) Kill(name string, exitCode int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.mustFind(name)
+	d.exit(c, exitCode)
+}
+
+// EndEvents ends all event streams, as when the daemon restarts.
+func (d *Daemon) EndEvents() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for s := range d.subs {
+		s.stop()
+	}
+}
+
+// Fail makes the next call (e.g. "start torrent") fail with a server error, like a daemon that
+// cannot create or start the container. The failed call is still recorded. Only create and start
+// calls can fail.
+func (d *Daemon) Fail(call string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failures == nil {
+		d.failures = make(map[string]bool)
+	}
+	d.failures[call] = true
+}
+
+// Calls returns the container API calls made so far, in order, as "<operation> <name>" with
+// operation one of restart, stop, start, create, rename, remove and logs. List, inspect and
+// events are not recorded.
+func (d *Daemon) Calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.calls)
+}
+
+// WaitCall waits until call (e.g. "restart vpn") has been made, or returns ctx's error.
+func (d *Daemon) WaitCall(ctx context.Context, call string) error {
+	return d.wait(ctx, func() bool { return slices.Contains(d.calls, call) })
+}
+
+// WaitSubscribers waits until at least n event streams are open, so events sent afterwards are
+// seen, or returns ctx's error.
+func (d *Daemon) WaitSubscribers(ctx context.Context, n int) error {
+	return d.wait(ctx, func() bool { return len(d.subs) >= n })
+}
+
+// wait waits until cond, called with d.mu held, is true.
+func (d *Daemon) wait(ctx context.Context, cond func() bool) error {
+	for {
+		d.mu.Lock()
+		ok, changed := cond(), d.changed
+		d.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up wait. Caller holds d.mu.
+func (d *Daemon) notify() {
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// find returns the container with the given ID or name, or nil. Caller holds d.mu.
+func (d *Daemon) find(idOrName string) *Container {
+	for _, c := range d.containers {
+		if c.ID == idOrName || c.Name == idOrName {
+			return c
+		}
+	}
+	return nil
+}
+
+func (d *Daemon) mustFind(name string) *Container {
+	c := d.find(name)
+	if c == nil {
+		panic("dockertest: no container " + name)
+	}
+	return c
+}
+
+// record records a call. Caller holds d.mu.
+func (d *Daemon) record(op string, c *Container) {
+	d.recordName(op, c.Name)
+}
+
+// recordName records a call on the container called name and reports whether it is to fail (see
+// Fail). Caller holds d.mu.
+func (d *Daemon) recordName(op, name string) (fail bool) {
+	call := op + " " + name
+	d.calls = append(d.calls, call)
+	d.notify()
+	fail = d.failures[call]
+	delete(d.failures, call)
+	return fail
+}
+
+// start marks c running (health "starting" with a healthcheck) and sends start. Caller holds d.mu.
+func (d *Daemon) start(c *Container) {
+	c.Status, c.ExitCode, c.OOMKilled = "running", 0, false
+	c.startedAt = time.Now()
+	if hasHealthcheck(c) {
+		c.Health = "starting"
+	}
+	d.emit(c, events.ActionStart, nil)
+}
+
+// exit marks c exited with exitCode and sends die. Caller holds d.mu.
+func (d *Daemon) exit(c *Container, exitCode int) {
+	c.Status, c.ExitCode = "exited", exitCode
+	c.finishedAt = time.Now()
+	if hasHealthcheck(c) {
+		c.Health = "unhealthy"
+	}
+	d.emit(c, events.ActionDie, map[string]string{"exitCode": strconv.Itoa(exitCode)})
+}
+
+// started calls OnStart, if set. Caller must not hold d.mu.
+func (d *Daemon) started(name string) {
+	if d.OnStart != nil {
+		d.OnStart(name)
+	}
+}
+
+// emit sends a container event to every stream whose filter matches. Attributes are the
+// container's labels, name and image plus extra, like the daemon's. Caller holds d.mu.
+func (d *Daemon) emit(c *Container, action events.Action, extra map[string]string) {
+	attrs := map[string]string{"name": c.Name, "image": c.Image}
+	for k, v := range c.Labels {
+		attrs[k] = v
+	}
+	for k, v := range extra {
+		attrs[k] = v
+	}
+	now := time.Now()
+	msg := events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: c.ID, Attributes: attrs},
+		Scope:    "local",
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+	for s := range d.subs {
+		if s.matches(msg) {
+			s.send(msg)
+		}
+	}
+}
+
+// newID returns a new 64-hex-digit container ID. Caller holds d.mu.
+func (d *Daemon) newID() string {
+	d.nextID++
+	return fmt.Sprintf("%064x", d.nextID)
+}
+
+// subscription is one event stream. Events are queued so emitting never blocks.
+type subscription struct {
+	filter filters.Args
+
+	mu    sync.Mutex
+	queue []events.Message
+	wake  chan struct{}
+	end   chan struct{}
+	ended bool
+}
+
+func newSubscription(f filters.Args) *subscription {
+	return &subscription{filter: f, wake: make(chan struct{}, 1), end: make(chan struct{})}
+}
+
+// matches applies the type, event and container filters like the daemon: an event filter
+// "health_status" matches the action "health_status: unhealthy".
+func (s *subscription) matches(m events.Message) bool {
+	if s.filter.Contains("type") && !s.filter.ExactMatch("type", string(m.Type)) {
+		return false
+	}
+	if s.filter.Contains("event") {
+		action := string(m.Action)
+		prefix, _, _ := strings.Cut(action, ":")
+		if !s.filter.ExactMatch("event", action) && !s.filter.ExactMatch("event", prefix) {
+			return false
+		}
+	}
+	if s.filter.Contains("container") && !s.filter.ExactMatch("container", m.Actor.ID) && !s.filter.ExactMatch("container", m.Actor.Attributes["name"]) {
+		return false
+	}
+	return true
+}
+
+func (s *subscription) send(m events.Message) {
+	s.mu.Lock()
+	s.queue = append(s.queue, m)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.ended = true
+		close(s.end)
+	}
+}
+
+// next returns the queued events, waiting for one if there are none. ok is false when ctx is
+// done or the subscription was stopped.
+func (s *subscription) next(ctx context.Context) (msgs []events.Message, ok bool) {
+	for {
+		s.mu.Lock()
+		msgs, s.queue = s.queue, nil
+		s.mu.Unlock()
+		if len(msgs) > 0 {
+			return msgs, true
+		}
+		select {
+		case <-s.wake:
+		case <-ctx.Done():
+			return nil, false
+		case <-s.end:
+			return nil, false
+		}
+	}
+}
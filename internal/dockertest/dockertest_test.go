@@ -0,0 +1,80 @@
+package dockertest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+func TestDaemon_eventFilters(t *testing.T) {
+	d := Start(t, Container{Name: "vpn", Health: "healthy"}, Container{Name: "web"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := filters.NewArgs(filters.Arg("type", "container"), filters.Arg("event", "health_status"), filters.Arg("container", "vpn"))
+	q := url.Values{"filters": {mustJSON(t, f)}}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://"+strings.TrimPrefix(d.Host(), "tcp://")+"/v1.46/events?"+q.Encode(), nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := d.WaitSubscribers(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	d.SetHealth("web", "unhealthy")
+	d.Kill("vpn", 1)
+	d.SetHealth("vpn", "unhealthy")
+	var m events.Message
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Action != "health_status: unhealthy" || m.Actor.ID != "vpn-id" || m.Actor.Attributes["name"] != "vpn" {
+		t.Errorf("event = %+v, want only vpn's health_status", m)
+	}
+}
+
+func TestDaemon_errors(t *testing.T) {
+	d := Start(t, Container{Name: "vpn"}, Container{Name: "web"})
+	base := "http://" + strings.TrimPrefix(d.Host(), "tcp://") + "/v1.46"
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{"GET", "/containers/missing/json", http.StatusNotFound},
+		{"POST", "/containers/vpn/rename?name=web", http.StatusConflict},
+		{"DELETE", "/containers/vpn", http.StatusConflict},
+		{"POST", "/containers/vpn/start", http.StatusNotModified},
+		{"POST", "/images/create", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(tc.method, base+tc.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.code {
+			t.Errorf("%s %s = %d, want %d", tc.method, tc.path, resp.StatusCode, tc.code)
+		}
+	}
+	if c, _ := d.Container("vpn"); c.Status != "running" {
+		t.Errorf("vpn = %+v, want still running", c)
+	}
+}
+
+func mustJSON(t *testing.T, f filters.Args) string {
+	t.Helper()
+	s, err := filters.ToJSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
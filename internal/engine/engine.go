@@ -0,0 +1,186 @@
+// Package engine defines the container runtime watch-dog supervises: the Runtime interface
+// (list, inspect, restart, stop/start, recreate, events and logs) and the container data it
+// returns. The Docker Engine API client (internal/docker) is the main backend; Podman is served
+// through its Docker-compatible API (internal/podman), and enginetest provides an in-memory fake.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Runtime is a container runtime backend. Containers are addressed by ID or name.
+type Runtime interface {
+	// ListContainers returns containers with their labels. If all is false, only running
+	// containers are returned and State is not set.
+	ListContainers(ctx context.Context, all bool) ([]ContainerInfo, error)
+	// Inspect returns a state snapshot of a container.
+	Inspect(ctx context.Context, containerID string) (ContainerState, error)
+	// Restart restarts a container, letting it stop within the stop timeout before it is killed.
+	Restart(ctx context.Context, containerID string) error
+	// Stop stops a container within the stop timeout.
+	Stop(ctx context.Context, containerID string) error
+	// Start starts a stopped container (a no-op if it is already running).
+	Start(ctx context.Context, containerID string) error
+	// Recreate replaces a container with one of the same name and configuration but the given
+	// network mode, and returns the new container's ID.
+	Recreate(ctx context.Context, containerID, networkMode string) (string, error)
+	// Logs returns a container's combined stdout and stderr, limited to the last tail lines
+	// (0 = all) and to output since the given time (zero = no limit).
+	Logs(ctx context.Context, containerID string, tail int, since time.Time) ([]byte, error)
+	// SubscribeHealthStatus sends EventUnhealthy, EventHealthy, EventDie and EventStop events of
+	// all containers to out until ctx is canceled or the stream fails; out is closed when the subscription ends.
+	SubscribeHealthStatus(ctx context.Context, out chan<- HealthEvent)
+	// SubscribeContainer sends EventHealthy, EventUnhealthy and EventDie events of one container
+	// to out until ctx is canceled or the stream fails; out is closed when the subscription ends.
+	SubscribeContainer(ctx context.Context, containerID string, out chan<- HealthEvent)
+	// SetStopTimeout sets how long Restart, Stop and Recreate let a container stop before it is
+	// killed (zero restores the backend default). Safe to call concurrently.
+	SetStopTimeout(d time.Duration)
+	// Close releases the backend's connections.
+	Close() error
+}
+
+// Event statuses in HealthEvent.Status, named like the Docker event actions.
+const (
+	EventHealthy   = "health_status: healthy"
+	EventUnhealthy = "health_status: unhealthy"
+	EventDie       = "die"
+	EventStop      = "stop"
+)
+
+// HealthEvent is emitted when a container's health status changes or it stops.
+type HealthEvent struct {
+	// ContainerID is the container ID.
+	ContainerID string
+	// ContainerName is the container name.
+	ContainerName string
+	// Status is the event action (e.g. EventUnhealthy).
+	Status string
+}
+
+// ContainerInfo holds minimal container data for discovery.
+type ContainerInfo struct {
+	// ID is the container ID.
+	ID string
+	// Name is the container name (without leading slash).
+	Name string
+	// Labels holds container labels (e.g. com.docker.compose.service).
+	Labels map[string]string
+	// State is "running", "exited", etc.; only set when ListContainers is called with all=true.
+	State string
+}
+
+// ContainerState is a point-in-time snapshot of a container from inspect. Besides health and
+// labels it carries the exit and healthcheck details used to explain why recovery was triggered.
+type ContainerState struct {
+	// Health is "healthy", "unhealthy", "starting", or "" if no healthcheck.
+	Health string `json:"health,omitempty"`
+	// Labels holds container labels from the container config.
+	Labels map[string]string `json:"-"`
+	// Status is "running", "exited", "restarting", etc.
+	Status string `json:"status,omitempty"`
+	// ExitCode is the exit code of the last run (0 while running).
+	ExitCode int `json:"exit_code"`
+	// OOMKilled is true when the last run was killed by the kernel OOM killer.
+	OOMKilled bool `json:"oom_killed"`
+	// Error is the daemon-reported error from the last start, if any.
+	Error string `json:"error,omitempty"`
+	// RestartCount is the number of restarts performed by the daemon's restart policy.
+	RestartCount int `json:"restart_count"`
+	// StartedAt is when the container was last started (zero if unknown).
+	StartedAt time.Time `json:"started_at,omitzero"`
+	// FinishedAt is when the container last exited (zero if unknown or never exited).
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	// HealthLog holds the last healthcheck results, oldest first.
+	HealthLog []HealthLogEntry `json:"health_log,omitempty"`
+	// Healthcheck is the container's healthcheck configuration, or nil if it has none (or it is disabled).
+	Healthcheck *HealthcheckConfig `json:"healthcheck,omitempty"`
+	// NetworkMode is the container's network mode, e.g. "bridge" or "container:<id>" when it shares
+	// another container's network namespace.
+	NetworkMode string `json:"network_mode,omitempty"`
+}
+
+// HealthcheckConfig is a container's effective healthcheck timing. Backends replace unset values
+// with the runtime's defaults (for Docker: interval 30s, timeout 30s, retries 3).
+type HealthcheckConfig struct {
+	// Interval is the time between checks.
+	Interval time.Duration `json:"interval"`
+	// Timeout is the maximum time one check may take.
+	Timeout time.Duration `json:"timeout"`
+	// StartPeriod is the grace period after start during which failures do not count.
+	StartPeriod time.Duration `json:"start_period"`
+	// Retries is the number of consecutive failures before the container is unhealthy.
+	Retries int `json:"retries"`
+}
+
+// HealthLogEntry is a single healthcheck result from State.Health.Log.
+type HealthLogEntry struct {
+	// Start is when the check started.
+	Start time.Time `json:"start"`
+	// End is when the check finished.
+	End time.Time `json:"end"`
+	// ExitCode is the check command's exit code (0 = healthy).
+	ExitCode int `json:"exit_code"`
+	// Output is the check command's output, trimmed and truncated to healthOutputLimit bytes.
+	Output string `json:"output"`
+}
+
+// LastHealthOutput returns the output of the most recent healthcheck, or "" if none.
+func (s ContainerState) LastHealthOutput() string {
+	if len(s.HealthLog) == 0 {
+		return ""
+	}
+	return s.HealthLog[len(s.HealthLog)-1].Output
+}
+
+// Summary returns a short human-readable description of the state for log messages,
+// e.g. "exit code 137, OOM killed" or "last healthcheck exit 1: connection refused".
+func (s ContainerState) Summary() string {
+	var parts []string
+	if s.Status != "" && s.Status != "running" {
+		parts = append(parts, fmt.Sprintf("exit code %d", s.ExitCode))
+	}
+	if s.OOMKilled {
+		parts = append(parts, "OOM killed")
+	}
+	if s.Error != "" {
+		parts = append(parts, "error: "+s.Error)
+	}
+	if n := len(s.HealthLog); n > 0 && s.Health != "healthy" {
+		last := s.HealthLog[n-1]
+		if last.ExitCode != 0 {
+			parts = append(parts, fmt.Sprintf("last healthcheck exit %d: %s", last.ExitCode, last.Output))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LogAttrs returns the snapshot as slog attrs grouped under "state", for recovery logs.
+func (s ContainerState) LogAttrs() slog.Attr {
+	attrs := []any{
+		"status", s.Status,
+		"exit_code", s.ExitCode,
+		"oom_killed", s.OOMKilled,
+		"restart_count", s.RestartCount,
+	}
+	if s.Error != "" {
+		attrs = append(attrs, "error", s.Error)
+	}
+	if !s.StartedAt.IsZero() {
+		attrs = append(attrs, "started_at", s.StartedAt.Format(time.RFC3339))
+	}
+	if !s.FinishedAt.IsZero() {
+		attrs = append(attrs, "finished_at", s.FinishedAt.Format(time.RFC3339))
+	}
+	if s.Health != "" {
+		attrs = append(attrs, "health", s.Health)
+	}
+	if out := s.LastHealthOutput(); out != "" {
+		attrs = append(attrs, "last_health_output", out)
+	}
+	return slog.Group("state", attrs...)
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContainerState_Summary(t *testing.T) {
+	failing := []HealthLogEntry{{ExitCode: 0, Output: "ok"}, {ExitCode: 1, Output: "connexion refusée: délai dépassé"}}
+	tests := []struct {
+		name  string
+		state ContainerState
+		want  string
+	}{
+		{"running", ContainerState{Status: "running"}, ""},
+		{"exit code", ContainerState{Status: "exited", ExitCode: 1}, "exit code 1"},
+		{"OOM", ContainerState{Status: "exited", ExitCode: 137, OOMKilled: true}, "exit code 137, OOM killed"},
+		{"error", ContainerState{Status: "dead", ExitCode: 128, Error: "no such device"}, "exit code 128, error: no such device"},
+		{"unhealthy", ContainerState{Status: "running", Health: "unhealthy", HealthLog: failing}, "last healthcheck exit 1: connexion refusée: délai dépassé"},
+		{"healthy again", ContainerState{Status: "running", Health: "healthy", HealthLog: failing}, ""},
+		{"last check passed", ContainerState{Status: "running", Health: "unhealthy", HealthLog: failing[:1]}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.Summary(); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerState_LogAttrs(t *testing.T) {
+	started := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	output := strings.Repeat("échec ", 100)
+	tests := []struct {
+		name  string
+		state ContainerState
+		want  map[string]string
+	}{
+		{
+			name:  "running",
+			state: ContainerState{Status: "running", RestartCount: 2},
+			want:  map[string]string{"status": "running", "exit_code": "0", "oom_killed": "false", "restart_count": "2"},
+		},
+		{
+			name:  "OOM killed",
+			state: ContainerState{Status: "exited", ExitCode: 137, OOMKilled: true, StartedAt: started, FinishedAt: started.Add(time.Hour)},
+			want: map[string]string{
+				"status": "exited", "exit_code": "137", "oom_killed": "true", "restart_count": "0",
+				"started_at": "2026-01-01T03:00:00Z", "finished_at": "2026-01-01T04:00:00Z",
+			},
+		},
+		{
+			name:  "unhealthy",
+			state: ContainerState{Status: "running", Health: "unhealthy", Error: "oops", HealthLog: []HealthLogEntry{{ExitCode: 1, Output: output}}},
+			want: map[string]string{
+				"status": "running", "exit_code": "0", "oom_killed": "false", "restart_count": "0",
+				"error": "oops", "health": "unhealthy", "last_health_output": output,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attr := tt.state.LogAttrs()
+			if attr.Key != "state" || attr.Value.Kind() != slog.KindGroup {
+				t.Fatalf("LogAttrs() = %v, want a state group", attr)
+			}
+			got := make(map[string]string)
+			for _, a := range attr.Value.Group() {
+				got[a.Key] = a.Value.String()
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("LogAttrs() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
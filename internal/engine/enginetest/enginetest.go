@@ -0,0 +1,444 @@
+// Package enginetest provides an in-memory engine.Runtime for tests. Tests add containers with
+// labels, status and health, script failures (SetHealth, Kill) and observe what watch-dog did
+// (Calls, WaitCall). Restart, Stop and Kill send the events a Docker daemon would, so code driven
+// by SubscribeHealthStatus and SubscribeContainer can be tested without a daemon.
+package enginetest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"watch-dog/internal/engine"
+)
+
+// Container is a simulated container.
+type Container struct {
+	// ID is the container ID; New and Add set it to "<name>-id" when empty.
+	ID string
+	// Name is the container name.
+	Name string
+	// Labels are the container labels (e.g. com.docker.compose.service).
+	Labels map[string]string
+	// Status is "running" (the default), "exited", etc.
+	Status string
+	// Health is "" for a container without healthcheck, or "starting", "healthy" or "unhealthy".
+	Health string
+	// Healthcheck is the healthcheck timing Inspect reports (nil: none).
+	Healthcheck *engine.HealthcheckConfig
+	// ExitCode is the exit code of the last run.
+	ExitCode int
+	// NetworkMode is the network mode Inspect reports.
+	NetworkMode string
+	// Logs is what Logs returns.
+	Logs string
+}
+
+// Service returns a running container of the compose service name (labelled as compose labels
+// it), with health as in Container.Health.
+func Service(name, health string) Container {
+	return Container{Name: name, Health: health, Labels: map[string]string{"com.docker.compose.service": name}}
+}
+
+// Runtime is an in-memory engine.Runtime. It is safe for concurrent use.
+type Runtime struct {
+	// OnStart, if set, is called after a container is restarted, started or recreated, without
+	// locks held, e.g. to make it healthy again: func(name string) { rt.SetHealth(name, "healthy") }.
+	OnStart func(name string)
+
+	mu          sync.Mutex
+	containers  []*Container
+	subs        map[*subscription]bool
+	calls       []string
+	stopTimeout time.Duration
+	// changed is closed and replaced whenever calls or subs change (see wait).
+	changed chan struct{}
+}
+
+var _ engine.Runtime = (*Runtime)(nil)
+
+// New returns a runtime with the given containers.
+func New(containers ...Container) *Runtime {
+	r := &Runtime{subs: make(map[*subscription]bool), changed: make(chan struct{})}
+	for _, c := range containers {
+		r.Add(c)
+	}
+	return r
+}
+
+// Add adds a container.
+func (r *Runtime) Add(c Container) {
+	if c.ID == "" {
+		c.ID = c.Name + "-id"
+	}
+	if c.Status == "" {
+		c.Status = "running"
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.containers = append(r.containers, &c)
+}
+
+// Container returns a copy of the named container.
+func (r *Runtime) Container(name string) (Container, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.find(name)
+	if c == nil {
+		return Container{}, false
+	}
+	return *c, true
+}
+
+// SetHealth sets the named container's health and sends the health_status event.
+func (r *Runtime) SetHealth(name, health string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.find(name)
+	if c == nil {
+		panic("enginetest: no container " + name)
+	}
+	c.Health = health
+	r.emit(c, "health_status: "+health)
+}
+
+// Kill makes the named container exit with exitCode and sends the die event.
+func (r *Runtime) Kill(name string, exitCode int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.find(name)
+	if c == nil {
+		panic("enginetest: no container " + name)
+	}
+	c.Status, c.ExitCode = "exited", exitCode
+	r.emit(c, engine.EventDie)
+}
+
+// EndEvents ends all event subscriptions, as when the daemon restarts.
+func (r *Runtime) EndEvents() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for s := range r.subs {
+		s.stop()
+	}
+}
+
+// Calls returns the calls made so far, in order, as "<method> <container name>" with method one
+// of restart, stop, start, recreate and logs.
+func (r *Runtime) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// WaitCall waits until call (e.g. "restart vpn") has been made, or returns ctx's error.
+func (r *Runtime) WaitCall(ctx context.Context, call string) error {
+	return r.wait(ctx, func() bool { return slices.Contains(r.calls, call) })
+}
+
+// WaitSubscribers waits until at least n event subscriptions (SubscribeHealthStatus or
+// SubscribeContainer) are active, so events sent afterwards are seen, or returns ctx's error.
+func (r *Runtime) WaitSubscribers(ctx context.Context, n int) error {
+	return r.wait(ctx, func() bool { return len(r.subs) >= n })
+}
+
+// StopTimeout returns the stop timeout last set with SetStopTimeout.
+func (r *Runtime) StopTimeout() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopTimeout
+}
+
+// wait waits until cond, called with r.mu held, is true.
+func (r *Runtime) wait(ctx context.Context, cond func() bool) error {
+	for {
+		r.mu.Lock()
+		ok, changed := cond(), r.changed
+		r.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up wait. Caller holds r.mu.
+func (r *Runtime) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// find returns the container with the given ID or name, or nil. Caller holds r.mu.
+func (r *Runtime) find(idOrName string) *Container {
+	for _, c := range r.containers {
+		if c.ID == idOrName || c.Name == idOrName {
+			return c
+		}
+	}
+	return nil
+}
+
+// call records a call on the container with the given ID or name. Caller holds r.mu.
+func (r *Runtime) call(method, idOrName string) (*Container, error) {
+	c := r.find(idOrName)
+	if c == nil {
+		return nil, fmt.Errorf("no such container: %s", idOrName)
+	}
+	r.calls = append(r.calls, method+" "+c.Name)
+	r.notify()
+	return c, nil
+}
+
+// emit queues the event for every subscription that wants it. Caller holds r.mu.
+func (r *Runtime) emit(c *Container, status string) {
+	ev := engine.HealthEvent{ContainerID: c.ID, ContainerName: c.Name, Status: status}
+	for s := range r.subs {
+		if s.wants(c, status) {
+			s.send(ev)
+		}
+	}
+}
+
+// started marks c running after a restart or start. Caller holds r.mu.
+func started(c *Container) {
+	c.Status, c.ExitCode = "running", 0
+	if c.Health != "" {
+		c.Health = "starting"
+	}
+}
+
+// ListContainers implements engine.Runtime.
+func (r *Runtime) ListContainers(ctx context.Context, all bool) ([]engine.ContainerInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []engine.ContainerInfo
+	for _, c := range r.containers {
+		if !all && c.Status != "running" {
+			continue
+		}
+		info := engine.ContainerInfo{ID: c.ID, Name: c.Name, Labels: c.Labels}
+		if all {
+			info.State = c.Status
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// Inspect implements engine.Runtime.
+func (r *Runtime) Inspect(ctx context.Context, containerID string) (engine.ContainerState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.find(containerID)
+	if c == nil {
+		return engine.ContainerState{}, fmt.Errorf("no such container: %s", containerID)
+	}
+	return engine.ContainerState{
+		Health:      c.Health,
+		Labels:      c.Labels,
+		Status:      c.Status,
+		ExitCode:    c.ExitCode,
+		Healthcheck: c.Healthcheck,
+		NetworkMode: c.NetworkMode,
+	}, nil
+}
+
+// Restart implements engine.Runtime: the container sends die and stop, then runs again (health
+// "starting" if it has a healthcheck) and OnStart is called.
+func (r *Runtime) Restart(ctx context.Context, containerID string) error {
+	r.mu.Lock()
+	c, err := r.call("restart", containerID)
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.emit(c, engine.EventDie)
+	r.emit(c, engine.EventStop)
+	started(c)
+	name := c.Name
+	r.mu.Unlock()
+	r.started(name)
+	return nil
+}
+
+// Stop implements engine.Runtime: the container exits with code 0 and sends die and stop.
+func (r *Runtime) Stop(ctx context.Context, containerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, err := r.call("stop", containerID)
+	if err != nil {
+		return err
+	}
+	if c.Status == "running" {
+		c.Status, c.ExitCode = "exited", 0
+		r.emit(c, engine.EventDie)
+		r.emit(c, engine.EventStop)
+	}
+	return nil
+}
+
+// Start implements engine.Runtime.
+func (r *Runtime) Start(ctx context.Context, containerID string) error {
+	r.mu.Lock()
+	c, err := r.call("start", containerID)
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	wasRunning := c.Status == "running"
+	if !wasRunning {
+		started(c)
+	}
+	name := c.Name
+	r.mu.Unlock()
+	if !wasRunning {
+		r.started(name)
+	}
+	return nil
+}
+
+// Recreate implements engine.Runtime: the container gets a new ID and the network mode.
+func (r *Runtime) Recreate(ctx context.Context, containerID, networkMode string) (string, error) {
+	r.mu.Lock()
+	c, err := r.call("recreate", containerID)
+	if err != nil {
+		r.mu.Unlock()
+		return "", err
+	}
+	c.ID = c.ID + "-new"
+	c.NetworkMode = networkMode
+	started(c)
+	id, name := c.ID, c.Name
+	r.mu.Unlock()
+	r.started(name)
+	return id, nil
+}
+
+// started calls OnStart, if set.
+func (r *Runtime) started(name string) {
+	if r.OnStart != nil {
+		r.OnStart(name)
+	}
+}
+
+// Logs implements engine.Runtime; tail and since are ignored.
+func (r *Runtime) Logs(ctx context.Context, containerID string, tail int, since time.Time) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, err := r.call("logs", containerID)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(c.Logs), nil
+}
+
+// SubscribeHealthStatus implements engine.Runtime.
+func (r *Runtime) SubscribeHealthStatus(ctx context.Context, out chan<- engine.HealthEvent) {
+	r.subscribe(ctx, &subscription{actions: []string{engine.EventUnhealthy, engine.EventHealthy, engine.EventDie, engine.EventStop}}, out)
+}
+
+// SubscribeContainer implements engine.Runtime.
+func (r *Runtime) SubscribeContainer(ctx context.Context, containerID string, out chan<- engine.HealthEvent) {
+	r.subscribe(ctx, &subscription{container: containerID, actions: []string{engine.EventHealthy, engine.EventUnhealthy, engine.EventDie}}, out)
+}
+
+// SetStopTimeout implements engine.Runtime.
+func (r *Runtime) SetStopTimeout(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopTimeout = d
+}
+
+// Close implements engine.Runtime; it ends all event subscriptions.
+func (r *Runtime) Close() error {
+	r.EndEvents()
+	return nil
+}
+
+func (r *Runtime) subscribe(ctx context.Context, s *subscription, out chan<- engine.HealthEvent) {
+	s.wake = make(chan struct{}, 1)
+	s.end = make(chan struct{})
+	r.mu.Lock()
+	r.subs[s] = true
+	r.notify()
+	r.mu.Unlock()
+	go func() {
+		defer close(out)
+		s.run(ctx, out)
+		r.mu.Lock()
+		delete(r.subs, s)
+		r.notify()
+		r.mu.Unlock()
+	}()
+}
+
+// subscription is one event subscription. Events are queued so emitting never blocks.
+type subscription struct {
+	// container is the ID or name the subscription is for ("" for all containers).
+	container string
+	actions   []string
+
+	mu    sync.Mutex
+	queue []engine.HealthEvent
+	wake  chan struct{}
+	end   chan struct{}
+	ended bool
+}
+
+func (s *subscription) wants(c *Container, status string) bool {
+	if s.container != "" && s.container != c.ID && s.container != c.Name {
+		return false
+	}
+	return slices.Contains(s.actions, status)
+}
+
+func (s *subscription) send(ev engine.HealthEvent) {
+	s.mu.Lock()
+	s.queue = append(s.queue, ev)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.ended = true
+		close(s.end)
+	}
+}
+
+// run delivers queued events to out until ctx is done or the subscription is stopped.
+func (s *subscription) run(ctx context.Context, out chan<- engine.HealthEvent) {
+	for {
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+		for _, ev := range queue {
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			case <-s.end:
+				return
+			}
+		}
+		select {
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		case <-s.end:
+			return
+		}
+	}
+}
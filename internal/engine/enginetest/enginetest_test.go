@@ -0,0 +1,58 @@
+package enginetest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"watch-dog/internal/engine"
+)
+
+func TestRuntime_restartSendsEventsAndRuns(t *testing.T) {
+	rt := New(Container{Name: "vpn", Health: "unhealthy"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	all := make(chan engine.HealthEvent, 8)
+	rt.SubscribeHealthStatus(ctx, all)
+	one := make(chan engine.HealthEvent, 8)
+	rt.SubscribeContainer(ctx, "vpn", one)
+
+	if err := rt.Restart(ctx, "vpn-id"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{engine.EventDie, engine.EventStop} {
+		if ev := <-all; ev.Status != want || ev.ContainerName != "vpn" {
+			t.Errorf("event = %+v, want %s of vpn", ev, want)
+		}
+	}
+	st, err := rt.Inspect(ctx, "vpn")
+	if err != nil || st.Status != "running" || st.Health != "starting" {
+		t.Errorf("after restart: %+v, %v", st, err)
+	}
+	rt.SetHealth("vpn", "healthy")
+	if ev := <-one; ev.Status != engine.EventDie {
+		t.Errorf("container event = %+v, want die first", ev)
+	}
+	if ev := <-one; ev.Status != engine.EventHealthy {
+		t.Errorf("container event = %+v, want healthy", ev)
+	}
+	if got := rt.Calls(); !slices.Equal(got, []string{"restart vpn"}) {
+		t.Errorf("calls = %v", got)
+	}
+}
+
+func TestRuntime_endEventsClosesSubscriptions(t *testing.T) {
+	rt := New(Container{Name: "vpn"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out := make(chan engine.HealthEvent)
+	rt.SubscribeHealthStatus(ctx, out)
+	if err := rt.WaitSubscribers(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	rt.EndEvents()
+	if _, ok := <-out; ok {
+		t.Fatal("event received, want channel closed")
+	}
+}
@@ -0,0 +1,249 @@
+package journal
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Attempt is one recovery sequence summarized from its journal entries.
+type Attempt struct {
+	// ID is the attempt ID shared by the sequence's entries.
+	ID string `json:"id"`
+	// Start is when the sequence started.
+	Start time.Time `json:"start"`
+	// Host is the parent's Docker endpoint ("" with a single endpoint).
+	Host string `json:"host,omitempty"`
+	// Parent is the recovered parent container.
+	Parent string `json:"parent"`
+	// Project is the parent's compose project, if known.
+	Project string `json:"project,omitempty"`
+	// Trigger is "event", "startup", "takeover", "polling" or "resume".
+	Trigger string `json:"trigger,omitempty"`
+	// Reason is why recovery was triggered.
+	Reason string `json:"reason,omitempty"`
+	// Details summarizes the parent's state at failure.
+	Details string `json:"details,omitempty"`
+	// LogFile is the captured failure log, if any.
+	LogFile string `json:"log_file,omitempty"`
+	// Outcome is the sequence outcome, or "" if the sequence never finished (e.g. watch-dog was killed).
+	Outcome string `json:"outcome,omitempty"`
+	// Error explains a failed outcome.
+	Error string `json:"error,omitempty"`
+	// TimeToHealthy is how long the parent took to become healthy after restart (0 if it did not).
+	TimeToHealthy time.Duration `json:"time_to_healthy_ns,omitempty"`
+	// Duration is the total time of the sequence (0 if it never finished).
+	Duration time.Duration `json:"duration_ns,omitempty"`
+	// Dependents holds the outcome of each dependent restart step, in journal order.
+	Dependents []DependentRestart `json:"dependents,omitempty"`
+	// Pending lists the dependents an aborted sequence did not get to.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// DependentRestart is the outcome of one restart_dependent step.
+type DependentRestart struct {
+	// Name is the dependent container name.
+	Name string `json:"name"`
+	// Outcome is success, failed, skipped or aborted.
+	Outcome string `json:"outcome"`
+	// Error explains a failed restart.
+	Error string `json:"error,omitempty"`
+}
+
+// Attempts groups entries (oldest first) into attempts, ordered by start time.
+// Entries without a matching attempt entry (e.g. dropped by compaction) are ignored.
+func Attempts(entries []Entry) []Attempt {
+	byID := make(map[string]*Attempt)
+	var order []string
+	for _, e := range entries {
+		if e.Kind == KindAttempt {
+			if _, ok := byID[e.AttemptID]; ok {
+				continue
+			}
+			byID[e.AttemptID] = &Attempt{
+				ID:      e.AttemptID,
+				Start:   e.Time,
+				Host:    e.Host,
+				Parent:  e.Parent,
+				Project: e.Project,
+				Trigger: e.Trigger,
+				Reason:  e.Reason,
+				Details: e.Details,
+				LogFile: e.LogFile,
+			}
+			order = append(order, e.AttemptID)
+			continue
+		}
+		a := byID[e.AttemptID]
+		if a == nil {
+			continue
+		}
+		switch e.Kind {
+		case KindStep:
+			switch e.Step {
+			case StepWaitHealthy:
+				if e.Outcome == OutcomeSuccess {
+					a.TimeToHealthy = time.Duration(e.DurationMS) * time.Millisecond
+				}
+			case StepRestartDependent:
+				a.Dependents = append(a.Dependents, DependentRestart{Name: e.Container, Outcome: e.Outcome, Error: e.Error})
+			}
+		case KindOutcome:
+			a.Outcome = e.Outcome
+			a.Error = e.Error
+			a.Pending = e.Pending
+			a.Duration = time.Duration(e.DurationMS) * time.Millisecond
+		}
+	}
+	out := make([]Attempt, 0, len(order))
+	for _, id := range order {
+		out = append(out, *byID[id])
+	}
+	slices.SortStableFunc(out, func(a, b Attempt) int { return a.Start.Compare(b.Start) })
+	return out
+}
+
+// Filter selects attempts. Empty fields match everything.
+type Filter struct {
+	// Container matches attempts whose parent is this container or that restarted it as a dependent.
+	Container string
+	// Host matches the parent's Docker endpoint.
+	Host string
+	// Project matches the parent's compose project.
+	Project string
+	// Trigger matches the trigger (see Attempt.Trigger).
+	Trigger string
+	// Outcome matches the sequence outcome; "unfinished" matches attempts without one.
+	Outcome string
+	// Since and Until bound the attempt start time (inclusive since, exclusive until).
+	Since, Until time.Time
+}
+
+// Match reports whether a satisfies every set field of the filter.
+func (f Filter) Match(a Attempt) bool {
+	if f.Container != "" && a.Parent != f.Container && !slices.ContainsFunc(a.Dependents, func(d DependentRestart) bool { return d.Name == f.Container }) {
+		return false
+	}
+	if f.Host != "" && a.Host != f.Host {
+		return false
+	}
+	if f.Project != "" && a.Project != f.Project {
+		return false
+	}
+	if f.Trigger != "" && a.Trigger != f.Trigger {
+		return false
+	}
+	if f.Outcome != "" {
+		outcome := a.Outcome
+		if outcome == "" {
+			outcome = "unfinished"
+		}
+		if outcome != f.Outcome {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && a.Start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !a.Start.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Select returns the attempts that match f.
+func (f Filter) Select(attempts []Attempt) []Attempt {
+	var out []Attempt
+	for _, a := range attempts {
+		if f.Match(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// ContainerStats summarizes how often a container was restarted, as parent or dependent.
+type ContainerStats struct {
+	// Container is the container name.
+	Container string `json:"container"`
+	// Restarts counts restarts as a parent (attempts) plus non-skipped dependent restarts.
+	Restarts int `json:"restarts"`
+	// Failures counts failed parent attempts plus failed dependent restarts.
+	Failures int `json:"failures"`
+	// FailureRate is Failures / Restarts over finished restarts (0 when none finished).
+	FailureRate float64 `json:"failure_rate"`
+	// MeanTimeToHealthy is the mean time the container took to become healthy as a parent (0 if never measured).
+	MeanTimeToHealthy time.Duration `json:"mean_time_to_healthy_ns"`
+	// MostCommonReason is the most frequent trigger reason; dependent restarts count as "dependent of <parent>".
+	MostCommonReason string `json:"most_common_reason"`
+}
+
+// Stats computes per-container statistics over attempts, sorted by restarts (descending) then name.
+func Stats(attempts []Attempt) []ContainerStats {
+	type acc struct {
+		restarts, failures, finished int
+		healthyTotal                 time.Duration
+		healthyCount                 int
+		reasons                      map[string]int
+	}
+	byName := make(map[string]*acc)
+	get := func(name string) *acc {
+		a := byName[name]
+		if a == nil {
+			a = &acc{reasons: make(map[string]int)}
+			byName[name] = a
+		}
+		return a
+	}
+	for _, at := range attempts {
+		p := get(at.Parent)
+		p.restarts++
+		p.reasons[at.Reason]++
+		if at.Outcome != "" && at.Outcome != OutcomeAborted {
+			p.finished++
+			if at.Outcome == OutcomeFailed {
+				p.failures++
+			}
+		}
+		if at.TimeToHealthy > 0 {
+			p.healthyTotal += at.TimeToHealthy
+			p.healthyCount++
+		}
+		for _, d := range at.Dependents {
+			if d.Outcome == OutcomeSkipped || d.Outcome == OutcomeAborted {
+				continue
+			}
+			a := get(d.Name)
+			a.restarts++
+			a.finished++
+			a.reasons["dependent of "+at.Parent]++
+			if d.Outcome == OutcomeFailed {
+				a.failures++
+			}
+		}
+	}
+	out := make([]ContainerStats, 0, len(byName))
+	for name, a := range byName {
+		s := ContainerStats{Container: name, Restarts: a.restarts, Failures: a.failures}
+		if a.finished > 0 {
+			s.FailureRate = float64(a.failures) / float64(a.finished)
+		}
+		if a.healthyCount > 0 {
+			s.MeanTimeToHealthy = a.healthyTotal / time.Duration(a.healthyCount)
+		}
+		best := 0
+		for reason, n := range a.reasons {
+			if n > best || (n == best && reason < s.MostCommonReason) {
+				best, s.MostCommonReason = n, reason
+			}
+		}
+		out = append(out, s)
+	}
+	slices.SortFunc(out, func(a, b ContainerStats) int {
+		if a.Restarts != b.Restarts {
+			return b.Restarts - a.Restarts
+		}
+		return strings.Compare(a.Container, b.Container)
+	})
+	return out
+}
@@ -0,0 +1,88 @@
+package journal
+
+import (
+	"testing"
+	"time"
+)
+
+func historyEntries(base time.Time) []Entry {
+	return []Entry{
+		{Time: base, Kind: KindAttempt, AttemptID: "a", Parent: "vpn", Project: "media", Trigger: "event", Reason: "unhealthy"},
+		{Time: base, Kind: KindStep, AttemptID: "a", Step: StepWaitHealthy, Outcome: OutcomeSuccess, DurationMS: 20000},
+		{Time: base, Kind: KindStep, AttemptID: "a", Step: StepRestartDependent, Container: "torrent", Outcome: OutcomeSuccess},
+		{Time: base, Kind: KindOutcome, AttemptID: "a", Outcome: OutcomeSuccess},
+		{Time: base.Add(time.Hour), Kind: KindAttempt, AttemptID: "b", Parent: "vpn", Project: "media", Trigger: "polling", Reason: "die"},
+		{Time: base.Add(time.Hour), Kind: KindStep, AttemptID: "b", Step: StepWaitHealthy, Outcome: OutcomeSuccess, DurationMS: 40000},
+		{Time: base.Add(time.Hour), Kind: KindStep, AttemptID: "b", Step: StepRestartDependent, Container: "torrent", Outcome: OutcomeFailed, Error: "boom"},
+		{Time: base.Add(time.Hour), Kind: KindOutcome, AttemptID: "b", Outcome: OutcomeFailed},
+		{Time: base.Add(2 * time.Hour), Kind: KindAttempt, AttemptID: "c", Parent: "vpn", Project: "media", Trigger: "event", Reason: "unhealthy"},
+		{Time: base.Add(2 * time.Hour), Kind: KindAttempt, AttemptID: "d", Parent: "db", Project: "apps", Trigger: "event", Reason: "die"},
+	}
+}
+
+func TestAttempts_groupsEntriesByAttempt(t *testing.T) {
+	base := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	got := Attempts(historyEntries(base))
+	if len(got) != 4 {
+		t.Fatalf("got %d attempts, want 4", len(got))
+	}
+	b := got[1]
+	if b.ID != "b" || b.Outcome != OutcomeFailed || b.TimeToHealthy != 40*time.Second || len(b.Dependents) != 1 || b.Dependents[0].Error != "boom" {
+		t.Errorf("attempt b = %+v", b)
+	}
+	if got[2].Outcome != "" {
+		t.Errorf("attempt c outcome = %q, want unfinished (\"\")", got[2].Outcome)
+	}
+}
+
+func TestFilter_Select(t *testing.T) {
+	base := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	attempts := Attempts(historyEntries(base))
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"dependent container", Filter{Container: "torrent"}, []string{"a", "b"}},
+		{"project", Filter{Project: "apps"}, []string{"d"}},
+		{"trigger", Filter{Trigger: "polling"}, []string{"b"}},
+		{"unfinished", Filter{Outcome: "unfinished"}, []string{"c", "d"}},
+		{"time range", Filter{Since: base.Add(30 * time.Minute), Until: base.Add(2 * time.Hour)}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, a := range tt.filter.Select(attempts) {
+				ids = append(ids, a.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("got %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStats_perContainer(t *testing.T) {
+	base := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	stats := Stats(Attempts(historyEntries(base)))
+	byName := make(map[string]ContainerStats)
+	for _, s := range stats {
+		byName[s.Container] = s
+	}
+	if stats[0].Container != "vpn" {
+		t.Errorf("first stats row = %q, want vpn (most restarts)", stats[0].Container)
+	}
+	vpn := byName["vpn"]
+	if vpn.Restarts != 3 || vpn.Failures != 1 || vpn.FailureRate != 0.5 || vpn.MeanTimeToHealthy != 30*time.Second || vpn.MostCommonReason != "unhealthy" {
+		t.Errorf("vpn stats = %+v", vpn)
+	}
+	torrent := byName["torrent"]
+	if torrent.Restarts != 2 || torrent.Failures != 1 || torrent.MostCommonReason != "dependent of vpn" {
+		t.Errorf("torrent stats = %+v", torrent)
+	}
+}
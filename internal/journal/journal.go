@@ -0,0 +1,353 @@
+// Package journal persists every recovery attempt, step and outcome to an append-only
+// JSON Lines file, with size-based rotation and age-based compaction, so recovery state
+// (cooldowns) survives a watch-dog restart and past recoveries can be queried.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"watch-dog/internal/engine"
+)
+
+// Kind is the type of a journal entry.
+type Kind string
+
+const (
+	// KindAttempt is written when a recovery sequence starts for a parent.
+	KindAttempt Kind = "attempt"
+	// KindStep is written when one step of a sequence finishes (or is skipped).
+	KindStep Kind = "step"
+	// KindOutcome is written when a sequence finishes.
+	KindOutcome Kind = "outcome"
+)
+
+// Step names used in KindStep entries.
+const (
+	StepCaptureLogs      = "capture_logs"
+	StepRestartParent    = "restart_parent"
+	StepWaitHealthy      = "wait_healthy"
+	StepRestartDependent = "restart_dependent"
+	StepStopDependent    = "stop_dependent"
+)
+
+// Outcomes used in KindStep and KindOutcome entries. Partial is only used for outcomes: the parent
+// recovered but some dependents failed. Aborted means watch-dog shut down before the step or
+// sequence finished; an aborted outcome lists the dependents it did not get to in Pending.
+const (
+	OutcomeSuccess = "success"
+	OutcomePartial = "partial"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped"
+	OutcomeAborted = "aborted"
+)
+
+// Entry is one line of the journal. Fields that do not apply to a Kind are omitted.
+type Entry struct {
+	// Time is when the entry was written.
+	Time time.Time `json:"time"`
+	// Kind is attempt, step or outcome.
+	Kind Kind `json:"kind"`
+	// AttemptID groups all entries of one recovery sequence.
+	AttemptID string `json:"attempt_id"`
+	// Host is the Docker endpoint of the parent ("" with a single endpoint).
+	Host string `json:"host,omitempty"`
+	// Parent is the parent container name the sequence recovers.
+	Parent string `json:"parent"`
+	// Project is the parent's compose project (com.docker.compose.project), if known.
+	Project string `json:"project,omitempty"`
+	// Trigger is what started the sequence: "event", "startup", "takeover", "polling" or "resume".
+	Trigger string `json:"trigger,omitempty"`
+	// Reason is why recovery was triggered (e.g. "die", "unhealthy").
+	Reason string `json:"reason,omitempty"`
+	// Details is a short summary of the parent's state at failure (see engine.ContainerState.Summary).
+	Details string `json:"details,omitempty"`
+	// State is the parent's inspected state at failure (attempt entries only).
+	State *engine.ContainerState `json:"state,omitempty"`
+	// LogFile is the path of the captured failure logs, if any.
+	LogFile string `json:"log_file,omitempty"`
+	// Step is the step name (step entries only).
+	Step string `json:"step,omitempty"`
+	// Container is the container a step acted on (e.g. the dependent for restart_dependent).
+	Container string `json:"container,omitempty"`
+	// Outcome is success, failed, skipped or aborted for steps; success, partial, failed or aborted for outcomes.
+	Outcome string `json:"outcome,omitempty"`
+	// Attempts is how many times a step was tried (restart_dependent with retries).
+	Attempts int `json:"attempts,omitempty"`
+	// Error is the error message when Outcome is failed.
+	Error string `json:"error,omitempty"`
+	// DurationMS is how long the step or sequence took, in milliseconds.
+	DurationMS int64 `json:"duration_ms,omitempty"`
+	// Pending lists the dependents an aborted sequence did not restart (aborted outcomes only);
+	// they are restarted when watch-dog starts again (see State.Pending).
+	Pending []string `json:"pending,omitempty"`
+	// Plan lists the steps the sequence is going to run, in order (attempt entries only). It is
+	// written before the first step so a sequence cut off by a crash or by watch-dog restarting
+	// itself can be completed on the next start (see State.Pending).
+	Plan []PlanStep `json:"plan,omitempty"`
+}
+
+// PlanStep is one planned step of a recovery sequence: a step name (StepRestartParent, …) and
+// the container it acts on.
+type PlanStep struct {
+	Step      string `json:"step"`
+	Container string `json:"container"`
+}
+
+// Options configures rotation and compaction.
+type Options struct {
+	// MaxSize is the size in bytes at which the active file is rotated (0 = never rotate).
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept (path.1 … path.N); older ones are removed.
+	MaxFiles int
+	// Retention drops attempts older than this when the journal is compacted (0 = keep all).
+	Retention time.Duration
+}
+
+// Journal is an append-only JSON Lines file. It is safe for concurrent use.
+type Journal struct {
+	path string
+	opts Options
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open compacts the journal at path (dropping attempts older than Retention and merging
+// rotated files) and opens it for appending, creating it if missing.
+func Open(path string, opts Options) (*Journal, error) {
+	j := &Journal{path: path, opts: opts}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := j.compact(time.Now()); err != nil {
+		return nil, fmt.Errorf("compact journal: %w", err)
+	}
+	if err := j.openActive(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Path returns the path of the active journal file.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+func (j *Journal) openActive() error {
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.f = f
+	j.size = info.Size()
+	return nil
+}
+
+// Append writes e as one line and syncs it to disk, rotating first if the active file
+// has reached MaxSize. A zero Time is set to now.
+func (j *Journal) Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return errors.New("journal closed")
+	}
+	if j.opts.MaxSize > 0 && j.size > 0 && j.size+int64(len(line)) > j.opts.MaxSize {
+		if err := j.rotate(); err != nil {
+			return fmt.Errorf("rotate journal: %w", err)
+		}
+	}
+	n, err := j.f.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// rotate shifts path.N-1 → path.N … path → path.1 and opens a fresh active file. Caller holds j.mu.
+func (j *Journal) rotate() error {
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	j.f = nil
+	maxFiles := max(j.opts.MaxFiles, 1)
+	if err := os.Remove(rotatedPath(j.path, maxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedPath(j.path, i), rotatedPath(j.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(j.path, rotatedPath(j.path, 1)); err != nil {
+		return err
+	}
+	return j.openActive()
+}
+
+// Close closes the active file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// Entries returns all entries from the rotated files and the active file, oldest first.
+func (j *Journal) Entries() ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return ReadAll(j.path)
+}
+
+// compact rewrites the journal as a single active file without attempts that started
+// before now-Retention, and removes rotated files. Caller must not hold j.f open.
+func (j *Journal) compact(now time.Time) error {
+	entries, err := ReadAll(j.path)
+	if err != nil {
+		return err
+	}
+	kept := entries
+	if j.opts.Retention > 0 {
+		kept = dropOldAttempts(entries, now.Add(-j.opts.Retention))
+	}
+	tmp := j.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range kept {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	for i := 1; ; i++ {
+		if err := os.Remove(rotatedPath(j.path, i)); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// dropOldAttempts removes every entry of attempts whose first entry is before cutoff.
+func dropOldAttempts(entries []Entry, cutoff time.Time) []Entry {
+	started := make(map[string]time.Time)
+	for _, e := range entries {
+		if t, ok := started[e.AttemptID]; !ok || e.Time.Before(t) {
+			started[e.AttemptID] = e.Time
+		}
+	}
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if started[e.AttemptID].Before(cutoff) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// ReadAll reads the journal at path including its rotated files, oldest first.
+// Missing files are skipped; lines that cannot be decoded (e.g. a partial last line
+// after a crash) are ignored.
+func ReadAll(path string) ([]Entry, error) {
+	var rotated []string
+	for i := 1; ; i++ {
+		p := rotatedPath(path, i)
+		if _, err := os.Stat(p); err != nil {
+			break
+		}
+		rotated = append(rotated, p)
+	}
+	var out []Entry
+	for i := len(rotated) - 1; i >= 0; i-- {
+		entries, err := readFile(rotated[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+	}
+	entries, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, entries...), nil
+}
+
+func readFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return decode(f)
+}
+
+func decode(r io.Reader) ([]Entry, error) {
+	var out []Entry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+func rotatedPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
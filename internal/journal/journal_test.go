@@ -0,0 +1,209 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal_appendAndReadBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer j.Close()
+	want := []Entry{
+		{Kind: KindAttempt, AttemptID: "a1", Parent: "vpn", Trigger: "event", Reason: "die"},
+		{Kind: KindStep, AttemptID: "a1", Parent: "vpn", Step: StepRestartParent, Outcome: OutcomeSuccess},
+		{Kind: KindOutcome, AttemptID: "a1", Parent: "vpn", Outcome: OutcomeSuccess},
+	}
+	for _, e := range want {
+		if err := j.Append(e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	got, err := j.Entries()
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Kind != want[i].Kind || got[i].Step != want[i].Step || got[i].Time.IsZero() {
+			t.Errorf("entry %d = %+v, want kind %s step %q with time set", i, got[i], want[i].Kind, want[i].Step)
+		}
+	}
+}
+
+func TestJournal_rotatesAtMaxSizeAndKeepsOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := Open(path, Options{MaxSize: 200, MaxFiles: 10})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer j.Close()
+	for i := 0; i < 10; i++ {
+		if err := j.Append(Entry{Kind: KindAttempt, AttemptID: string(rune('a' + i)), Parent: "vpn"}); err != nil {
+			t.Fatalf("Append %d: %v", i, err)
+		}
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected rotated file %s.1: %v", path, err)
+	}
+	got, err := ReadAll(path)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(got) != 10 {
+		t.Fatalf("got %d entries across rotated files, want 10", len(got))
+	}
+	for i, e := range got {
+		if e.AttemptID != string(rune('a'+i)) {
+			t.Errorf("entry %d attempt = %q, want %q (oldest first)", i, e.AttemptID, string(rune('a'+i)))
+		}
+	}
+}
+
+func TestOpen_compactsOldAttemptsAndRotatedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := Open(path, Options{MaxSize: 150, MaxFiles: 5})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	entries := []Entry{
+		{Time: old, Kind: KindAttempt, AttemptID: "old", Parent: "vpn"},
+		{Time: old.Add(time.Minute), Kind: KindOutcome, AttemptID: "old", Parent: "vpn", Outcome: OutcomeSuccess},
+		{Kind: KindAttempt, AttemptID: "new", Parent: "vpn"},
+		{Kind: KindOutcome, AttemptID: "new", Parent: "vpn", Outcome: OutcomeSuccess},
+	}
+	for _, e := range entries {
+		if err := j.Append(e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	j.Close()
+
+	j, err = Open(path, Options{Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer j.Close()
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("rotated file still present after compaction (stat err %v)", err)
+	}
+	got, _ := j.Entries()
+	if len(got) != 2 || got[0].AttemptID != "new" || got[1].AttemptID != "new" {
+		t.Errorf("after compaction got %+v, want only the two entries of attempt \"new\"", got)
+	}
+}
+
+func TestRestore_lastRecoveryAndDependentRestarts(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	entries := []Entry{
+		{Time: t1, Kind: KindAttempt, AttemptID: "a", Parent: "vpn"},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Step: StepRestartDependent, Container: "torrent", Outcome: OutcomeSuccess},
+		{Time: t2, Kind: KindAttempt, AttemptID: "b", Parent: "vpn"},
+		{Time: t2, Kind: KindStep, AttemptID: "b", Step: StepRestartDependent, Container: "torrent", Outcome: OutcomeFailed},
+		{Time: t2, Kind: KindStep, AttemptID: "b", Step: StepRestartDependent, Container: "sonarr", Outcome: OutcomeSkipped},
+	}
+	s := Restore(entries)
+	if !s.LastRecovery["vpn"].Equal(t2) {
+		t.Errorf("LastRecovery[vpn] = %v, want %v", s.LastRecovery["vpn"], t2)
+	}
+	if !s.LastDependentRestart["torrent"].Equal(t1) {
+		t.Errorf("LastDependentRestart[torrent] = %v, want %v (failed restarts do not count)", s.LastDependentRestart["torrent"], t1)
+	}
+	if _, ok := s.LastDependentRestart["sonarr"]; ok {
+		t.Errorf("LastDependentRestart has skipped dependent sonarr")
+	}
+}
+
+func TestRestore_pendingDependentsOfAbortedAttempt(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: t1, Kind: KindAttempt, AttemptID: "a", Parent: "vpn"},
+		{Time: t1, Kind: KindOutcome, AttemptID: "a", Parent: "vpn", Outcome: OutcomeAborted, Pending: []string{"torrent", "sonarr"}},
+		{Time: t1, Kind: KindAttempt, AttemptID: "b", Parent: "db"},
+		{Time: t1, Kind: KindOutcome, AttemptID: "b", Parent: "db", Outcome: OutcomeAborted, Pending: []string{"web"}},
+		{Time: t1.Add(time.Minute), Kind: KindAttempt, AttemptID: "c", Parent: "db"},
+	}
+	s := Restore(entries)
+	if got := s.Pending["vpn"]; len(got) != 2 || got[0] != "torrent" || got[1] != "sonarr" {
+		t.Errorf("Pending[vpn] = %v, want [torrent sonarr]", got)
+	}
+	if _, ok := s.Pending["db"]; ok {
+		t.Error("Pending[db] kept after a later attempt for db")
+	}
+}
+
+func TestRestore_pendingStepsOfUnfinishedPlan(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	plan := []PlanStep{
+		{Step: StepRestartParent, Container: "vpn"},
+		{Step: StepWaitHealthy, Container: "vpn"},
+		{Step: StepRestartDependent, Container: "sonarr"},
+		{Step: StepRestartDependent, Container: "torrent"},
+		{Step: StepRestartDependent, Container: "watch-dog"},
+	}
+	entries := []Entry{
+		{Time: t1, Kind: KindAttempt, AttemptID: "a", Parent: "vpn", Plan: plan},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepRestartParent, Container: "vpn", Outcome: OutcomeSuccess},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepWaitHealthy, Container: "vpn", Outcome: OutcomeSuccess},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepRestartDependent, Container: "sonarr", Outcome: OutcomeSuccess},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepRestartDependent, Container: "torrent", Outcome: OutcomeFailed},
+		// A finished plan leaves nothing pending.
+		{Time: t1, Kind: KindAttempt, AttemptID: "b", Parent: "db", Plan: []PlanStep{{Step: StepRestartDependent, Container: "web"}}},
+		{Time: t1, Kind: KindOutcome, AttemptID: "b", Parent: "db", Outcome: OutcomeFailed},
+	}
+	s := Restore(entries)
+	if got := s.Pending["vpn"]; len(got) != 2 || got[0] != "torrent" || got[1] != "watch-dog" {
+		t.Errorf("Pending[vpn] = %v, want [torrent watch-dog]", got)
+	}
+	if _, ok := s.Pending["db"]; ok {
+		t.Error("Pending[db] set for a finished attempt")
+	}
+}
+
+func TestRestore_heldStoppedDependents(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: t1, Kind: KindAttempt, AttemptID: "a", Parent: "vpn"},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepStopDependent, Container: "torrent", Outcome: OutcomeSuccess},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepStopDependent, Container: "sonarr", Outcome: OutcomeSuccess},
+		{Time: t1, Kind: KindStep, AttemptID: "a", Parent: "vpn", Step: StepStopDependent, Container: "web", Outcome: OutcomeFailed},
+		{Time: t1, Kind: KindOutcome, AttemptID: "a", Parent: "vpn", Outcome: OutcomeFailed},
+		// sonarr was started again later (e.g. once vpn was healthy); torrent still is not.
+		{Time: t1.Add(time.Hour), Kind: KindAttempt, AttemptID: "b", Parent: "vpn", Trigger: "held"},
+		{Time: t1.Add(time.Hour), Kind: KindStep, AttemptID: "b", Parent: "vpn", Step: StepRestartDependent, Container: "sonarr", Outcome: OutcomeSuccess},
+		{Time: t1.Add(time.Hour), Kind: KindStep, AttemptID: "b", Parent: "vpn", Step: StepRestartDependent, Container: "torrent", Outcome: OutcomeFailed},
+	}
+	s := Restore(entries)
+	if len(s.Held) != 1 || s.Held["torrent"] != "vpn" {
+		t.Errorf("Held = %v, want only torrent held by vpn", s.Held)
+	}
+}
+
+func TestRestore_forHostKeepsEndpointsApart(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: t1, Kind: KindAttempt, AttemptID: "a", Parent: "vpn"},
+		{Time: t1.Add(time.Minute), Kind: KindAttempt, AttemptID: "b", Host: "nas", Parent: "vpn"},
+		{Time: t1.Add(time.Minute), Kind: KindOutcome, AttemptID: "b", Host: "nas", Parent: "vpn", Outcome: OutcomeAborted, Pending: []string{"torrent"}},
+	}
+	local := Restore(ForHost(entries, ""))
+	if got := local.LastRecovery["vpn"]; !got.Equal(t1) {
+		t.Errorf("local LastRecovery[vpn] = %v, want %v", got, t1)
+	}
+	if len(local.Pending) != 0 {
+		t.Errorf("local Pending = %v, want none", local.Pending)
+	}
+	nas := Restore(ForHost(entries, "nas"))
+	if got := nas.Pending["vpn"]; len(got) != 1 || got[0] != "torrent" {
+		t.Errorf("nas Pending[vpn] = %v, want [torrent]", got)
+	}
+}
@@ -0,0 +1,100 @@
+package journal
+
+import "time"
+
+// State is the recovery state reconstructed from journal entries at startup.
+type State struct {
+	// LastRecovery maps parent name to the start time of its most recent recovery attempt
+	// (restores the per-parent RECOVERY_COOLDOWN).
+	LastRecovery map[string]time.Time
+	// LastDependentRestart maps dependent name to the time of its most recent successful
+	// restart (restores WATCHDOG_DEPENDENT_RESTART_COOLDOWN).
+	LastDependentRestart map[string]time.Time
+	// Pending maps parent name to the dependents its last attempt left to restart: those listed
+	// by an aborted outcome (watch-dog shut down mid-sequence) or, for an attempt without an
+	// outcome (watch-dog was killed or restarted itself), the planned dependents without a
+	// successful restart step. A later attempt for the parent clears it.
+	Pending map[string][]string
+	// Held maps the dependents a stop-first recovery stopped and that were not started again
+	// (successful stop_dependent step without a later successful restart_dependent step) to that
+	// recovery's parent.
+	Held map[string]string
+}
+
+// openPlan is the plan of a parent's latest attempt while it has no outcome.
+type openPlan struct {
+	attemptID string
+	steps     []PlanStep
+	done      map[PlanStep]bool
+}
+
+// Restore builds State from entries (oldest first, as returned by ReadAll).
+func Restore(entries []Entry) State {
+	s := State{
+		LastRecovery:         make(map[string]time.Time),
+		LastDependentRestart: make(map[string]time.Time),
+		Pending:              make(map[string][]string),
+		Held:                 make(map[string]string),
+	}
+	plans := make(map[string]*openPlan)
+	for _, e := range entries {
+		switch e.Kind {
+		case KindAttempt:
+			if e.Time.After(s.LastRecovery[e.Parent]) {
+				s.LastRecovery[e.Parent] = e.Time
+			}
+			delete(s.Pending, e.Parent)
+			delete(plans, e.Parent)
+			if len(e.Plan) > 0 {
+				plans[e.Parent] = &openPlan{attemptID: e.AttemptID, steps: e.Plan, done: make(map[PlanStep]bool)}
+			}
+		case KindOutcome:
+			if p := plans[e.Parent]; p != nil && p.attemptID == e.AttemptID {
+				delete(plans, e.Parent)
+			}
+			if e.Outcome == OutcomeAborted && len(e.Pending) > 0 {
+				s.Pending[e.Parent] = e.Pending
+			}
+		case KindStep:
+			if e.Outcome != OutcomeSuccess {
+				break
+			}
+			if p := plans[e.Parent]; p != nil && p.attemptID == e.AttemptID {
+				p.done[PlanStep{Step: e.Step, Container: e.Container}] = true
+			}
+			switch e.Step {
+			case StepStopDependent:
+				s.Held[e.Container] = e.Parent
+			case StepRestartDependent:
+				delete(s.Held, e.Container)
+				if e.Time.After(s.LastDependentRestart[e.Container]) {
+					s.LastDependentRestart[e.Container] = e.Time
+				}
+			}
+		}
+	}
+	for parent, p := range plans {
+		var pending []string
+		for _, step := range p.steps {
+			if step.Step == StepRestartDependent && !p.done[step] {
+				pending = append(pending, step.Container)
+			}
+		}
+		if len(pending) > 0 {
+			s.Pending[parent] = pending
+		}
+	}
+	return s
+}
+
+// ForHost returns the entries of the Docker endpoint host, for restoring its State ("" selects
+// the entries written with a single endpoint).
+func ForHost(entries []Entry, host string) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if e.Host == host {
+			out = append(out, e)
+		}
+	}
+	return out
+}
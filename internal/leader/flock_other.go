@@ -0,0 +1,19 @@
+//go:build !unix
+
+package leader
+
+import (
+	"errors"
+	"os"
+)
+
+// errUnsupported is returned by lockFile on platforms without flock.
+var errUnsupported = errors.New("file locks are not supported on this platform")
+
+func lockFile(f *os.File) error {
+	return errUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errUnsupported
+}
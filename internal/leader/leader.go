@@ -0,0 +1,212 @@
+// Package leader elects one active watch-dog among replicas that share a volume. The replicas
+// hold a lease in a JSON file on the volume; the file is read and written under an exclusive
+// flock, so only the local filesystem is needed. The holder renews the lease every third of its
+// duration; a standby takes over once the lease has expired, e.g. when the leader stopped, crashed
+// or hangs.
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"watch-dog/internal/clock"
+	"watch-dog/internal/docker"
+)
+
+// Lease is the content of the lease file.
+type Lease struct {
+	// Holder is the ID of the replica holding the lease.
+	Holder string `json:"holder"`
+	// Acquired is when Holder took the lease.
+	Acquired time.Time `json:"acquired"`
+	// Expires is when the lease ends unless Holder renews it.
+	Expires time.Time `json:"expires"`
+}
+
+// Elector takes part in the election for one replica. A nil *Elector (election disabled) is
+// always the leader.
+type Elector struct {
+	path     string
+	id       string
+	duration time.Duration
+	clock    clock.Clock
+
+	leader atomic.Bool
+	// until is when the lease held by this replica ends; past it, IsLeader is false even if a
+	// renewal is late (e.g. the file system hangs), so two replicas never both act.
+	until atomic.Int64
+
+	mu     sync.Mutex
+	holder string
+}
+
+// New returns an Elector for the lease file at path, identifying this replica as id. duration is
+// how long a lease lasts without renewal.
+func New(path, id string, duration time.Duration) *Elector {
+	return &Elector{path: path, id: id, duration: duration, clock: clock.Real}
+}
+
+// ID returns this replica's ID.
+func (e *Elector) ID() string {
+	if e == nil {
+		return ""
+	}
+	return e.id
+}
+
+// IsLeader reports whether this replica holds an unexpired lease.
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return e.leader.Load() && e.clock.Now().UnixNano() < e.until.Load()
+}
+
+// Holder returns the ID of the replica that held the lease at the last check ("" if none).
+func (e *Elector) Holder() string {
+	if e == nil {
+		return ""
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.holder
+}
+
+// Run takes part in the election until ctx is done, calling Check every third of the lease
+// duration. On return, a lease held by this replica is released so a standby can take over right
+// away. Run on a nil *Elector returns immediately.
+func (e *Elector) Run(ctx context.Context, onChange func(leader bool, holder string)) {
+	if e == nil {
+		return
+	}
+	interval := max(e.duration/3, 100*time.Millisecond)
+	for {
+		e.Check(onChange)
+		select {
+		case <-ctx.Done():
+			if err := e.release(); err != nil {
+				docker.LogWarn("release leader lease", "path", e.path, "error", err)
+			}
+			if e.leader.Swap(false) {
+				onChange(false, "")
+			}
+			return
+		case <-e.clock.After(interval):
+		}
+	}
+}
+
+// Check tries to acquire or renew the lease once. onChange is called when this replica became or
+// stopped being leader, or the lease holder changed.
+func (e *Elector) Check(onChange func(leader bool, holder string)) {
+	lease, err := e.acquire()
+	if err != nil {
+		docker.LogWarn("leader lease", "path", e.path, "error", err)
+		// Leadership lapses on its own when the lease ends (see IsLeader).
+		if e.leader.Load() && !e.IsLeader() {
+			e.leader.Store(false)
+			onChange(false, e.Holder())
+		}
+		return
+	}
+	e.mu.Lock()
+	prevHolder := e.holder
+	e.holder = lease.Holder
+	e.mu.Unlock()
+	isLeader := lease.Holder == e.id
+	if isLeader {
+		e.until.Store(lease.Expires.UnixNano())
+	}
+	if e.leader.Swap(isLeader) != isLeader || prevHolder != lease.Holder {
+		onChange(isLeader, lease.Holder)
+	}
+}
+
+// acquire reads the lease under the file lock and takes it for this replica when it is free,
+// expired or already ours. It returns the lease in effect afterwards.
+func (e *Elector) acquire() (Lease, error) {
+	f, err := os.OpenFile(e.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return Lease{}, err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return Lease{}, fmt.Errorf("lock %s: %w", e.path, err)
+	}
+	defer unlockFile(f)
+
+	current, err := readLease(f)
+	if err != nil {
+		return Lease{}, err
+	}
+	now := e.clock.Now()
+	if current.Holder != "" && current.Holder != e.id && now.Before(current.Expires) {
+		return current, nil
+	}
+	next := Lease{Holder: e.id, Acquired: current.Acquired, Expires: now.Add(e.duration)}
+	if current.Holder != e.id || !now.Before(current.Expires) {
+		next.Acquired = now
+	}
+	if err := writeLease(f, next); err != nil {
+		return Lease{}, err
+	}
+	return next, nil
+}
+
+// release clears the lease if this replica holds it.
+func (e *Elector) release() error {
+	f, err := os.OpenFile(e.path, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	current, err := readLease(f)
+	if err != nil || current.Holder != e.id {
+		return err
+	}
+	return writeLease(f, Lease{})
+}
+
+func readLease(f *os.File) (Lease, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return Lease{}, err
+	}
+	var l Lease
+	if len(data) == 0 {
+		return l, nil
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		// A torn write cannot happen under the lock, but a foreign file can: treat it as free.
+		docker.LogWarn("ignoring unreadable leader lease", "path", f.Name(), "error", err)
+		return Lease{}, nil
+	}
+	return l, nil
+}
+
+func writeLease(f *os.File, l Lease) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(append(data, '\n'), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
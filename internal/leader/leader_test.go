@@ -0,0 +1,100 @@
+package leader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"watch-dog/internal/clock/clocktest"
+)
+
+func newElectors(t *testing.T, c *clocktest.Fake) (a, b *Elector) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	a, b = New(path, "a", 15*time.Second), New(path, "b", 15*time.Second)
+	a.clock, b.clock = c, c
+	return a, b
+}
+
+func TestElector_standbyTakesOverExpiredLease(t *testing.T) {
+	c := clocktest.New(time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC))
+	a, b := newElectors(t, c)
+	var changes []string
+	record := func(name string) func(bool, string) {
+		return func(leader bool, holder string) {
+			changes = append(changes, name+":"+holder)
+		}
+	}
+
+	a.Check(record("a"))
+	b.Check(record("b"))
+	if !a.IsLeader() || b.IsLeader() || b.Holder() != "a" {
+		t.Fatalf("after first check: a leader %v, b leader %v, holder %q", a.IsLeader(), b.IsLeader(), b.Holder())
+	}
+
+	// a renews within the lease, so b stays standby.
+	c.Advance(10 * time.Second)
+	a.Check(record("a"))
+	c.Advance(10 * time.Second)
+	b.Check(record("b"))
+	if b.IsLeader() {
+		t.Fatal("b took over a renewed lease")
+	}
+
+	// a stops renewing: its leadership lapses on its own and b takes over once the lease expires.
+	c.Advance(6 * time.Second)
+	if a.IsLeader() {
+		t.Error("a still leader after its lease expired")
+	}
+	b.Check(record("b"))
+	a.Check(record("a"))
+	if !b.IsLeader() || a.IsLeader() || a.Holder() != "b" {
+		t.Errorf("after expiry: a leader %v, b leader %v, holder %q", a.IsLeader(), b.IsLeader(), a.Holder())
+	}
+	want := []string{"a:a", "b:a", "b:b", "a:b"}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes = %v, want %v", changes, want)
+		}
+	}
+}
+
+func TestElector_runReleasesLeaseOnShutdown(t *testing.T) {
+	c := clocktest.New(time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC))
+	a, b := newElectors(t, c)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var lost bool
+	go func() {
+		a.Run(ctx, func(leader bool, _ string) { lost = !leader })
+		close(done)
+	}()
+	// Run checks once, then waits for the next renewal.
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	if err := c.WaitTimers(waitCtx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !a.IsLeader() {
+		t.Fatal("a not leader after its first check")
+	}
+	cancel()
+	<-done
+	if !lost || a.IsLeader() {
+		t.Error("a still leader after Run returned")
+	}
+	b.Check(func(bool, string) {})
+	if !b.IsLeader() {
+		t.Error("b did not take the released lease right away")
+	}
+}
+
+func TestElector_nilIsAlwaysLeader(t *testing.T) {
+	var e *Elector
+	if !e.IsLeader() || e.Holder() != "" {
+		t.Error("nil Elector should be leader without a holder")
+	}
+}
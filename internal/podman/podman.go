@@ -0,0 +1,44 @@
+// Package podman is the Podman backend of engine.Runtime. Podman serves a Docker-compatible
+// Engine API on its service socket (podman system service), so the backend is the Docker client
+// pointed at that socket; the compat API's different health event format is handled by the
+// docker package's event subscription.
+package podman
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
+)
+
+// rootfulSocket is the API socket of the system-wide Podman service.
+const rootfulSocket = "/run/podman/podman.sock"
+
+// DefaultHost returns the Podman API address when none is configured: CONTAINER_HOST (Podman's
+// own variable), else DOCKER_HOST, else the rootless socket under XDG_RUNTIME_DIR if it exists,
+// else the rootful socket. Variables are read through getenv.
+func DefaultHost(getenv func(string) string) string {
+	for _, name := range []string{"CONTAINER_HOST", "DOCKER_HOST"} {
+		if v := getenv(name); v != "" {
+			return v
+		}
+	}
+	if dir := getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sock := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return "unix://" + sock
+		}
+	}
+	return "unix://" + rootfulSocket
+}
+
+// NewClient creates a client for the Podman API of e; an empty e.Host means DefaultHost. Like
+// docker.NewEndpointClient it does not connect yet.
+func NewClient(ctx context.Context, e docker.Endpoint) (engine.Runtime, error) {
+	if e.Host == "" {
+		e.Host = DefaultHost(os.Getenv)
+	}
+	return docker.NewEndpointClient(ctx, e)
+}
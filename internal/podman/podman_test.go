@@ -0,0 +1,32 @@
+package podman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultHost(t *testing.T) {
+	dir := t.TempDir()
+	env := map[string]string{"XDG_RUNTIME_DIR": dir}
+	getenv := func(name string) string { return env[name] }
+
+	if got := DefaultHost(getenv); got != "unix:///run/podman/podman.sock" {
+		t.Errorf("without a rootless socket: %q, want the rootful socket", got)
+	}
+	sock := filepath.Join(dir, "podman", "podman.sock")
+	if err := os.MkdirAll(filepath.Dir(sock), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sock, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := DefaultHost(getenv); got != "unix://"+sock {
+		t.Errorf("with a rootless socket: %q, want %q", got, "unix://"+sock)
+	}
+	env["DOCKER_HOST"] = "tcp://docker:2375"
+	env["CONTAINER_HOST"] = "unix:///run/user/1000/podman/podman.sock"
+	if got := DefaultHost(getenv); got != env["CONTAINER_HOST"] {
+		t.Errorf("with CONTAINER_HOST: %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
+)
+
+// captureFileSuffix is the extension of failure log files written by LogCapture; retention only
+// considers files with this suffix so other files in Dir are never removed.
+const captureFileSuffix = ".log"
+
+// LogCapture saves a failing container's recent stdout/stderr to Dir before it is restarted,
+// so the evidence survives the restart. Old captures are pruned by MaxFiles and MaxAge.
+type LogCapture struct {
+	// Dir is the directory captures are written to (created if missing).
+	Dir string
+	// Tail is the number of most recent log lines to keep (0 = no line limit).
+	Tail int
+	// Since limits the capture to output from the last Since before the failure (0 = no time limit).
+	Since time.Duration
+	// MaxFiles is the maximum number of captures kept in Dir; oldest are removed first (0 = unlimited).
+	MaxFiles int
+	// MaxAge removes captures older than this (0 = keep regardless of age).
+	MaxAge time.Duration
+}
+
+// Capture fetches the container's logs through the client and writes them to a new file in Dir
+// named <containerName>-<UTC timestamp>.log, then applies retention. now is the time of the
+// failure (the Flow's clock); it names the file and is its modification time, so retention ages
+// captures by the same clock. Returns the file path.
+func (c *LogCapture) Capture(ctx context.Context, client engine.Runtime, containerID, containerName string, now time.Time) (string, error) {
+	var since time.Time
+	if c.Since > 0 {
+		since = now.Add(-c.Since)
+	}
+	data, err := client.Logs(ctx, containerID, c.Tail, since)
+	if err != nil {
+		return "", fmt.Errorf("read logs: %w", err)
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return "", err
+	}
+	name := sanitizeFileName(containerName) + "-" + now.UTC().Format("20060102T150405.000Z") + captureFileSuffix
+	path := filepath.Join(c.Dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Chtimes(path, now, now); err != nil {
+		return "", err
+	}
+	if err := c.prune(now); err != nil {
+		docker.LogWarn("recovery: prune failure logs", "dir", c.Dir, "error", err)
+	}
+	return path, nil
+}
+
+// prune removes captures older than MaxAge, then the oldest captures beyond MaxFiles.
+func (c *LogCapture) prune(now time.Time) error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	type capture struct {
+		path    string
+		modTime time.Time
+	}
+	var kept []capture
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), captureFileSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.Dir, e.Name())
+		if c.MaxAge > 0 && now.Sub(info.ModTime()) > c.MaxAge {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, capture{path: path, modTime: info.ModTime()})
+	}
+	if c.MaxFiles <= 0 || len(kept) <= c.MaxFiles {
+		return nil
+	}
+	// Newest first; names embed the timestamp so they break ties within the same mtime.
+	slices.SortFunc(kept, func(a, b capture) int {
+		if d := b.modTime.Compare(a.modTime); d != 0 {
+			return d
+		}
+		return strings.Compare(b.path, a.path)
+	})
+	for _, old := range kept[c.MaxFiles:] {
+		if err := os.Remove(old.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sanitizeFileName replaces characters that are unsafe in file names with '_'.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,102 @@
+package recovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"watch-dog/internal/discovery"
+)
+
+func TestLogCapture_writesLogsToDir(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeClient{logs: map[string]string{"vpn-id": "2026-01-01T03:00:00Z tunnel down\n"}}
+	c := &LogCapture{Dir: dir, Tail: 100}
+
+	path, err := c.Capture(context.Background(), fake, "vpn-id", "vpn", epoch)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "vpn-") {
+		t.Errorf("Capture path = %q, want vpn-*.log in %q", path, dir)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read capture: %v", err)
+	}
+	if !strings.Contains(string(data), "tunnel down") {
+		t.Errorf("capture content = %q, want container logs", data)
+	}
+}
+
+func TestLogCapture_retentionKeepsNewestMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"a-old.log", "b-mid.log", "c-new.log"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mt := epoch.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Unrelated files are never pruned.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeClient{}
+	c := &LogCapture{Dir: dir, MaxFiles: 2}
+
+	if _, err := c.Capture(context.Background(), fake, "vpn-id", "vpn", epoch); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 3 || !slices.Contains(names, "c-new.log") || !slices.Contains(names, "notes.txt") {
+		t.Errorf("after retention: files %v, want c-new.log, the new capture and notes.txt", names)
+	}
+}
+
+func TestLogCapture_retentionRemovesOlderThanMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "vpn-old.log")
+	if err := os.WriteFile(old, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mt := epoch.Add(-48 * time.Hour)
+	if err := os.Chtimes(old, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+	c := &LogCapture{Dir: dir, MaxAge: 24 * time.Hour}
+
+	if _, err := c.Capture(context.Background(), &fakeClient{}, "vpn-id", "vpn", epoch); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("capture older than MaxAge still present (stat err %v)", err)
+	}
+}
+
+func TestRunFullSequence_capturesLogsBeforeRestart(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	flow := &Flow{Client: fake, Capture: &LogCapture{Dir: t.TempDir()}}
+	parentToDeps := discovery.ParentToDependents{"vpn": {"torrent"}}
+
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "unhealthy", "event", discovery.NewGraph(parentToDeps), "")
+
+	fake.mu.Lock()
+	calls := slices.Clone(fake.calls)
+	fake.mu.Unlock()
+	want := []string{"logs:vpn-id", "restart:vpn-id", "restart:torrent"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,294 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/journal"
+)
+
+// dependentRetryBackoff is the pause before retrying a dependent that failed to restart or verify.
+const dependentRetryBackoff = 5 * time.Second
+
+// LabelDependentConcurrency is the parent container label that overrides DependentConcurrency for
+// that parent's dependents (a positive integer), e.g. for a VPN gateway with many dependents.
+const LabelDependentConcurrency = "watch-dog.dependent-concurrency"
+
+// dependentConcurrency returns the concurrency for restarting the parent's dependents: the
+// LabelDependentConcurrency label on the parent when valid, otherwise DependentConcurrency.
+func (f *Flow) dependentConcurrency(parent *engine.ContainerState) int {
+	if parent != nil {
+		if v := parent.Labels[LabelDependentConcurrency]; v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				return n
+			}
+			f.log().Warn("invalid "+LabelDependentConcurrency+" label, ignoring", "value", v)
+		}
+	}
+	return f.policy().DependentConcurrency
+}
+
+// DependentResult is the outcome of restarting (and optionally verifying) one dependent.
+type DependentResult struct {
+	// Name is the dependent container name.
+	Name string
+	// Outcome is journal.OutcomeSuccess, OutcomeFailed, OutcomeSkipped (cooldown) or OutcomeAborted
+	// (not restarted, or cut short, because watch-dog shut down).
+	Outcome string
+	// Attempts is the number of restarts issued (0 when skipped).
+	Attempts int
+	// Verified is the verification result ("healthy", "running", a WaitResult name), or "" when not verified.
+	Verified string
+	// Err is the last restart or verification error when Outcome is failed.
+	Err error
+}
+
+// restartDependent restarts one dependent under the cooldown (or recreates it, for run.recreate, or
+// starts it, for run.start; both bypass the cooldown since a restart would not bring the dependent
+// back, as do dependents in run.bypassCooldown), verifies it when VerifyDependents is set (retrying up to DependentRetries times), logs
+// and journals the result.
+func (f *Flow) restartDependent(ctx context.Context, run dependentRun, name string) DependentResult {
+	parentName := run.parent
+	p := f.policy()
+	res := DependentResult{Name: name}
+	step := journal.Entry{Kind: journal.KindStep, AttemptID: run.attemptID, Parent: parentName, Step: journal.StepRestartDependent, Container: name}
+	action, verb, done := f.Client.Restart, "restart", "restarted"
+	switch {
+	case run.recreate[name]:
+		networkMode := "container:" + run.parentID
+		action = func(ctx context.Context, id string) error {
+			_, err := f.Client.Recreate(ctx, id, networkMode)
+			return err
+		}
+		verb, done = "recreate", "recreated"
+		if p.DependentRestartCooldown > 0 {
+			f.markDependentRestart(name)
+		}
+	case run.start:
+		action, verb, done = f.Client.Start, "start", "started"
+		if p.DependentRestartCooldown > 0 {
+			f.markDependentRestart(name)
+		}
+	case run.bypassCooldown[name]:
+		if p.DependentRestartCooldown > 0 {
+			f.markDependentRestart(name)
+		}
+	case p.DependentRestartCooldown > 0 && !f.shouldRestartDependent(name):
+		f.log().Debug("skip dependent restart, within cooldown", "dependent", name, "parent", parentName)
+		res.Outcome = journal.OutcomeSkipped
+		step.Outcome = journal.OutcomeSkipped
+		f.record(step)
+		return res
+	}
+	start := f.clock().Now()
+	maxAttempts := 1
+	if p.VerifyDependents {
+		maxAttempts += max(p.DependentRetries, 0)
+	}
+attempts:
+	for res.Attempts < maxAttempts {
+		if res.Attempts > 0 {
+			f.log().InfoRecovery(fmt.Sprintf("recovery: retrying dependent %q (parent %s, attempt %d/%d)", name, parentName, res.Attempts+1, maxAttempts), "dependent", name, "parent", parentName, "attempt", res.Attempts+1, "error", res.Err)
+			select {
+			case <-ctx.Done():
+				res.Err = ctx.Err()
+				break attempts
+			case <-f.clock().After(dependentRetryBackoff):
+			}
+			// A started dependent that did not come back may be running but stuck: retry with a restart.
+			if verb == "start" {
+				action, verb, done = f.Client.Restart, "restart", "restarted"
+			}
+		}
+		res.Attempts++
+		if err := action(ctx, name); err != nil {
+			f.log().ErrorRecovery(fmt.Sprintf("recovery: failed to %s dependent %q (parent %s)", verb, name, parentName), "dependent", name, "parent", parentName, "error", err)
+			res.Err = err
+			continue
+		}
+		// Running again, however it got there: no longer held by a stop-first recovery.
+		f.releaseHeld(name)
+		if !p.VerifyDependents {
+			f.log().InfoRecovery(fmt.Sprintf("recovery: %s dependent %q (parent %s)", done, name, parentName), "dependent", name, "parent", parentName)
+			res.Err = nil
+			break
+		}
+		verified, err := f.verifyDependent(ctx, name)
+		res.Verified, res.Err = verified, err
+		if err == nil {
+			f.log().InfoRecovery(fmt.Sprintf("recovery: %s dependent %q (parent %s), %s", done, name, parentName, verified), "dependent", name, "parent", parentName, "verified", verified)
+			break
+		}
+		f.log().WarnRecovery(fmt.Sprintf("recovery: dependent %q (parent %s) not back after %s: %v", name, parentName, verb, err), "dependent", name, "parent", parentName, "verified", verified, "error", err)
+	}
+	if res.Err != nil {
+		res.Outcome = journal.OutcomeFailed
+		if ctx.Err() != nil && f.Draining() {
+			// Cut short by the drain timeout: left pending for the next start.
+			res.Outcome = journal.OutcomeAborted
+		}
+		step.Error = res.Err.Error()
+		if p.DependentRestartCooldown > 0 {
+			f.clearDependentCooldown(name)
+		}
+	} else {
+		res.Outcome = journal.OutcomeSuccess
+	}
+	step.Outcome = res.Outcome
+	step.Attempts = res.Attempts
+	step.DurationMS = f.clock().Since(start).Milliseconds()
+	f.record(step)
+	return res
+}
+
+// verifyDependent waits for a restarted dependent to come back: healthy if it has a healthcheck,
+// otherwise running. Returns the observed state ("healthy", "running" or a WaitResult name) and an
+// error when it did not come back.
+func (f *Flow) verifyDependent(ctx context.Context, name string) (string, error) {
+	st, err := f.Client.Inspect(ctx, name)
+	if err != nil {
+		return WaitInspectError.String(), err
+	}
+	if st.Healthcheck == nil && st.Health == "" {
+		if st.Status != "running" {
+			return st.Status, fmt.Errorf("not running after restart (status %s)", st.Status)
+		}
+		return "running", nil
+	}
+	timeout := f.policy().DependentVerifyTimeout
+	if timeout <= 0 {
+		timeout, _ = f.waitHealthyTimeout(&st)
+	}
+	if r := f.WaitUntilHealthy(ctx, name, timeout); r != WaitHealthy {
+		return r.String(), errors.New(r.describe())
+	}
+	return WaitHealthy.String(), nil
+}
+
+// staleNetworkDependents returns the dependents among names that join the parent's network
+// namespace (see discovery.Graph.NetworkParents) but still point at an old parent container
+// ("container:<old id>"), e.g. because the parent was recreated. A restart cannot fix them.
+func (f *Flow) staleNetworkDependents(ctx context.Context, run dependentRun, graph *discovery.Graph, names []string) map[string]bool {
+	var stale map[string]bool
+	for _, name := range names {
+		if !graph.SharesNetwork(name, run.parent) {
+			continue
+		}
+		st, err := f.Client.Inspect(ctx, name)
+		if err != nil {
+			f.log().Debug("recovery: inspect network dependent failed", "dependent", name, "parent", run.parent, "error", err)
+			continue
+		}
+		target, ok := strings.CutPrefix(st.NetworkMode, "container:")
+		if !ok || target == run.parent || strings.HasPrefix(run.parentID, target) {
+			continue
+		}
+		f.log().InfoRecovery(fmt.Sprintf("recovery: dependent %q joins a stale network namespace of parent %s, recreating it", name, run.parent), "dependent", name, "parent", run.parent, "network_mode", st.NetworkMode)
+		if stale == nil {
+			stale = make(map[string]bool)
+		}
+		stale[name] = true
+	}
+	return stale
+}
+
+// waitStageConditions waits, after a stage of dependents was restarted, for the depends_on
+// conditions that the dependents of later stages declare on the stage's containers: healthy for
+// service_healthy, exited with code 0 for service_completed_successfully (service_started needs no
+// wait). Dependents that failed to restart are not waited for. A condition that is not met is
+// logged and the next stage is restarted anyway, so one broken dependent does not block the rest.
+func (f *Flow) waitStageConditions(ctx context.Context, parentName string, graph *discovery.Graph, stage []DependentResult, later []string) {
+	for _, r := range stage {
+		if r.Outcome == journal.OutcomeFailed {
+			continue
+		}
+		condition := discovery.ConditionStarted
+		for _, d := range later {
+			if !slices.Contains(graph.GetDependents(r.Name), d) {
+				continue
+			}
+			switch c := graph.Condition(d, r.Name); {
+			case c == discovery.ConditionCompletedSuccessfully:
+				condition = c
+			case c == discovery.ConditionHealthy && condition == discovery.ConditionStarted:
+				condition = c
+			}
+		}
+		if condition == discovery.ConditionStarted {
+			continue
+		}
+		start := f.clock().Now()
+		err := f.waitCondition(ctx, r.Name, condition)
+		if err != nil {
+			f.log().WarnRecovery(fmt.Sprintf("recovery: dependent %q (parent %s) did not reach %s: %v", r.Name, parentName, condition, err), "dependent", r.Name, "parent", parentName, "condition", condition, "error", err)
+			continue
+		}
+		f.log().Debug("dependent reached condition", "dependent", r.Name, "parent", parentName, "condition", condition, "after", f.clock().Since(start).Round(time.Millisecond).String())
+	}
+}
+
+// waitCondition waits until the container satisfies a depends_on condition. The timeout is
+// DependentVerifyTimeout or, when unset, derived as for the parent (see waitHealthyTimeout).
+func (f *Flow) waitCondition(ctx context.Context, name, condition string) error {
+	if condition == discovery.ConditionHealthy {
+		_, err := f.verifyDependent(ctx, name)
+		return err
+	}
+	timeout := f.policy().DependentVerifyTimeout
+	if timeout <= 0 {
+		st, err := f.Client.Inspect(ctx, name)
+		if err != nil {
+			return err
+		}
+		timeout, _ = f.waitHealthyTimeout(&st)
+	}
+	return f.waitCompleted(ctx, name, timeout)
+}
+
+// sequenceOutcome combines dependent results into the sequence outcome: aborted when shutdown left
+// dependents pending, success when no dependent failed, failed when every restarted dependent
+// failed, partial otherwise. summary counts results,
+// e.g. "2 ok, 1 failed (torrent), 1 skipped".
+func sequenceOutcome(results []DependentResult) (outcome, summary string) {
+	var ok, skipped int
+	var failed, pending []string
+	for _, r := range results {
+		switch r.Outcome {
+		case journal.OutcomeSuccess:
+			ok++
+		case journal.OutcomeSkipped:
+			skipped++
+		case journal.OutcomeAborted:
+			pending = append(pending, r.Name)
+		default:
+			failed = append(failed, r.Name)
+		}
+	}
+	summary = fmt.Sprintf("%d ok", ok)
+	if len(failed) > 0 {
+		summary += fmt.Sprintf(", %d failed %v", len(failed), failed)
+	}
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	if len(pending) > 0 {
+		summary += fmt.Sprintf(", %d pending %v", len(pending), pending)
+	}
+	switch {
+	case len(pending) > 0:
+		return journal.OutcomeAborted, summary
+	case len(failed) == 0:
+		return journal.OutcomeSuccess, summary
+	case ok == 0:
+		return journal.OutcomeFailed, summary
+	default:
+		return journal.OutcomePartial, summary
+	}
+}
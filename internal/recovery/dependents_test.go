@@ -0,0 +1,193 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"watch-dog/internal/clock/clocktest"
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/journal"
+)
+
+// restartWithBackoff runs RestartDependents on a fake clock, moving it past the retry backoff
+// whenever the flow waits on a timer, and returns the results. Verification timeouts must be long
+// enough not to expire meanwhile.
+func restartWithBackoff(t *testing.T, flow *Flow, parentName string, deps *discovery.ParentToDependents) []DependentResult {
+	t.Helper()
+	clk := clocktest.New(epoch)
+	flow.Clock = clk
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan []DependentResult, 1)
+	go func() {
+		done <- flow.RestartDependents(context.Background(), parentName, deps, "")
+		cancel()
+	}()
+	for {
+		if err := clk.WaitTimers(ctx, 1); err != nil {
+			select {
+			case results := <-done:
+				return results
+			default:
+				t.Fatalf("dependents not restarted: %v", err)
+			}
+		}
+		clk.Advance(dependentRetryBackoff)
+	}
+}
+
+func TestRestartDependents_verifyRetriesFailedRestart(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string), nextRestartErr: errors.New("fake restart failure")}
+	flow := &Flow{Client: fake, VerifyDependents: true, DependentRetries: 2, DependentVerifyTimeout: time.Hour}
+	parentToDeps := discovery.ParentToDependents{"parent1": {"dep-a"}}
+
+	results := restartWithBackoff(t, flow, "parent1", &parentToDeps)
+
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if r := results[0]; r.Outcome != journal.OutcomeSuccess || r.Attempts != 2 || r.Verified != "healthy" || r.Err != nil {
+		t.Errorf("result = %+v, want success after 2 attempts, verified healthy", r)
+	}
+}
+
+func TestRestartDependents_verifyFailsAfterRetryBudget(t *testing.T) {
+	fake := &fakeClient{events: map[string][]engine.HealthEvent{"dep-a": {{ContainerID: "dep-a", Status: "health_status: unhealthy"}}}}
+	fake.inspect = map[string]string{"dep-a": "starting"}
+	flow := &Flow{Client: fake, VerifyDependents: true, DependentRetries: 1, DependentVerifyTimeout: time.Hour}
+	parentToDeps := discovery.ParentToDependents{"parent1": {"dep-a", "dep-b"}}
+
+	results := restartWithBackoff(t, flow, "parent1", &parentToDeps)
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if a := results[0]; a.Outcome != journal.OutcomeFailed || a.Attempts != 2 || a.Verified != "unhealthy" || a.Err == nil {
+		t.Errorf("dep-a result = %+v, want failed after 2 attempts (unhealthy)", a)
+	}
+	if b := results[1]; b.Outcome != journal.OutcomeSuccess || b.Attempts != 1 {
+		t.Errorf("dep-b result = %+v, want success after 1 attempt", b)
+	}
+	if outcome, _ := sequenceOutcome(results); outcome != journal.OutcomePartial {
+		t.Errorf("sequenceOutcome = %q, want partial", outcome)
+	}
+}
+
+func TestRestartDependents_concurrentKeepsSelfLastAndAlone(t *testing.T) {
+	restarting, release := make(chan string, 8), make(chan struct{})
+	fake := &fakeClient{inspect: make(map[string]string), restarting: restarting, release: release}
+	flow := &Flow{Client: fake, DependentConcurrency: 2}
+	parentToDeps := discovery.ParentToDependents{"vpn": {"app-d", "watch-dog", "app-a", "app-c", "app-b"}}
+
+	done := make(chan []DependentResult)
+	go func() { done <- flow.RestartDependents(context.Background(), "vpn", &parentToDeps, "watch-dog") }()
+	// Two restarts are running at once before any of them finishes.
+	<-restarting
+	<-restarting
+	close(release)
+	results := <-done
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	want := []string{"app-a", "app-b", "app-c", "app-d", "watch-dog"}
+	if !slices.Equal(names, want) {
+		t.Errorf("result order = %v, want %v", names, want)
+	}
+	restarts := fake.getRestarts()
+	if len(restarts) != 5 || restarts[4] != "watch-dog" {
+		t.Errorf("restarts = %v, want watch-dog last", restarts)
+	}
+	if fake.maxInFlight != 2 {
+		t.Errorf("max concurrent restarts = %d, want 2", fake.maxInFlight)
+	}
+}
+
+func TestRestartDependentsInGraph_restartsInDependencyOrder(t *testing.T) {
+	fake := &fakeClient{inspect: map[string]string{"db": "healthy"}}
+	flow := &Flow{Client: fake, DependentConcurrency: 4}
+	graph := discovery.NewGraph(discovery.ParentToDependents{
+		"vpn":        {"api", "cache", "db", "network-gw"},
+		"network-gw": {"cache", "db"},
+		"db":         {"api"},
+		"cache":      {"api"},
+	})
+	graph.Conditions["api"] = map[string]string{"db": discovery.ConditionHealthy}
+
+	flow.RestartDependentsInGraph(context.Background(), "vpn", graph, "")
+
+	restarts := fake.getRestarts()
+	pos := make(map[string]int)
+	for i, name := range restarts {
+		pos[name] = i
+	}
+	if len(restarts) != 4 || pos["network-gw"] != 0 || pos["api"] != 3 {
+		t.Errorf("restarts = %v, want network-gw first and api last", restarts)
+	}
+}
+
+func TestRunFullSequence_recreatesDependentsOnStaleNetworkNamespace(t *testing.T) {
+	fake := &fakeClient{
+		inspect:      make(map[string]string),
+		networkModes: map[string]string{"torrent": "container:old-vpn-id", "web": "container:vpn-id"},
+	}
+	flow := &Flow{Client: fake}
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent", "web", "db"}})
+	graph.NetworkParents["torrent"] = "vpn"
+	graph.NetworkParents["web"] = "vpn"
+
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", graph, "")
+
+	want := []string{"restart:vpn-id", "restart:db", "recreate:torrent:container:vpn-id", "restart:web"}
+	if !slices.Equal(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+}
+
+func TestDependentConcurrency_parentLabelOverridesFlow(t *testing.T) {
+	flow := &Flow{DependentConcurrency: 2}
+	tests := []struct {
+		label string
+		want  int
+	}{
+		{"", 2},
+		{"8", 8},
+		{"0", 2},
+		{"many", 2},
+	}
+	for _, tt := range tests {
+		st := &engine.ContainerState{Labels: map[string]string{LabelDependentConcurrency: tt.label}}
+		if got := flow.dependentConcurrency(st); got != tt.want {
+			t.Errorf("label %q: got %d, want %d", tt.label, got, tt.want)
+		}
+	}
+	if got := flow.dependentConcurrency(nil); got != 2 {
+		t.Errorf("nil state: got %d, want 2", got)
+	}
+}
+
+func TestSequenceOutcome(t *testing.T) {
+	ok := DependentResult{Name: "a", Outcome: journal.OutcomeSuccess}
+	failed := DependentResult{Name: "b", Outcome: journal.OutcomeFailed, Err: errors.New("x")}
+	skipped := DependentResult{Name: "c", Outcome: journal.OutcomeSkipped}
+	tests := []struct {
+		name    string
+		results []DependentResult
+		want    string
+	}{
+		{"none", nil, journal.OutcomeSuccess},
+		{"all ok or skipped", []DependentResult{ok, skipped}, journal.OutcomeSuccess},
+		{"some failed", []DependentResult{ok, failed}, journal.OutcomePartial},
+		{"all failed", []DependentResult{failed, skipped}, journal.OutcomeFailed},
+	}
+	for _, tt := range tests {
+		if got, summary := sequenceOutcome(tt.results); got != tt.want {
+			t.Errorf("%s: sequenceOutcome = %q (%s), want %q", tt.name, got, summary, tt.want)
+		}
+	}
+}
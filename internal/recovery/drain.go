@@ -0,0 +1,148 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/journal"
+)
+
+// Drain stops the Flow from starting new recovery sequences and makes running ones stop after
+// their current step; the dependents they did not get to are journaled as pending (see
+// ResumeDependents). It returns once no sequence is running, or with ctx's error when ctx ends
+// first; the caller then cancels the sequences' context so the current steps are cut short.
+func (f *Flow) Drain(ctx context.Context) error {
+	f.mu.Lock()
+	f.draining.Store(true)
+	f.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		f.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Draining reports whether Drain was called.
+func (f *Flow) Draining() bool {
+	return f.draining.Load()
+}
+
+// begin registers a sequence with Drain. It returns false when the Flow is draining; otherwise the
+// caller must call f.running.Done when the sequence ends.
+func (f *Flow) begin() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining.Load() {
+		return false
+	}
+	f.running.Add(1)
+	return true
+}
+
+// aborted reports whether a sequence should stop before its next step: the Flow is draining or
+// the context was canceled (drain timeout).
+func (f *Flow) aborted(ctx context.Context) bool {
+	return f.Draining() || ctx.Err() != nil
+}
+
+// plannedDependents returns every dependent a sequence for parentName restarts, in restart order.
+func plannedDependents(parentName string, graph *discovery.Graph, selfName string) []string {
+	stages, hasSelf := dependentStages(parentName, graph, selfName)
+	names := slices.Concat(stages...)
+	if hasSelf {
+		names = append(names, selfName)
+	}
+	return names
+}
+
+// pendingDependents returns the dependents of results that were not restarted because the
+// sequence was aborted.
+func pendingDependents(results []DependentResult) []string {
+	var pending []string
+	for _, r := range results {
+		if r.Outcome == journal.OutcomeAborted {
+			pending = append(pending, r.Name)
+		}
+	}
+	return pending
+}
+
+// ResumeDependents restarts the dependents that an aborted or interrupted sequence for parentName
+// left pending (see journal.State.Pending), in dependency order and regardless of the dependent
+// cooldown. It only runs when the parent is healthy (or running, without a healthcheck);
+// otherwise it returns false and the parent is left to regular recovery, which restarts all its
+// dependents anyway. Dependents that are no longer dependents of the parent in graph are ignored,
+// and so is selfName: watch-dog running again means its own restart already happened. The run is
+// journaled as an attempt with trigger "resume" and its own plan, so resuming is itself resumable.
+func (f *Flow) ResumeDependents(ctx context.Context, parentName string, pending []string, graph *discovery.Graph, selfName string) bool {
+	pending = slices.DeleteFunc(slices.Clone(pending), func(name string) bool { return name == selfName })
+	return f.runDependentsOf(ctx, parentName, pending, graph, selfName, false)
+}
+
+// runDependentsOf implements ResumeDependents (restarting names) and, with start, StartHeld
+// (starting names).
+func (f *Flow) runDependentsOf(ctx context.Context, parentName string, names []string, graph *discovery.Graph, selfName string, start bool) bool {
+	if !f.begin() {
+		return false
+	}
+	defer f.running.Done()
+	trigger, reason, verb, action, done := "resume", "interrupted recovery", "resume", "resuming restart of", "resumed"
+	if start {
+		trigger, reason, verb, action, done = "held", "parent healthy again", "start held", "starting held", "started held"
+	}
+	st, err := f.Client.Inspect(ctx, parentName)
+	if err != nil {
+		f.log().WarnRecovery(fmt.Sprintf("recovery: cannot %s dependents of parent %q: %v", verb, parentName, err), "parent", parentName, "error", err)
+		return false
+	}
+	if st.Health != "healthy" && (st.Health != "" || st.Status != "running") {
+		f.log().InfoRecovery(fmt.Sprintf("recovery: not %s dependents of parent %q, parent is not healthy (status %s, health %s)", action, parentName, st.Status, st.Health), "parent", parentName, "status", st.Status, "health", st.Health)
+		return false
+	}
+	started := f.clock().Now()
+	run := dependentRun{attemptID: newAttemptID(), parent: parentName, concurrency: f.dependentConcurrency(&st), start: start, only: make(map[string]bool), bypassCooldown: make(map[string]bool)}
+	for _, name := range names {
+		run.only[name] = true
+		run.bypassCooldown[name] = true
+	}
+	stages, _ := dependentStages(parentName, graph, selfName)
+	attempt := journal.Entry{Kind: journal.KindAttempt, AttemptID: run.attemptID, Parent: parentName, Project: st.Labels[labelComposeProject], Trigger: trigger, Reason: reason, Time: started}
+	attempt.Plan = dependentPlan(slices.Concat(onlyDependents(stages, run.only)...))
+	f.log().InfoRecovery(fmt.Sprintf("recovery: %s dependents %v of parent %q", action, names, parentName), "parent", parentName, "dependents", names)
+	f.record(attempt)
+	results := f.restartDependents(ctx, run, graph, selfName)
+	result, summary := sequenceOutcome(results)
+	outcome := journal.Entry{Kind: journal.KindOutcome, AttemptID: attempt.AttemptID, Parent: parentName, Project: attempt.Project, Trigger: attempt.Trigger, Reason: attempt.Reason, Outcome: result, DurationMS: f.clock().Since(started).Milliseconds()}
+	if result != journal.OutcomeSuccess {
+		outcome.Error = summary
+	}
+	if result == journal.OutcomeAborted {
+		outcome.Pending = pendingDependents(results)
+	}
+	f.record(outcome)
+	f.log().InfoRecovery(fmt.Sprintf("recovery: %s dependents of parent %q (outcome: %s, %s)", done, parentName, result, summary), "parent", parentName, "outcome", result, "dependents", summary)
+	return true
+}
+
+// onlyDependents filters stages to the names in only (nil keeps all), dropping empty stages.
+func onlyDependents(stages [][]string, only map[string]bool) [][]string {
+	if only == nil {
+		return stages
+	}
+	var out [][]string
+	for _, stage := range stages {
+		stage = slices.DeleteFunc(slices.Clone(stage), func(name string) bool { return !only[name] })
+		if len(stage) > 0 {
+			out = append(out, stage)
+		}
+	}
+	return out
+}
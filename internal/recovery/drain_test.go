@@ -0,0 +1,112 @@
+package recovery
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"watch-dog/internal/clock/clocktest"
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/journal"
+)
+
+func TestDrain_refusesNewSequences(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	j := &memJournal{}
+	flow := &Flow{Client: fake, Journal: j}
+	if err := flow.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent"}}), "")
+	if got := fake.getRestarts(); len(got) != 0 || len(j.entries) != 0 {
+		t.Errorf("draining flow ran a sequence: restarts %v, journal %v", got, j.entries)
+	}
+}
+
+func TestDrain_abortsAfterCurrentStepAndJournalsPending(t *testing.T) {
+	restarting, release := make(chan string, 1), make(chan struct{})
+	fake := &fakeClient{inspect: make(map[string]string), restarting: restarting, release: release}
+	j := &memJournal{}
+	flow := &Flow{Client: fake, Journal: j}
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent", "sonarr"}})
+
+	done := make(chan struct{})
+	go func() {
+		flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", graph, "")
+		close(done)
+	}()
+	<-restarting // parent restart in progress
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	if err := flow.Drain(expired); err == nil {
+		t.Fatal("Drain returned before the running sequence ended")
+	}
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := flow.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	<-done
+
+	if got := fake.getRestarts(); !slices.Equal(got, []string{"vpn-id"}) {
+		t.Errorf("restarts = %v, want only the parent (current step finishes)", got)
+	}
+	last := j.entries[len(j.entries)-1]
+	if last.Kind != journal.KindOutcome || last.Outcome != journal.OutcomeAborted || !slices.Equal(last.Pending, []string{"sonarr", "torrent"}) {
+		t.Errorf("outcome = %+v, want aborted with pending [sonarr torrent]", last)
+	}
+}
+
+func TestResumeDependents_restartsOnlyPending(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	j := &memJournal{}
+	flow := &Flow{Client: fake, Journal: j, DependentRestartCooldown: time.Hour, Clock: clocktest.New(epoch)}
+	flow.RestoreDependentRestarts(map[string]time.Time{"torrent": epoch}) // cooldown does not apply to resumed dependents
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent", "sonarr", "web"}})
+
+	if !flow.ResumeDependents(context.Background(), "vpn", []string{"torrent", "web", "gone"}, graph, "") {
+		t.Fatal("ResumeDependents = false, want true for healthy parent")
+	}
+	if got := fake.getRestarts(); !slices.Equal(got, []string{"torrent", "web"}) {
+		t.Errorf("restarts = %v, want [torrent web]", got)
+	}
+	if j.entries[0].Trigger != "resume" || j.entries[len(j.entries)-1].Outcome != journal.OutcomeSuccess {
+		t.Errorf("journal = %+v, want resume attempt with success outcome", j.entries)
+	}
+
+	fake.inspect["vpn"] = "unhealthy"
+	if flow.ResumeDependents(context.Background(), "vpn", []string{"torrent"}, graph, "") {
+		t.Error("ResumeDependents = true for unhealthy parent")
+	}
+}
+
+func TestRunFullSequence_journalsPlanBeforeFirstStep(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	j := &memJournal{}
+	flow := &Flow{Client: fake, Journal: j, Strategy: StrategyStopFirst}
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent", "watch-dog"}})
+
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", graph, "watch-dog")
+
+	var got []string
+	for _, s := range j.entries[0].Plan {
+		got = append(got, s.Step+":"+s.Container)
+	}
+	want := []string{"stop_dependent:torrent", "restart_parent:vpn", "wait_healthy:vpn", "restart_dependent:torrent", "restart_dependent:watch-dog"}
+	if j.entries[0].Kind != journal.KindAttempt || !slices.Equal(got, want) {
+		t.Errorf("plan = %v, want %v", got, want)
+	}
+}
+
+func TestResumeDependents_skipsSelf(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	flow := &Flow{Client: fake}
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent", "watch-dog"}})
+
+	flow.ResumeDependents(context.Background(), "vpn", []string{"torrent", "watch-dog"}, graph, "watch-dog")
+	if got := fake.getRestarts(); !slices.Equal(got, []string{"torrent"}) {
+		t.Errorf("restarts = %v, want [torrent] (watch-dog already restarted)", got)
+	}
+}
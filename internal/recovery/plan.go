@@ -0,0 +1,37 @@
+package recovery
+
+import (
+	"slices"
+
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/journal"
+)
+
+// planSteps returns the steps a sequence for parentName runs with strategy, in order, for the
+// attempt's journal entry (see journal.Entry.Plan). Log capture happens before the attempt is
+// journaled and is not part of the plan.
+func planSteps(parentName, strategy string, graph *discovery.Graph, selfName string) []journal.PlanStep {
+	var plan []journal.PlanStep
+	if strategy == StrategyStopFirst {
+		stages, _ := dependentStages(parentName, graph, selfName)
+		for _, stage := range slices.Backward(stages) {
+			for _, name := range stage {
+				plan = append(plan, journal.PlanStep{Step: journal.StepStopDependent, Container: name})
+			}
+		}
+	}
+	plan = append(plan,
+		journal.PlanStep{Step: journal.StepRestartParent, Container: parentName},
+		journal.PlanStep{Step: journal.StepWaitHealthy, Container: parentName},
+	)
+	return append(plan, dependentPlan(plannedDependents(parentName, graph, selfName))...)
+}
+
+// dependentPlan returns a restart_dependent step for each of names.
+func dependentPlan(names []string) []journal.PlanStep {
+	plan := make([]journal.PlanStep, 0, len(names))
+	for _, name := range names {
+		plan = append(plan, journal.PlanStep{Step: journal.StepRestartDependent, Container: name})
+	}
+	return plan
+}
@@ -0,0 +1,40 @@
+package recovery
+
+import "time"
+
+// Policy is the part of a Flow's configuration that can be replaced while it runs (see SetPolicy).
+// Its fields mean the same as the Flow fields of the same name.
+type Policy struct {
+	DependentRestartCooldown time.Duration
+	WaitHealthyTimeout       time.Duration
+	Capture                  *LogCapture
+	VerifyDependents         bool
+	DependentVerifyTimeout   time.Duration
+	DependentConcurrency     int
+	DependentRetries         int
+	Strategy                 string
+}
+
+// SetPolicy atomically replaces the Flow's settings (e.g. on a configuration reload). Cooldowns,
+// held-stopped dependents and recoveries in progress are kept; running sequences use the new
+// settings from their next step on. Once SetPolicy was called, the Flow's policy fields are ignored.
+func (f *Flow) SetPolicy(p Policy) {
+	f.current.Store(&p)
+}
+
+// policy returns the settings set by SetPolicy, or the Flow's fields if it was never called.
+func (f *Flow) policy() Policy {
+	if p := f.current.Load(); p != nil {
+		return *p
+	}
+	return Policy{
+		DependentRestartCooldown: f.DependentRestartCooldown,
+		WaitHealthyTimeout:       f.WaitHealthyTimeout,
+		Capture:                  f.Capture,
+		VerifyDependents:         f.VerifyDependents,
+		DependentVerifyTimeout:   f.DependentVerifyTimeout,
+		DependentConcurrency:     f.DependentConcurrency,
+		DependentRetries:         f.DependentRetries,
+		Strategy:                 f.Strategy,
+	}
+}
@@ -0,0 +1,38 @@
+package recovery
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"watch-dog/internal/discovery"
+)
+
+func TestSetPolicy_replacesSettingsAndKeepsCooldowns(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	flow := &Flow{Client: fake, DependentRestartCooldown: time.Hour}
+	deps := &discovery.ParentToDependents{"vpn": {"torrent"}}
+
+	flow.RestartDependents(context.Background(), "vpn", deps, "")
+	if got := flow.policy().DependentRestartCooldown; got != time.Hour {
+		t.Fatalf("policy before SetPolicy uses the Flow fields: cooldown = %s", got)
+	}
+
+	// A reload switches to stop-first and keeps the dependent cooldown from the first restart.
+	flow.SetPolicy(Policy{DependentRestartCooldown: time.Hour, Strategy: StrategyStopFirst})
+	if got := flow.recoveryStrategy(nil); got != StrategyStopFirst {
+		t.Errorf("strategy after SetPolicy = %q", got)
+	}
+	flow.RestartDependents(context.Background(), "vpn", deps, "")
+	if want := []string{"torrent"}; !slices.Equal(fake.restarts, want) {
+		t.Errorf("restarts = %v, want %v (second restart within the kept cooldown)", fake.restarts, want)
+	}
+
+	// Without a cooldown the dependent is restarted again.
+	flow.SetPolicy(Policy{})
+	flow.RestartDependents(context.Background(), "vpn", deps, "")
+	if want := []string{"torrent", "torrent"}; !slices.Equal(fake.restarts, want) {
+		t.Errorf("restarts = %v, want %v", fake.restarts, want)
+	}
+}
@@ -4,33 +4,82 @@ package recovery
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"watch-dog/internal/clock"
 	"watch-dog/internal/discovery"
 	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/journal"
 )
 
 const defaultWaitHealthyTimeout = 5 * time.Minute
 
-// dockerClient is the subset of Docker API used by Flow (for testing with fakes).
-type dockerClient interface {
-	Restart(ctx context.Context, containerID string) error
-	Inspect(ctx context.Context, containerID string) (health string, labels map[string]string, err error)
+// labelComposeProject is the compose project label recorded in the journal for each attempt.
+const labelComposeProject = "com.docker.compose.project"
+
+// Recorder persists recovery journal entries (implemented by *journal.Journal).
+type Recorder interface {
+	Append(e journal.Entry) error
 }
 
 // Flow runs the full recovery sequence: restart parent, wait until healthy, restart dependents.
 type Flow struct {
-	// Client is the Docker client used for restart and inspect.
-	Client dockerClient
+	// Client is the container runtime used for restart and inspect.
+	Client engine.Runtime
 	// DependentRestartCooldown is the minimum time between restarts of the same dependent (0 = disabled).
 	// When multiple parents of the same dependent recover in quick succession, the dependent is restarted at most once per this window.
 	DependentRestartCooldown time.Duration
+	// WaitHealthyTimeout is the wait-healthy timeout for parents without a healthcheck or timeout label (0 = 5m).
+	WaitHealthyTimeout time.Duration
+	// Capture, when non-nil, saves the parent's recent logs before it is restarted (post-mortem evidence).
+	Capture *LogCapture
+	// Journal, when non-nil, receives an entry for every recovery attempt, step and outcome.
+	Journal Recorder
+	// VerifyDependents enables the verification phase: after restart, each dependent must become
+	// healthy (or be running, if it has no healthcheck) within DependentVerifyTimeout.
+	VerifyDependents bool
+	// DependentVerifyTimeout bounds the wait for one dependent (0 = derived from its healthcheck, like the parent).
+	DependentVerifyTimeout time.Duration
+	// DependentConcurrency is how many dependents of one parent are restarted at a time (<= 1 = one at a time).
+	// A parent can override it with the LabelDependentConcurrency label.
+	DependentConcurrency int
+	// DependentRetries is how many more times a dependent that failed to restart or verify is
+	// restarted (only with VerifyDependents).
+	DependentRetries int
+	// Strategy is StrategyRestart (default when empty) or StrategyStopFirst. A parent can override
+	// it with the LabelRecoveryStrategy label.
+	Strategy string
+	// Host names the Docker endpoint Client talks to; it labels log lines (host=<name>) and journal
+	// entries. Empty with a single endpoint.
+	Host string
+	// Clock times cooldowns, waits, retries and step durations (nil = clock.Real).
+	Clock clock.Clock
+
+	// current is the policy set by SetPolicy, replacing the fields above.
+	current atomic.Pointer[Policy]
+
+	// draining is set by Drain; running counts sequences in progress (see begin).
+	draining atomic.Bool
+	running  sync.WaitGroup
 
 	mu                   sync.Mutex
 	lastDependentRestart map[string]time.Time
+	// heldStopped maps dependents stopped by the stop-first strategy, until they are started again,
+	// to the parent whose recovery stopped them.
+	heldStopped map[string]string
+}
+
+// clock returns the Flow's clock.
+func (f *Flow) clock() clock.Clock {
+	return clock.Or(f.Clock)
 }
 
 // RestartParent restarts the container by ID or name (idempotent).
@@ -38,35 +87,6 @@ func (f *Flow) RestartParent(ctx context.Context, containerID string) error {
 	return f.Client.Restart(ctx, containerID)
 }
 
-// WaitUntilHealthy polls the container's health status until "healthy" or timeout.
-// If timeout is reached, returns false (caller must not restart dependents).
-func (f *Flow) WaitUntilHealthy(ctx context.Context, containerID string, timeout time.Duration) bool {
-	if timeout <= 0 {
-		timeout = defaultWaitHealthyTimeout
-	}
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-	for {
-		health, _, err := f.Client.Inspect(ctx, containerID)
-		if err != nil {
-			docker.LogErrorRecovery(fmt.Sprintf("recovery: inspect after restart failed (container %s)", containerID), "container", containerID, "error", err)
-			return false
-		}
-		if health == "healthy" {
-			return true
-		}
-		if time.Now().After(deadline) {
-			return false
-		}
-		select {
-		case <-ctx.Done():
-			return false
-		case <-ticker.C:
-		}
-	}
-}
-
 // shouldRestartDependent reports whether the dependent name may be restarted under cooldown,
 // and if so updates the last-restart timestamp. Caller must hold no locks.
 func (f *Flow) shouldRestartDependent(name string) bool {
@@ -76,13 +96,23 @@ func (f *Flow) shouldRestartDependent(name string) bool {
 		f.lastDependentRestart = make(map[string]time.Time)
 	}
 	last := f.lastDependentRestart[name]
-	if !last.IsZero() && time.Since(last) < f.DependentRestartCooldown {
+	if !last.IsZero() && f.clock().Since(last) < f.policy().DependentRestartCooldown {
 		return false
 	}
-	f.lastDependentRestart[name] = time.Now()
+	f.lastDependentRestart[name] = f.clock().Now()
 	return true
 }
 
+// markDependentRestart starts the dependent's cooldown window now, regardless of an earlier restart.
+func (f *Flow) markDependentRestart(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lastDependentRestart == nil {
+		f.lastDependentRestart = make(map[string]time.Time)
+	}
+	f.lastDependentRestart[name] = f.clock().Now()
+}
+
 // clearDependentCooldown removes the dependent from the cooldown map so a subsequent restart is allowed (e.g. after a failed restart).
 func (f *Flow) clearDependentCooldown(name string) {
 	f.mu.Lock()
@@ -92,66 +122,341 @@ func (f *Flow) clearDependentCooldown(name string) {
 	}
 }
 
-// RestartDependents restarts all containers that list parentName in depends_on,
-// one at a time in deterministic order (sorted by name). If selfName is non-empty
-// and present in the list, it is restarted last so in-flight operations are not canceled.
+// RestartDependents restarts all containers that list parentName in depends_on, in dependency
+// order: dependents that depend on other dependents of the same parent are restarted in a later
+// stage, names break ties (see discovery.Graph.Stages). Within a stage up to DependentConcurrency
+// dependents are restarted at a time (default one at a time). If selfName is non-empty and present
+// in the list, it is restarted last and alone, after all other dependents finished, so in-flight
+// operations are not canceled.
 // If DependentRestartCooldown is set, a dependent that was restarted within that window is skipped (at most one restart per dependent per cooldown).
-// discovery may be nil; then no dependents are restarted.
-func (f *Flow) RestartDependents(ctx context.Context, parentName string, discovery *discovery.ParentToDependents, selfName string) {
-	if discovery == nil {
+// When VerifyDependents is set, each restarted dependent must become healthy (or running, without a
+// healthcheck) and is retried up to DependentRetries times otherwise.
+// discovery may be nil; then no dependents are restarted. Returns one result per dependent, in restart order.
+func (f *Flow) RestartDependents(ctx context.Context, parentName string, discovery *discovery.ParentToDependents, selfName string) []DependentResult {
+	return f.restartDependents(ctx, dependentRun{parent: parentName, concurrency: f.policy().DependentConcurrency}, graphOf(discovery), selfName)
+}
+
+// RestartDependentsInGraph is RestartDependents for a dependency graph: before the next stage
+// starts, it waits for every depends_on condition (service_healthy, service_completed_successfully)
+// that later dependents declare on the containers of the current stage.
+func (f *Flow) RestartDependentsInGraph(ctx context.Context, parentName string, graph *discovery.Graph, selfName string) []DependentResult {
+	return f.restartDependents(ctx, dependentRun{parent: parentName, concurrency: f.policy().DependentConcurrency}, graph, selfName)
+}
+
+// graphOf wraps m in a graph without conditions; nil stays nil.
+func graphOf(m *discovery.ParentToDependents) *discovery.Graph {
+	if m == nil {
+		return nil
+	}
+	return discovery.NewGraph(*m)
+}
+
+// dependentRun describes one pass over a parent's dependents.
+type dependentRun struct {
+	// attemptID is the journal attempt the steps are recorded under ("" outside RunFullSequence).
+	attemptID string
+	// parent is the parent container name.
+	parent string
+	// parentID is the parent's current container ID, if known (enables recreating stale network dependents).
+	parentID string
+	// concurrency is how many dependents of a stage are handled at a time (<= 1 = sequential).
+	concurrency int
+	// start starts dependents that the stop-first strategy stopped, instead of restarting them.
+	start bool
+	// recreate holds dependents that must be recreated to rejoin the parent's network namespace.
+	recreate map[string]bool
+	// bypassCooldown holds dependents restarted even within the cooldown (see discovery.EdgeKind.BypassesCooldown).
+	bypassCooldown map[string]bool
+	// only, when non-nil, restricts the run to these dependents (see ResumeDependents).
+	only map[string]bool
+}
+
+// restartDependents implements RestartDependentsInGraph for run.
+func (f *Flow) restartDependents(ctx context.Context, run dependentRun, graph *discovery.Graph, selfName string) []DependentResult {
+	stages, restartSelf := dependentStages(run.parent, graph, selfName)
+	stages = onlyDependents(stages, run.only)
+	restartSelf = restartSelf && (run.only == nil || run.only[selfName])
+	all := slices.Concat(stages...)
+	if run.parentID != "" {
+		run.recreate = f.staleNetworkDependents(ctx, run, graph, all)
+	}
+	for _, name := range all {
+		if graph.BypassesCooldown(name, run.parent) {
+			if run.bypassCooldown == nil {
+				run.bypassCooldown = make(map[string]bool)
+			}
+			run.bypassCooldown[name] = true
+		}
+	}
+	results := make([]DependentResult, 0, len(stages)+1)
+	for i, stage := range stages {
+		if len(stages) > 1 {
+			f.log().Debug("restarting dependents stage", "parent", run.parent, "stage", i+1, "of", len(stages), "dependents", stage)
+		}
+		stageResults := forEachDependent(stage, run.concurrency, func(name string) DependentResult {
+			if f.aborted(ctx) {
+				return DependentResult{Name: name, Outcome: journal.OutcomeAborted}
+			}
+			return f.restartDependent(ctx, run, name)
+		})
+		results = append(results, stageResults...)
+		if i < len(stages)-1 && !f.aborted(ctx) {
+			f.waitStageConditions(ctx, run.parent, graph, stageResults, slices.Concat(stages[i+1:]...))
+		}
+	}
+	if restartSelf {
+		if f.aborted(ctx) {
+			return append(results, DependentResult{Name: selfName, Outcome: journal.OutcomeAborted})
+		}
+		// Self was never stopped, so it is restarted even under stop-first.
+		selfRun := run
+		selfRun.start = false
+		results = append(results, f.restartDependent(ctx, selfRun, selfName))
+	}
+	return results
+}
+
+// dependentStages returns the parent's dependents without selfName in restart stages (see
+// discovery.Graph.Stages), and whether selfName is one of them. A dependent listed twice appears once.
+func dependentStages(parentName string, graph *discovery.Graph, selfName string) (stages [][]string, hasSelf bool) {
+	if graph == nil {
 		docker.LogDebug("no discovery available, skipping restart of dependents", "parentName", parentName)
-		return
+		return nil, false
 	}
-	deps := discovery.GetDependents(parentName)
+	deps := graph.GetDependents(parentName)
 	if len(deps) == 0 {
-		return
+		return nil, false
 	}
 	ordered := slices.Clone(deps)
-	// Deterministic order: sort by name.
 	slices.Sort(ordered)
-	// If self is in the list, move it to last so we don't cancel in-flight restarts.
+	ordered = slices.Compact(ordered)
+	// If self is in the list, take it out; it is restarted last and alone so we don't cancel in-flight restarts.
 	if selfName != "" {
-		for i, name := range ordered {
-			if name == selfName {
-				copy(ordered[i:], ordered[i+1:])
-				ordered[len(ordered)-1] = name
-				break
-			}
+		if i := slices.Index(ordered, selfName); i >= 0 {
+			ordered = slices.Delete(ordered, i, i+1)
+			hasSelf = true
 		}
 	}
-	for _, name := range ordered {
-		if f.DependentRestartCooldown > 0 && !f.shouldRestartDependent(name) {
-			docker.LogDebug("skip dependent restart, within cooldown", "dependent", name, "parent", parentName)
-			continue
-		}
-		if err := f.Client.Restart(ctx, name); err != nil {
-			docker.LogErrorRecovery(fmt.Sprintf("recovery: failed to restart dependent %q (parent %s)", name, parentName), "dependent", name, "parent", parentName, "error", err)
-			if f.DependentRestartCooldown > 0 {
-				f.clearDependentCooldown(name)
-			}
-		} else {
-			docker.LogInfoRecovery(fmt.Sprintf("recovery: restarted dependent %q (parent %s)", name, parentName), "dependent", name, "parent", parentName)
+	return graph.Stages(ordered), hasSelf
+}
+
+// forEachDependent calls fn for every name, up to concurrency at a time (<= 1 = sequential), and
+// returns the results in the order of names.
+func forEachDependent(names []string, concurrency int, fn func(name string) DependentResult) []DependentResult {
+	results := make([]DependentResult, len(names))
+	if concurrency <= 1 {
+		for i, name := range names {
+			results[i] = fn(name)
 		}
+		return results
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = fn(name)
+		}()
 	}
+	wg.Wait()
+	return results
 }
 
 // RunFullSequence restarts the parent, waits until healthy, then restarts dependents.
 // If wait-for-healthy times out, dependents are not restarted.
-// reason describes why recovery was triggered (e.g. "stop", "unhealthy"); used for logging.
+// Dependents that joined the parent's network namespace under an old parent ID are recreated
+// instead of restarted (see staleNetworkDependents).
+// With the stop-first strategy (see recoveryStrategy), dependents are stopped before the parent is
+// restarted and started (instead of restarted) once it is healthy; they stay stopped if it is not.
+// reason describes why recovery was triggered (e.g. "stop", "unhealthy") and trigger what
+// observed it ("event", "startup", "polling"); both are used for logging and the journal.
+// Before restarting, the parent is inspected so its exit code, OOM flag, error and last
+// healthcheck output are attached to the recovery logs (the restart would otherwise discard them),
+// and, when Capture is set, its recent logs are saved and the file is referenced as log_file.
+// The wait-healthy timeout is derived from the parent's healthcheck (see waitHealthyTimeout) and logged.
+// When Journal is set, the attempt, each step and the outcome are recorded.
 // selfName is optional; when set and present in the dependent list, that container is restarted last.
-func (f *Flow) RunFullSequence(ctx context.Context, parentID, parentName, reason string, discovery *discovery.ParentToDependents, selfName string) {
+func (f *Flow) RunFullSequence(ctx context.Context, parentID, parentName, reason, trigger string, graph *discovery.Graph, selfName string) {
 	if reason == "" {
 		reason = "unknown"
 	}
-	docker.LogInfoRecovery(fmt.Sprintf("recovery: starting recovery sequence for parent %q (reason: %s)", parentName, reason), "parent", parentName, "reason", reason)
+	if !f.begin() {
+		f.log().InfoRecovery(fmt.Sprintf("recovery: shutting down, not recovering parent %q", parentName), "parent", parentName, "reason", reason)
+		return
+	}
+	defer f.running.Done()
+	started := f.clock().Now()
+	attempt := journal.Entry{Kind: journal.KindAttempt, AttemptID: newAttemptID(), Parent: parentName, Trigger: trigger, Reason: reason}
+	attrs := []any{"parent", parentName, "reason", reason}
+	if st, err := f.Client.Inspect(ctx, parentID); err != nil {
+		f.log().Debug("recovery: inspect before restart failed", "parent", parentName, "error", err)
+	} else {
+		if details := st.Summary(); details != "" {
+			attrs = append(attrs, "details", details)
+			attempt.Details = details
+		}
+		attrs = append(attrs, st.LogAttrs())
+		attempt.Project = st.Labels[labelComposeProject]
+		attempt.State = &st
+	}
+	waitTimeout, timeoutSource := f.waitHealthyTimeout(attempt.State)
+	attrs = append(attrs, "wait_timeout", waitTimeout.String(), "wait_timeout_source", timeoutSource)
+	strategy := f.recoveryStrategy(attempt.State)
+	if strategy != StrategyRestart {
+		attrs = append(attrs, "strategy", strategy)
+	}
+	run := dependentRun{attemptID: attempt.AttemptID, parent: parentName, parentID: parentID, concurrency: f.dependentConcurrency(attempt.State)}
+	var captureStep *journal.Entry
+	if capture := f.policy().Capture; capture != nil {
+		captureStep = &journal.Entry{Kind: journal.KindStep, AttemptID: attempt.AttemptID, Parent: parentName, Step: journal.StepCaptureLogs, Container: parentName, Outcome: journal.OutcomeSuccess}
+		// Captures of all endpoints share one directory; the host keeps same-named parents apart.
+		captureName := parentName
+		if f.Host != "" {
+			captureName = f.Host + "-" + parentName
+		}
+		if path, err := capture.Capture(ctx, f.Client, parentID, captureName, started); err != nil {
+			f.log().WarnRecovery(fmt.Sprintf("recovery: failed to capture logs for parent %q", parentName), "parent", parentName, "error", err)
+			captureStep.Outcome, captureStep.Error = journal.OutcomeFailed, err.Error()
+		} else {
+			attrs = append(attrs, "log_file", path)
+			attempt.LogFile = path
+		}
+		captureStep.DurationMS = f.clock().Since(started).Milliseconds()
+	}
+	f.log().InfoRecovery(fmt.Sprintf("recovery: starting recovery sequence for parent %q (reason: %s)", parentName, reason), attrs...)
+	attempt.Time = started
+	attempt.Plan = planSteps(parentName, strategy, graph, selfName)
+	f.record(attempt)
+	if captureStep != nil {
+		f.record(*captureStep)
+	}
+	outcome := journal.Entry{Kind: journal.KindOutcome, AttemptID: attempt.AttemptID, Parent: parentName, Project: attempt.Project, Trigger: trigger, Reason: reason}
+	finish := func(result, errMsg string) {
+		outcome.Outcome, outcome.Error = result, errMsg
+		outcome.DurationMS = f.clock().Since(started).Milliseconds()
+		f.record(outcome)
+	}
+	// abort ends a sequence cut short by shutdown before the named step; pending dependents are
+	// restarted on the next start (see ResumeDependents).
+	abort := func(next string, pending []string) {
+		f.log().WarnRecovery(fmt.Sprintf("recovery: shutting down, aborted recovery of parent %q before %s", parentName, next), "parent", parentName, "pending", pending)
+		outcome.Pending = pending
+		finish(journal.OutcomeAborted, "shutdown before "+next)
+	}
+
+	if f.aborted(ctx) {
+		abort("restart parent", nil)
+		return
+	}
+	if strategy == StrategyStopFirst {
+		// Take dependents down before touching the parent so they do not run (or leak traffic) without it.
+		f.stopDependents(ctx, run, graph, selfName)
+		run.start = true
+		if f.aborted(ctx) {
+			abort("restart parent", plannedDependents(parentName, graph, selfName))
+			return
+		}
+	}
+
+	step := journal.Entry{Kind: journal.KindStep, AttemptID: attempt.AttemptID, Parent: parentName, Step: journal.StepRestartParent, Container: parentName}
 	if err := f.RestartParent(ctx, parentID); err != nil {
-		docker.LogErrorRecovery(fmt.Sprintf("recovery: failed to restart parent %q", parentName), "parent", parentName, "error", err)
+		f.log().ErrorRecovery(fmt.Sprintf("recovery: failed to restart parent %q", parentName), "parent", parentName, "error", err)
+		step.Outcome, step.Error = journal.OutcomeFailed, err.Error()
+		f.record(step)
+		finish(journal.OutcomeFailed, "restart parent: "+err.Error())
+		return
+	}
+	step.Outcome = journal.OutcomeSuccess
+	f.record(step)
+	if f.aborted(ctx) {
+		abort("wait healthy", plannedDependents(parentName, graph, selfName))
+		return
+	}
+	f.log().InfoRecovery(fmt.Sprintf("recovery: restarted parent %q, waiting for healthy", parentName), "parent", parentName)
+	waitStart := f.clock().Now()
+	waitResult := f.WaitUntilHealthy(ctx, parentID, waitTimeout)
+	step = journal.Entry{Kind: journal.KindStep, AttemptID: attempt.AttemptID, Parent: parentName, Step: journal.StepWaitHealthy, Container: parentName, DurationMS: f.clock().Since(waitStart).Milliseconds()}
+	if waitResult == WaitCanceled && f.Draining() {
+		step.Outcome, step.Error = journal.OutcomeAborted, "wait healthy: "+waitResult.String()
+		f.record(step)
+		abort("restart dependents", plannedDependents(parentName, graph, selfName))
 		return
 	}
-	docker.LogInfoRecovery(fmt.Sprintf("recovery: restarted parent %q, waiting for healthy", parentName), "parent", parentName)
-	if !f.WaitUntilHealthy(ctx, parentID, defaultWaitHealthyTimeout) {
-		docker.LogWarnRecovery(fmt.Sprintf("recovery: parent %q did not become healthy in time; not restarting dependents", parentName), "parent", parentName)
+	if waitResult != WaitHealthy {
+		msg := fmt.Sprintf("recovery: parent %q %s; not restarting dependents", parentName, waitResult.describe())
+		if run.start {
+			msg = fmt.Sprintf("recovery: parent %q %s; leaving dependents stopped until it is healthy", parentName, waitResult.describe())
+		}
+		if waitResult == WaitCanceled {
+			f.log().InfoRecovery(msg, "parent", parentName, "wait_result", waitResult.String())
+		} else {
+			f.log().WarnRecovery(msg, "parent", parentName, "wait_result", waitResult.String())
+		}
+		step.Outcome, step.Error = journal.OutcomeFailed, "wait healthy: "+waitResult.String()
+		f.record(step)
+		finish(journal.OutcomeFailed, "wait healthy: "+waitResult.String())
 		return
 	}
-	f.RestartDependents(ctx, parentName, discovery, selfName)
+	step.Outcome = journal.OutcomeSuccess
+	f.record(step)
+	results := f.restartDependents(ctx, run, graph, selfName)
+	result, summary := sequenceOutcome(results)
+	if result == journal.OutcomeAborted {
+		abort("restart dependents", pendingDependents(results))
+		return
+	}
+	if result == journal.OutcomeSuccess {
+		f.log().InfoRecovery(fmt.Sprintf("recovery: finished recovery for parent %q (outcome: %s)", parentName, result), "parent", parentName, "outcome", result, "dependents", summary)
+		finish(result, "")
+		return
+	}
+	f.log().WarnRecovery(fmt.Sprintf("recovery: finished recovery for parent %q (outcome: %s, %s)", parentName, result, summary), "parent", parentName, "outcome", result, "dependents", summary)
+	finish(result, summary)
+}
+
+// log returns the logger for the Flow's endpoint (see Host).
+func (f *Flow) log() *docker.Logger {
+	return docker.HostLogger(f.Host)
+}
+
+// record appends e to the journal when one is configured, labeled with Host; write errors are
+// logged, not returned.
+func (f *Flow) record(e journal.Entry) {
+	if f.Journal == nil {
+		return
+	}
+	e.Host = f.Host
+	if e.Time.IsZero() {
+		e.Time = f.clock().Now()
+	}
+	if err := f.Journal.Append(e); err != nil {
+		f.log().Warn("recovery: write journal entry", "kind", e.Kind, "parent", e.Parent, "error", err)
+	}
+}
+
+// RestoreDependentRestarts seeds the dependent cooldown map (e.g. from the journal at startup)
+// so dependents restarted shortly before a watch-dog restart are not bounced again.
+func (f *Flow) RestoreDependentRestarts(last map[string]time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lastDependentRestart == nil {
+		f.lastDependentRestart = make(map[string]time.Time)
+	}
+	for name, t := range last {
+		if t.After(f.lastDependentRestart[name]) {
+			f.lastDependentRestart[name] = t
+		}
+	}
+}
+
+// newAttemptID returns a random identifier grouping the journal entries of one recovery sequence.
+func newAttemptID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
 }
@@ -3,22 +3,58 @@ package recovery
 import (
 	"context"
 	"errors"
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
+	"watch-dog/internal/clock/clocktest"
 	"watch-dog/internal/discovery"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/journal"
 )
 
-// fakeClient records Restart and Inspect calls for tests.
+// epoch is the start time of the fake clock in tests.
+var epoch = time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+
+// fakeClient records Restart and Logs calls for tests. Runtime methods Flow does not use are
+// left to the nil embedded interface.
 type fakeClient struct {
-	mu              sync.Mutex
-	restarts        []string
-	inspect         map[string]string // containerID -> health to return
-	nextRestartErr  error              // if set, Restart returns it once and clears it
+	engine.Runtime
+
+	mu             sync.Mutex
+	restarts       []string
+	inspect        map[string]string               // containerID -> health to return
+	nextRestartErr error                           // if set, Restart returns it once and clears it
+	logs           map[string]string               // containerID -> log output to return
+	calls          []string                        // "restart:<id>", "stop:<id>", "start:<id>" and "logs:<id>" in call order
+	events         map[string][]engine.HealthEvent // containerID -> events delivered by SubscribeContainer
+	inspectErrs    int                             // number of Inspect calls that fail before succeeding
+	restarting     chan<- string                   // if set, Restart sends the container ID when it starts
+	release        <-chan struct{}                 // if set, Restart waits for a value (or close) before it finishes
+	inFlight       int                             // Restart calls currently running
+	maxInFlight    int                             // highest inFlight seen
+	networkModes   map[string]string               // containerID -> network mode returned by Inspect
+	inspected      chan<- string                   // if set, Inspect sends the container ID on every call
+	exited         map[string]int                  // containerID -> exit code; Inspect reports the container exited
 }
 
 func (c *fakeClient) Restart(ctx context.Context, containerID string) error {
+	c.mu.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+	if c.restarting != nil {
+		c.restarting <- containerID
+	}
+	if c.release != nil {
+		<-c.release
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.nextRestartErr != nil {
@@ -27,17 +63,71 @@ func (c *fakeClient) Restart(ctx context.Context, containerID string) error {
 		return err
 	}
 	c.restarts = append(c.restarts, containerID)
+	c.calls = append(c.calls, "restart:"+containerID)
 	return nil
 }
 
-func (c *fakeClient) Inspect(ctx context.Context, containerID string) (health string, labels map[string]string, err error) {
+func (c *fakeClient) Stop(ctx context.Context, containerID string) error {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "stop:"+containerID)
+	return nil
+}
+
+func (c *fakeClient) Start(ctx context.Context, containerID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "start:"+containerID)
+	return nil
+}
+
+func (c *fakeClient) Recreate(ctx context.Context, containerID, networkMode string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "recreate:"+containerID+":"+networkMode)
+	return containerID + "-new", nil
+}
+
+func (c *fakeClient) Inspect(ctx context.Context, containerID string) (engine.ContainerState, error) {
+	if c.inspected != nil {
+		c.inspected <- containerID
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inspectErrs > 0 {
+		c.inspectErrs--
+		return engine.ContainerState{}, errors.New("fake inspect failure")
+	}
 	h := c.inspect[containerID]
 	if h == "" {
 		h = "healthy"
 	}
+	if code, ok := c.exited[containerID]; ok {
+		return engine.ContainerState{Status: "exited", ExitCode: code}, nil
+	}
+	return engine.ContainerState{Health: h, Status: "running", NetworkMode: c.networkModes[containerID]}, nil
+}
+
+// SubscribeContainer delivers the queued events for containerID before it returns (out must have
+// room for them), then keeps the channel open until ctx is done.
+func (c *fakeClient) SubscribeContainer(ctx context.Context, containerID string, out chan<- engine.HealthEvent) {
+	c.mu.Lock()
+	evs := slices.Clone(c.events[containerID])
 	c.mu.Unlock()
-	return h, nil, nil
+	for _, ev := range evs {
+		out <- ev
+	}
+	go func() {
+		defer close(out)
+		<-ctx.Done()
+	}()
+}
+
+func (c *fakeClient) Logs(ctx context.Context, containerID string, tail int, since time.Time) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, "logs:"+containerID)
+	return []byte(c.logs[containerID]), nil
 }
 
 func (c *fakeClient) getRestarts() []string {
@@ -76,9 +166,11 @@ func TestRestartDependents_dependentCooldownSkipsSecondRestart(t *testing.T) {
 func TestRestartDependents_dependentCooldownAllowsRestartAfterWindow(t *testing.T) {
 	ctx := context.Background()
 	fake := &fakeClient{inspect: make(map[string]string)}
+	clk := clocktest.New(epoch)
 	flow := &Flow{
 		Client:                   fake,
-		DependentRestartCooldown: 20 * time.Millisecond,
+		DependentRestartCooldown: 20 * time.Second,
+		Clock:                    clk,
 	}
 	parentToDeps := discovery.ParentToDependents{
 		"parent1": {"dep-a"},
@@ -89,8 +181,13 @@ func TestRestartDependents_dependentCooldownAllowsRestartAfterWindow(t *testing.
 		t.Fatalf("first call: got %d restarts, want 1", n)
 	}
 
-	time.Sleep(50 * time.Millisecond)
+	clk.Advance(19 * time.Second)
+	flow.RestartDependents(ctx, "parent1", &parentToDeps, "")
+	if n := len(fake.getRestarts()); n != 1 {
+		t.Fatalf("within cooldown: got %d restarts, want 1", n)
+	}
 
+	clk.Advance(time.Second)
 	flow.RestartDependents(ctx, "parent1", &parentToDeps, "")
 	got := fake.getRestarts()
 	if len(got) != 2 {
@@ -140,3 +237,49 @@ func TestRestartDependents_cooldownDisabledRestartsEveryTime(t *testing.T) {
 		t.Errorf("cooldown disabled: got %d restarts %v, want 2 (dep-a twice)", len(got), got)
 	}
 }
+
+// memJournal collects journal entries in memory.
+type memJournal struct {
+	mu      sync.Mutex
+	entries []journal.Entry
+}
+
+func (j *memJournal) Append(e journal.Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, e)
+	return nil
+}
+
+func TestRunFullSequence_journalsAttemptStepsAndOutcome(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	j := &memJournal{}
+	flow := &Flow{Client: fake, Journal: j, Clock: clocktest.New(epoch)}
+	parentToDeps := discovery.ParentToDependents{"vpn": {"torrent"}}
+
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", discovery.NewGraph(parentToDeps), "")
+
+	var got []string
+	for _, e := range j.entries {
+		if e.AttemptID != j.entries[0].AttemptID {
+			t.Errorf("entry %+v has attempt ID %q, want %q", e, e.AttemptID, j.entries[0].AttemptID)
+		}
+		got = append(got, string(e.Kind)+":"+e.Step+":"+e.Outcome)
+		if !e.Time.Equal(epoch) {
+			t.Errorf("entry %+v has time %v, want the flow clock's %v", e, e.Time, epoch)
+		}
+	}
+	want := []string{
+		"attempt::",
+		"step:restart_parent:success",
+		"step:wait_healthy:success",
+		"step:restart_dependent:success",
+		"outcome::success",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("journal = %v, want %v", got, want)
+	}
+	if j.entries[0].Trigger != "event" || j.entries[0].Reason != "die" || j.entries[0].State == nil {
+		t.Errorf("attempt entry = %+v, want trigger, reason and state set", j.entries[0])
+	}
+}
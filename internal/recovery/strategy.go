@@ -0,0 +1,134 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/journal"
+)
+
+// Recovery strategies (Flow.Strategy, overridable per parent with LabelRecoveryStrategy).
+const (
+	// StrategyRestart restarts the parent, waits until healthy, then restarts the dependents,
+	// which keep running against the failed parent meanwhile.
+	StrategyRestart = "restart"
+	// StrategyStopFirst stops the dependents as soon as the failure is detected, restarts and awaits
+	// the parent, then starts the dependents in dependency order, like docker compose up. Use it for
+	// dependents that must not run without the parent (e.g. network_mode: service:vpn).
+	StrategyStopFirst = "stop-first"
+)
+
+// LabelRecoveryStrategy is the parent container label that overrides Flow.Strategy for that parent.
+const LabelRecoveryStrategy = "watch-dog.recovery-strategy"
+
+// ValidStrategy reports whether s names a recovery strategy.
+func ValidStrategy(s string) bool {
+	return s == StrategyRestart || s == StrategyStopFirst
+}
+
+// recoveryStrategy returns the strategy for a parent: the LabelRecoveryStrategy label when valid,
+// otherwise Strategy, defaulting to StrategyRestart.
+func (f *Flow) recoveryStrategy(parent *engine.ContainerState) string {
+	if parent != nil {
+		if v := parent.Labels[LabelRecoveryStrategy]; v != "" {
+			if ValidStrategy(v) {
+				return v
+			}
+			f.log().Warn("invalid "+LabelRecoveryStrategy+" label, ignoring", "value", v)
+		}
+	}
+	if s := f.policy().Strategy; ValidStrategy(s) {
+		return s
+	}
+	return StrategyRestart
+}
+
+// stopDependents stops the parent's dependents (except selfName) in reverse dependency order:
+// dependents of other dependents go first, as docker compose down does. Failures are logged and
+// journaled as stop_dependent steps; the sequence continues regardless.
+func (f *Flow) stopDependents(ctx context.Context, run dependentRun, graph *discovery.Graph, selfName string) {
+	stages, _ := dependentStages(run.parent, graph, selfName)
+	for _, stage := range slices.Backward(stages) {
+		forEachDependent(stage, run.concurrency, func(name string) DependentResult {
+			start := f.clock().Now()
+			step := journal.Entry{Kind: journal.KindStep, AttemptID: run.attemptID, Parent: run.parent, Step: journal.StepStopDependent, Container: name, Outcome: journal.OutcomeSuccess}
+			res := DependentResult{Name: name, Outcome: journal.OutcomeSuccess, Attempts: 1}
+			if err := f.Client.Stop(ctx, name); err != nil {
+				f.log().ErrorRecovery(fmt.Sprintf("recovery: failed to stop dependent %q (parent %s)", name, run.parent), "dependent", name, "parent", run.parent, "error", err)
+				step.Outcome, step.Error = journal.OutcomeFailed, err.Error()
+				res.Outcome, res.Err = journal.OutcomeFailed, err
+			} else {
+				f.holdStopped(name, run.parent)
+				f.log().InfoRecovery(fmt.Sprintf("recovery: stopped dependent %q while parent %s recovers", name, run.parent), "dependent", name, "parent", run.parent)
+			}
+			step.DurationMS = f.clock().Since(start).Milliseconds()
+			f.record(step)
+			return res
+		})
+	}
+}
+
+// HeldStopped reports whether the container was stopped by the stop-first strategy and has not
+// been started again. Its stop is intentional, so callers must not recover it as a failed parent.
+func (f *Flow) HeldStopped(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, held := f.heldStopped[name]
+	return held
+}
+
+// HeldDependents returns the dependents of parentName that its stop-first recovery left stopped,
+// in name order (see StartHeld).
+func (f *Flow) HeldDependents(parentName string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name, parent := range f.heldStopped {
+		if parent == parentName {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// RestoreHeldStopped seeds the held-stopped dependents (e.g. from the journal at startup, see
+// journal.State.Held), mapped to the parent whose recovery stopped them, so they are neither
+// recovered as failed parents nor forgotten after watch-dog restarts.
+func (f *Flow) RestoreHeldStopped(held map[string]string) {
+	for name, parent := range held {
+		f.holdStopped(name, parent)
+	}
+}
+
+// StartHeld starts the dependents of parentName that the stop-first strategy left stopped because
+// the parent did not become healthy during its recovery (e.g. the wait timed out, or watch-dog
+// restarted meanwhile), once the parent is healthy again on its own. Like ResumeDependents, it
+// only runs when the parent is healthy (or running, without a healthcheck), starts the dependents
+// in dependency order and is journaled as an attempt, with trigger "held". It returns false when
+// no dependent is held or the parent is not healthy.
+func (f *Flow) StartHeld(ctx context.Context, parentName string, graph *discovery.Graph, selfName string) bool {
+	held := f.HeldDependents(parentName)
+	if len(held) == 0 {
+		return false
+	}
+	return f.runDependentsOf(ctx, parentName, held, graph, selfName, true)
+}
+
+func (f *Flow) holdStopped(name, parent string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.heldStopped == nil {
+		f.heldStopped = make(map[string]string)
+	}
+	f.heldStopped[name] = parent
+}
+
+func (f *Flow) releaseHeld(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.heldStopped, name)
+}
@@ -0,0 +1,90 @@
+package recovery
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/engine"
+)
+
+func TestRunFullSequence_stopFirstStopsDependentsThenStartsThemInOrder(t *testing.T) {
+	fake := &fakeClient{inspect: make(map[string]string)}
+	flow := &Flow{Client: fake, Strategy: StrategyStopFirst}
+	graph := discovery.NewGraph(discovery.ParentToDependents{
+		"vpn": {"api", "db", "watch-dog"},
+		"db":  {"api"},
+	})
+
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", graph, "watch-dog")
+
+	want := []string{"stop:api", "stop:db", "restart:vpn-id", "start:db", "start:api", "restart:watch-dog"}
+	if !slices.Equal(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+	if flow.HeldStopped("db") || flow.HeldStopped("api") {
+		t.Error("started dependents still reported as held stopped")
+	}
+}
+
+func TestRunFullSequence_stopFirstLeavesDependentsStoppedWhenParentFails(t *testing.T) {
+	fake := &fakeClient{
+		inspect: map[string]string{"vpn-id": "starting"},
+		events:  map[string][]engine.HealthEvent{"vpn-id": {{ContainerID: "vpn-id", Status: "die"}}},
+	}
+	flow := &Flow{Client: fake, Strategy: StrategyStopFirst}
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent"}})
+
+	flow.RunFullSequence(context.Background(), "vpn-id", "vpn", "die", "event", graph, "")
+
+	want := []string{"stop:torrent", "restart:vpn-id"}
+	if !slices.Equal(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+	if !flow.HeldStopped("torrent") {
+		t.Error("torrent not reported as held stopped")
+	}
+}
+
+func TestStartHeld_startsDependentsOnceParentIsHealthy(t *testing.T) {
+	fake := &fakeClient{inspect: map[string]string{"vpn": "starting"}}
+	flow := &Flow{Client: fake, Strategy: StrategyStopFirst}
+	graph := discovery.NewGraph(discovery.ParentToDependents{"vpn": {"torrent", "web"}, "torrent": {"web"}})
+	// As restored from the journal after a restart of watch-dog.
+	flow.RestoreHeldStopped(map[string]string{"torrent": "vpn", "web": "vpn"})
+	if !flow.HeldStopped("torrent") || !slices.Equal(flow.HeldDependents("vpn"), []string{"torrent", "web"}) {
+		t.Fatalf("HeldDependents(vpn) = %v after restore", flow.HeldDependents("vpn"))
+	}
+
+	if flow.StartHeld(context.Background(), "vpn", graph, "") {
+		t.Error("StartHeld ran while the parent is still starting")
+	}
+	fake.mu.Lock()
+	fake.inspect["vpn"] = "healthy"
+	fake.mu.Unlock()
+	if !flow.StartHeld(context.Background(), "vpn", graph, "") {
+		t.Fatal("StartHeld did not run for a healthy parent")
+	}
+	if want := []string{"start:torrent", "start:web"}; !slices.Equal(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+	if flow.HeldStopped("torrent") || flow.HeldStopped("web") {
+		t.Error("started dependents still reported as held stopped")
+	}
+	if flow.StartHeld(context.Background(), "vpn", graph, "") {
+		t.Error("StartHeld ran again without held dependents")
+	}
+}
+
+func TestRecoveryStrategy_parentLabelOverridesFlow(t *testing.T) {
+	flow := &Flow{}
+	st := &engine.ContainerState{Labels: map[string]string{LabelRecoveryStrategy: StrategyStopFirst}}
+	if got := flow.recoveryStrategy(st); got != StrategyStopFirst {
+		t.Errorf("label stop-first: got %q", got)
+	}
+	st.Labels[LabelRecoveryStrategy] = "bogus"
+	if got := flow.recoveryStrategy(st); got != StrategyRestart {
+		t.Errorf("invalid label: got %q, want %q", got, StrategyRestart)
+	}
+}
@@ -0,0 +1,44 @@
+package recovery
+
+import (
+	"time"
+
+	"watch-dog/internal/engine"
+)
+
+// LabelWaitHealthyTimeout is the container label that overrides the computed wait-healthy
+// timeout for a parent (a Go duration, e.g. "10m"). Set it per service under compose `labels:`.
+const LabelWaitHealthyTimeout = "watch-dog.wait-healthy-timeout"
+
+// waitHealthyMargin is added to the timeout derived from the healthcheck to absorb restart time.
+const waitHealthyMargin = 30 * time.Second
+
+// Sources of the wait-healthy timeout, logged at recovery start.
+const (
+	timeoutSourceLabel       = "label"
+	timeoutSourceHealthcheck = "healthcheck"
+	timeoutSourceDefault     = "default"
+)
+
+// waitHealthyTimeout returns how long to wait for a restarted parent to become healthy, and where
+// the value came from. The LabelWaitHealthyTimeout label wins; otherwise the timeout is derived
+// from the healthcheck as start_period + (interval + timeout) * retries + margin, i.e. the longest
+// a healthy container can take to be reported healthy plus slack. Without a healthcheck (or when
+// the parent could not be inspected) the Flow's default applies.
+func (f *Flow) waitHealthyTimeout(st *engine.ContainerState) (time.Duration, string) {
+	if st != nil {
+		if v := st.Labels[LabelWaitHealthyTimeout]; v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				return d, timeoutSourceLabel
+			}
+			f.log().Warn("invalid "+LabelWaitHealthyTimeout+" label, ignoring", "value", v)
+		}
+		if hc := st.Healthcheck; hc != nil {
+			return hc.StartPeriod + (hc.Interval+hc.Timeout)*time.Duration(hc.Retries) + waitHealthyMargin, timeoutSourceHealthcheck
+		}
+	}
+	if d := f.policy().WaitHealthyTimeout; d > 0 {
+		return d, timeoutSourceDefault
+	}
+	return defaultWaitHealthyTimeout, timeoutSourceDefault
+}
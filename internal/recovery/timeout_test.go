@@ -0,0 +1,34 @@
+package recovery
+
+import (
+	"testing"
+	"time"
+
+	"watch-dog/internal/engine"
+)
+
+func TestWaitHealthyTimeout(t *testing.T) {
+	hc := &engine.HealthcheckConfig{Interval: 5 * time.Second, Timeout: 3 * time.Second, StartPeriod: 10 * time.Minute, Retries: 3}
+	tests := []struct {
+		name        string
+		flowDefault time.Duration
+		st          *engine.ContainerState
+		want        time.Duration
+		wantSource  string
+	}{
+		{"not inspected", 0, nil, defaultWaitHealthyTimeout, timeoutSourceDefault},
+		{"no healthcheck uses flow default", 2 * time.Minute, &engine.ContainerState{}, 2 * time.Minute, timeoutSourceDefault},
+		{"derived from healthcheck", 0, &engine.ContainerState{Healthcheck: hc}, 10*time.Minute + 24*time.Second + waitHealthyMargin, timeoutSourceHealthcheck},
+		{"label overrides healthcheck", 0, &engine.ContainerState{Healthcheck: hc, Labels: map[string]string{LabelWaitHealthyTimeout: "15m"}}, 15 * time.Minute, timeoutSourceLabel},
+		{"invalid label ignored", 0, &engine.ContainerState{Healthcheck: hc, Labels: map[string]string{LabelWaitHealthyTimeout: "soon"}}, 10*time.Minute + 24*time.Second + waitHealthyMargin, timeoutSourceHealthcheck},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := &Flow{WaitHealthyTimeout: tt.flowDefault}
+			got, source := flow.waitHealthyTimeout(tt.st)
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("waitHealthyTimeout = %v (%s), want %v (%s)", got, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"watch-dog/internal/engine"
+)
+
+// waitHealthySafetyPoll is how often WaitUntilHealthy inspects the container while waiting for
+// events, in case an event is missed or the event stream fails.
+const waitHealthySafetyPoll = 15 * time.Second
+
+// maxConsecutiveInspectErrors is how many inspect failures in a row WaitUntilHealthy tolerates
+// before giving up with WaitInspectError.
+const maxConsecutiveInspectErrors = 3
+
+// WaitResult is the outcome of WaitUntilHealthy.
+type WaitResult int
+
+const (
+	// WaitHealthy means the container reported healthy.
+	WaitHealthy WaitResult = iota
+	// WaitUnhealthy means the container reported unhealthy again after the restart.
+	WaitUnhealthy
+	// WaitDied means the container exited while waiting.
+	WaitDied
+	// WaitTimeout means the timeout elapsed before the container became healthy.
+	WaitTimeout
+	// WaitCanceled means the context was canceled (e.g. shutdown).
+	WaitCanceled
+	// WaitInspectError means the container could not be inspected repeatedly.
+	WaitInspectError
+)
+
+// String returns the result name used in logs and the journal (e.g. "healthy", "timeout").
+func (r WaitResult) String() string {
+	switch r {
+	case WaitHealthy:
+		return "healthy"
+	case WaitUnhealthy:
+		return "unhealthy"
+	case WaitDied:
+		return "died"
+	case WaitTimeout:
+		return "timeout"
+	case WaitCanceled:
+		return "ctx-canceled"
+	case WaitInspectError:
+		return "inspect-error"
+	default:
+		return fmt.Sprintf("WaitResult(%d)", int(r))
+	}
+}
+
+// describe returns a phrase for log messages, e.g. "did not become healthy in time".
+func (r WaitResult) describe() string {
+	switch r {
+	case WaitHealthy:
+		return "became healthy"
+	case WaitUnhealthy:
+		return "became unhealthy again after restart"
+	case WaitDied:
+		return "died while waiting to become healthy"
+	case WaitTimeout:
+		return "did not become healthy in time"
+	case WaitCanceled:
+		return "wait for healthy was canceled"
+	default:
+		return "could not be inspected while waiting to become healthy"
+	}
+}
+
+// WaitUntilHealthy waits until the container reports healthy, driven by its health_status and
+// die events, with a low-frequency inspect as a safety net that decides an inspected state the
+// same way as the matching event. It returns as soon as the outcome is known: healthy, unhealthy
+// again, died, timeout, context canceled, or repeated inspect errors (single transient inspect
+// errors are tolerated). Only WaitHealthy allows restarting dependents.
+func (f *Flow) WaitUntilHealthy(ctx context.Context, containerID string, timeout time.Duration) WaitResult {
+	r, _ := f.waitFor(ctx, containerID, timeout, waitCondition{
+		what: "healthy",
+		state: func(st engine.ContainerState) (WaitResult, bool) {
+			switch {
+			case st.Health == "healthy":
+				return WaitHealthy, true
+			case st.Health == "unhealthy":
+				return WaitUnhealthy, true
+			case st.Status == "exited" || st.Status == "dead":
+				return WaitDied, true
+			}
+			return 0, false
+		},
+		event: func(status string) (WaitResult, bool) {
+			switch status {
+			case engine.EventHealthy:
+				return WaitHealthy, true
+			case engine.EventUnhealthy:
+				return WaitUnhealthy, true
+			case engine.EventDie:
+				return WaitDied, true
+			}
+			return 0, false
+		},
+	})
+	return r
+}
+
+// waitCompleted waits until the container exits (service_completed_successfully), driven by its
+// die event with the same safety-net inspect as WaitUntilHealthy. It returns nil when the container
+// exited with code 0, and an error when it exited with another code, did not exit in time, or
+// could not be inspected.
+func (f *Flow) waitCompleted(ctx context.Context, containerID string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultWaitHealthyTimeout
+	}
+	exitCode := 0
+	r, err := f.waitFor(ctx, containerID, timeout, waitCondition{
+		what: "completed",
+		// An exit is reported as WaitHealthy with code 0 and as WaitDied with any other code.
+		state: func(st engine.ContainerState) (WaitResult, bool) {
+			if st.Status != "exited" && st.Status != "dead" {
+				return 0, false
+			}
+			if exitCode = st.ExitCode; exitCode != 0 {
+				return WaitDied, true
+			}
+			return WaitHealthy, true
+		},
+	})
+	switch r {
+	case WaitHealthy:
+		return nil
+	case WaitDied:
+		return fmt.Errorf("exited with code %d", exitCode)
+	case WaitCanceled:
+		return ctx.Err()
+	case WaitTimeout:
+		return fmt.Errorf("did not complete within %s", timeout)
+	default:
+		return err
+	}
+}
+
+// waitCondition is what waitFor waits for.
+type waitCondition struct {
+	// what names the awaited state in log messages, e.g. "healthy".
+	what string
+	// state decides the outcome from an inspected state; false keeps waiting.
+	state func(st engine.ContainerState) (WaitResult, bool)
+	// event, when non-nil, decides the outcome from an event's status; false keeps waiting. A die
+	// event it does not decide is confirmed by an inspect.
+	event func(status string) (WaitResult, bool)
+}
+
+// waitFor waits until cond decides the outcome from the container's events or, as a safety net
+// for missed events or a failed event stream, from an inspect every waitHealthySafetyPoll. Without
+// an outcome it returns WaitTimeout after timeout (0 = 5m), WaitCanceled when ctx is done, and
+// WaitInspectError with the last error after maxConsecutiveInspectErrors failed inspects in a row.
+func (f *Flow) waitFor(ctx context.Context, containerID string, timeout time.Duration, cond waitCondition) (WaitResult, error) {
+	if timeout <= 0 {
+		timeout = defaultWaitHealthyTimeout
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Subscribe before the first inspect so a transition between the two is not missed.
+	events := make(chan engine.HealthEvent, 4)
+	f.Client.SubscribeContainer(ctx, containerID, events)
+	deadline := f.clock().NewTimer(timeout)
+	defer deadline.Stop()
+	poll := f.clock().NewTicker(waitHealthySafetyPoll)
+	defer poll.Stop()
+
+	inspectErrors := 0
+	check := func() (WaitResult, bool, error) {
+		st, err := f.Client.Inspect(ctx, containerID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return WaitCanceled, true, nil
+			}
+			inspectErrors++
+			f.log().WarnRecovery(fmt.Sprintf("recovery: inspect while waiting for %s failed (container %s)", cond.what, containerID), "container", containerID, "error", err, "consecutive", inspectErrors)
+			if inspectErrors >= maxConsecutiveInspectErrors {
+				return WaitInspectError, true, err
+			}
+			return 0, false, nil
+		}
+		inspectErrors = 0
+		r, done := cond.state(st)
+		return r, done, nil
+	}
+
+	if r, done, err := check(); done {
+		return r, err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return WaitCanceled, nil
+		case <-deadline.C():
+			// One last look so a state reached just at the deadline still counts.
+			if r, done, err := check(); done && r != WaitInspectError {
+				return r, err
+			}
+			return WaitTimeout, nil
+		case <-poll.C():
+			if r, done, err := check(); done {
+				return r, err
+			}
+		case ev, ok := <-events:
+			if !ok {
+				// Event stream ended; rely on the safety-net inspect for the rest of the wait.
+				events = nil
+				continue
+			}
+			if cond.event != nil {
+				if r, done := cond.event(ev.Status); done {
+					return r, nil
+				}
+			}
+			if ev.Status == engine.EventDie {
+				if r, done, err := check(); done {
+					return r, err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"watch-dog/internal/clock/clocktest"
+	"watch-dog/internal/engine"
+)
+
+func TestWaitUntilHealthy_results(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *fakeClient
+		ctx    func() context.Context
+		want   WaitResult
+	}{
+		{
+			name:   "already healthy",
+			client: &fakeClient{},
+			want:   WaitHealthy,
+		},
+		{
+			name:   "healthy event",
+			client: &fakeClient{inspect: map[string]string{"vpn": "starting"}, events: map[string][]engine.HealthEvent{"vpn": {{ContainerID: "vpn", Status: "health_status: healthy"}}}},
+			want:   WaitHealthy,
+		},
+		{
+			name:   "unhealthy event",
+			client: &fakeClient{inspect: map[string]string{"vpn": "starting"}, events: map[string][]engine.HealthEvent{"vpn": {{ContainerID: "vpn", Status: "health_status: unhealthy"}}}},
+			want:   WaitUnhealthy,
+		},
+		{
+			// A missed unhealthy event is caught by the safety-net inspect, without waiting for the
+			// timeout.
+			name:   "inspected unhealthy",
+			client: &fakeClient{inspect: map[string]string{"vpn": "unhealthy"}},
+			want:   WaitUnhealthy,
+		},
+		{
+			name:   "die event",
+			client: &fakeClient{inspect: map[string]string{"vpn": "starting"}, events: map[string][]engine.HealthEvent{"vpn": {{ContainerID: "vpn", Status: "die"}}}},
+			want:   WaitDied,
+		},
+		{
+			name:   "timeout",
+			client: &fakeClient{inspect: map[string]string{"vpn": "starting"}},
+			want:   WaitTimeout,
+		},
+		{
+			name:   "transient inspect errors tolerated",
+			client: &fakeClient{inspectErrs: maxConsecutiveInspectErrors - 1},
+			want:   WaitHealthy,
+		},
+		{
+			name:   "repeated inspect errors",
+			client: &fakeClient{inspectErrs: maxConsecutiveInspectErrors},
+			want:   WaitInspectError,
+		},
+		{
+			name:   "canceled",
+			client: &fakeClient{inspect: map[string]string{"vpn": "starting"}},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			want: WaitCanceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+			inspected := make(chan string, 16)
+			tt.client.inspected = inspected
+			clk := clocktest.New(epoch)
+			flow := &Flow{Client: tt.client, Clock: clk}
+			done := make(chan WaitResult, 1)
+			go func() { done <- flow.WaitUntilHealthy(ctx, "vpn", 4*waitHealthySafetyPoll) }()
+			// Queued events are delivered on subscribe, so the wait must end with them without any
+			// time passing. Otherwise every inspect moves time on to the next safety-net poll.
+			queued := len(tt.client.events) > 0
+			for {
+				select {
+				case got := <-done:
+					if got != tt.want {
+						t.Errorf("WaitUntilHealthy = %v, want %v", got, tt.want)
+					}
+					return
+				case <-inspected:
+					if !queued {
+						clk.Advance(waitHealthySafetyPoll)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWaitCompleted_results(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *fakeClient
+		wantErr string
+	}{
+		{"exited 0", &fakeClient{exited: map[string]int{"init": 0}}, ""},
+		{"exited 1", &fakeClient{exited: map[string]int{"init": 1}}, "exited with code 1"},
+		{"still running", &fakeClient{}, "did not complete within 1m0s"},
+		{"repeated inspect errors", &fakeClient{inspectErrs: maxConsecutiveInspectErrors}, "fake inspect failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspected := make(chan string, 16)
+			tt.client.inspected = inspected
+			clk := clocktest.New(epoch)
+			flow := &Flow{Client: tt.client, Clock: clk}
+			done := make(chan error, 1)
+			go func() { done <- flow.waitCompleted(context.Background(), "init", 4*waitHealthySafetyPoll) }()
+			for {
+				select {
+				case err := <-done:
+					if got := fmt.Sprint(err); (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && got != tt.wantErr) {
+						t.Errorf("waitCompleted = %v, want %q", err, tt.wantErr)
+					}
+					return
+				case <-inspected:
+					clk.Advance(waitHealthySafetyPoll)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package status
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// writeMetrics writes r in the Prometheus text format. With named endpoints the discovery gauges
+// have one sample per endpoint, labeled host="<name>". watchdog_leader is only written when
+// leader election is enabled.
+func writeMetrics(w io.Writer, r Report) {
+	endpoints := r.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []Report{r}
+	}
+	gauge(w, "watchdog_degraded", "1 while discovery is failing.", endpoints, func(e Report) int { return boolValue(e.State == StateDegraded) })
+	gauge(w, "watchdog_parents", "Parent containers found by the last successful discovery.", endpoints, func(e Report) int { return len(e.Parents) })
+	gauge(w, "watchdog_discovery_failures", "Consecutive failed discoveries.", endpoints, func(e Report) int { return e.DiscoveryFailures })
+	if r.Role != "" {
+		gauge(w, "watchdog_leader", "1 while this replica holds the leader lease and recovers containers.", []Report{{}}, func(Report) int { return boolValue(r.Role == RoleLeader) })
+	}
+}
+
+// gauge writes one sample of name per report, labeled with the report's endpoint name if any.
+func gauge(w io.Writer, name, help string, reports []Report, value func(Report) int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, r := range reports {
+		labels := ""
+		if r.Name != "" {
+			labels = "{host=" + strconv.Quote(r.Name) + "}"
+		}
+		fmt.Fprintf(w, "%s%s %d\n", name, labels, value(r))
+	}
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package status
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"watch-dog/internal/docker"
+)
+
+// ReloadResult is the response of POST /reload.
+type ReloadResult struct {
+	// Changed are the settings whose values changed.
+	Changed []string `json:"changed"`
+	// RestartRequired are the changed settings that only take effect after a restart.
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// ReloadFunc re-reads the configuration and applies it.
+type ReloadFunc func(ctx context.Context) (ReloadResult, error)
+
+// Handler serves /healthz (200 "ok", or 503 with the reason while degraded), /status (the
+// Report as JSON), /metrics (see writeMetrics) and, when reload is non-nil, POST /reload (the ReloadResult as JSON, or 422
+// with the error when the new configuration is invalid). A non-empty token must be sent with
+// /reload as "Authorization: Bearer <token>"; other requests are rejected with 401.
+func Handler(t *Tracker, reload ReloadFunc, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		rep := t.Report()
+		if !rep.Healthy() {
+			http.Error(w, rep.State+": "+rep.Problem(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(t.Report())
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, t.Report())
+	})
+	if reload != nil {
+		mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+			if !authorized(r, token) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "missing or wrong control token", http.StatusUnauthorized)
+				return
+			}
+			res, err := reload(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(res)
+		})
+	}
+	return mux
+}
+
+// authorized reports whether r carries token as its bearer token, or token is empty.
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// Loopback reports whether the listen address addr only accepts connections from this host
+// (e.g. "127.0.0.1:9090" or "localhost:9090", not ":9090").
+func Loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve listens on addr and serves h until ctx is done. Listen errors are returned; the server is
+// shut down gracefully when ctx ends.
+func Serve(ctx context.Context, addr string, h http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	docker.LogInfo("status server listening", "addr", ln.Addr().String())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Check requests /healthz on addr (e.g. ":9090") and returns an error unless it answers 200.
+// Used by the "healthcheck" subcommand for the container HEALTHCHECK.
+func Check(ctx context.Context, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+net.JoinHostPort(host, port)+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var buf [512]byte
+		n, _ := resp.Body.Read(buf[:])
+		return fmt.Errorf("%s: %s", resp.Status, string(buf[:n]))
+	}
+	return nil
+}
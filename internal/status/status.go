@@ -0,0 +1,220 @@
+// Package status tracks watch-dog's own state (running or degraded, last discovery, leader role)
+// and serves it over HTTP: /healthz for container healthchecks, /status as JSON and /metrics for
+// Prometheus.
+package status
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States reported by Tracker.
+const (
+	// StateStarting is reported until the first discovery finished.
+	StateStarting = "starting"
+	// StateRunning means discovery works and parents are supervised.
+	StateRunning = "running"
+	// StateDegraded means discovery is failing (e.g. the compose file is missing or invalid);
+	// watch-dog keeps retrying and supervises nothing new until it succeeds.
+	StateDegraded = "degraded"
+)
+
+// Roles reported by Tracker when leader election is enabled.
+const (
+	// RoleLeader means this replica holds the lease and recovers containers.
+	RoleLeader = "leader"
+	// RoleStandby means another replica (or none) holds the lease; this one only watches.
+	RoleStandby = "standby"
+)
+
+// Report is a snapshot of the tracked state, as served by /status.
+type Report struct {
+	// Name is the Docker endpoint name, in Endpoints only.
+	Name string `json:"name,omitempty"`
+	// State is starting, running or degraded.
+	State string `json:"state"`
+	// Since is when State last changed.
+	Since time.Time `json:"since"`
+	// ComposePath is the compose file discovery reads ("" when unset).
+	ComposePath string `json:"compose_path,omitempty"`
+	// Parents are the parent containers found by the last successful discovery, sorted.
+	Parents []string `json:"parents"`
+	// LastDiscovery is when discovery last succeeded (zero if never).
+	LastDiscovery time.Time `json:"last_discovery,omitzero"`
+	// DiscoveryError is the last discovery error while degraded.
+	DiscoveryError string `json:"discovery_error,omitempty"`
+	// DiscoveryFailures counts consecutive failed discoveries.
+	DiscoveryFailures int `json:"discovery_failures,omitempty"`
+	// LastReload is when the configuration was last reloaded (zero if never).
+	LastReload time.Time `json:"last_reload,omitzero"`
+	// ReloadError is the error of the last reload, if it failed (the previous configuration is kept).
+	ReloadError string `json:"reload_error,omitempty"`
+	// Role is RoleLeader or RoleStandby when leader election is enabled ("" otherwise).
+	Role string `json:"role,omitempty"`
+	// Leader is the ID of the replica holding the leader lease, if any.
+	Leader string `json:"leader,omitempty"`
+	// RoleSince is when Role last changed.
+	RoleSince time.Time `json:"role_since,omitzero"`
+	// Endpoints holds the discovery state of each named Docker endpoint; State is then derived
+	// from theirs (see Tracker.Endpoint).
+	Endpoints []Report `json:"endpoints,omitempty"`
+}
+
+// Healthy reports whether the state counts as healthy for /healthz (starting and running do).
+func (r Report) Healthy() bool {
+	return r.State != StateDegraded
+}
+
+// Problem describes why the report is degraded: the discovery error, or that of each degraded
+// endpoint.
+func (r Report) Problem() string {
+	if r.DiscoveryError != "" || len(r.Endpoints) == 0 {
+		return r.DiscoveryError
+	}
+	var problems []string
+	for _, e := range r.Endpoints {
+		if e.State == StateDegraded {
+			problems = append(problems, e.Name+": "+e.DiscoveryError)
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// Tracker records watch-dog's state. It is safe for concurrent use.
+type Tracker struct {
+	mu     sync.Mutex
+	report Report
+
+	// parent is the tracker this endpoint tracker reports to (see Endpoint).
+	parent *Tracker
+	// endpoints are the trackers returned by Endpoint, in creation order.
+	endpoints []*Tracker
+}
+
+// NewTracker returns a tracker in StateStarting for the given compose path.
+func NewTracker(composePath string) *Tracker {
+	return &Tracker{report: Report{State: StateStarting, Since: time.Now(), ComposePath: composePath}}
+}
+
+// Endpoint returns a tracker in StateStarting for the discovery of the named Docker endpoint,
+// reported in t's Report.Endpoints. Once t has endpoints, its own state is derived from theirs:
+// degraded while any endpoint is degraded, starting while any is starting, running otherwise.
+func (t *Tracker) Endpoint(name, composePath string) *Tracker {
+	e := NewTracker(composePath)
+	e.report.Name = name
+	e.parent = t
+	t.mu.Lock()
+	t.endpoints = append(t.endpoints, e)
+	t.mu.Unlock()
+	t.aggregate()
+	return e
+}
+
+// aggregate sets t's state from its endpoints' states.
+func (t *Tracker) aggregate() {
+	t.mu.Lock()
+	endpoints := slices.Clone(t.endpoints)
+	t.mu.Unlock()
+	state := StateRunning
+	for _, e := range endpoints {
+		switch e.Report().State {
+		case StateDegraded:
+			state = StateDegraded
+		case StateStarting:
+			if state != StateDegraded {
+				state = StateStarting
+			}
+		}
+	}
+	t.mu.Lock()
+	t.setState(state)
+	t.mu.Unlock()
+}
+
+// changed propagates a state change of an endpoint tracker to its parent.
+func (t *Tracker) changed() {
+	if t.parent != nil {
+		t.parent.aggregate()
+	}
+}
+
+// DiscoveryOK records a successful discovery with the given parents. It returns true when this
+// ends a degraded period.
+func (t *Tracker) DiscoveryOK(parents []string) (recovered bool) {
+	defer t.changed()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recovered = t.report.State == StateDegraded
+	t.setState(StateRunning)
+	t.report.Parents = slices.Sorted(slices.Values(parents))
+	t.report.LastDiscovery = time.Now()
+	t.report.DiscoveryError = ""
+	t.report.DiscoveryFailures = 0
+	return recovered
+}
+
+// DiscoveryFailed records a failed discovery. It returns true when this starts a degraded period.
+// Parents from the last successful discovery are kept.
+func (t *Tracker) DiscoveryFailed(err error) (degraded bool) {
+	defer t.changed()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	degraded = t.report.State != StateDegraded
+	t.setState(StateDegraded)
+	t.report.DiscoveryError = err.Error()
+	t.report.DiscoveryFailures++
+	return degraded
+}
+
+// Reloaded records a configuration reload with the (possibly changed) compose path; err is
+// non-nil when the new configuration was rejected.
+func (t *Tracker) Reloaded(composePath string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report.LastReload = time.Now()
+	if err != nil {
+		t.report.ReloadError = err.Error()
+		return
+	}
+	t.report.ReloadError = ""
+	t.report.ComposePath = composePath
+}
+
+// SetRole records this replica's election role and the current lease holder.
+func (t *Tracker) SetRole(role, leader string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.report.Role != role {
+		t.report.Role = role
+		t.report.RoleSince = time.Now()
+	}
+	t.report.Leader = leader
+}
+
+// Degraded reports whether the last discovery failed.
+func (t *Tracker) Degraded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.report.State == StateDegraded
+}
+
+// Report returns a snapshot of the current state.
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.report
+	r.Parents = slices.Clone(r.Parents)
+	for _, e := range t.endpoints {
+		r.Endpoints = append(r.Endpoints, e.Report())
+	}
+	return r
+}
+
+func (t *Tracker) setState(s string) {
+	if t.report.State != s {
+		t.report.State = s
+		t.report.Since = time.Now()
+	}
+}
@@ -0,0 +1,227 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrackerTransitions(t *testing.T) {
+	tr := NewTracker("/app/docker-compose.yml")
+	if rep := tr.Report(); rep.State != StateStarting || !rep.Healthy() {
+		t.Fatalf("initial report = %+v, want healthy starting", rep)
+	}
+	if !tr.DiscoveryFailed(errors.New("open compose: no such file")) {
+		t.Error("first failure did not report entering degraded state")
+	}
+	if tr.DiscoveryFailed(errors.New("still missing")) {
+		t.Error("second failure reported entering degraded state again")
+	}
+	rep := tr.Report()
+	if rep.State != StateDegraded || rep.DiscoveryFailures != 2 || rep.DiscoveryError != "still missing" {
+		t.Fatalf("degraded report = %+v", rep)
+	}
+	if !tr.DiscoveryOK([]string{"vpn", "db"}) {
+		t.Error("success after failures did not report recovery")
+	}
+	rep = tr.Report()
+	if rep.State != StateRunning || rep.DiscoveryError != "" || rep.DiscoveryFailures != 0 || rep.LastDiscovery.IsZero() {
+		t.Fatalf("recovered report = %+v", rep)
+	}
+	if strings.Join(rep.Parents, ",") != "db,vpn" {
+		t.Errorf("parents = %v, want sorted [db vpn]", rep.Parents)
+	}
+	if tr.DiscoveryOK(nil) {
+		t.Error("success while running reported recovery")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tr := NewTracker("/app/docker-compose.yml")
+	srv := httptest.NewServer(Handler(tr, nil, ""))
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var b strings.Builder
+		if _, err := io.Copy(&b, resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, b.String()
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz while starting = %d, want 200", code)
+	}
+	tr.DiscoveryFailed(errors.New("yaml: line 3: did not find expected key"))
+	code, body := get("/healthz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "did not find expected key") {
+		t.Errorf("healthz while degraded = %d %q, want 503 with the error", code, body)
+	}
+
+	code, body = get("/status")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	var rep Report
+	if err := json.Unmarshal([]byte(body), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.State != StateDegraded || rep.ComposePath != "/app/docker-compose.yml" || rep.DiscoveryFailures != 1 {
+		t.Errorf("status report = %+v", rep)
+	}
+
+	tr.DiscoveryOK([]string{"db"})
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz after recovery = %d, want 200", code)
+	}
+
+	if _, body := get("/metrics"); !strings.Contains(body, "watchdog_parents 1\n") || strings.Contains(body, "watchdog_leader") {
+		t.Errorf("metrics without election:\n%s", body)
+	}
+	tr.SetRole(RoleStandby, "watch-dog-2")
+	_, body = get("/metrics")
+	if !strings.Contains(body, "watchdog_leader 0\n") || !strings.Contains(body, "watchdog_degraded 0\n") {
+		t.Errorf("metrics of standby:\n%s", body)
+	}
+	if rep := tr.Report(); rep.Role != RoleStandby || rep.Leader != "watch-dog-2" {
+		t.Errorf("report role = %q leader = %q", rep.Role, rep.Leader)
+	}
+}
+
+func TestHandler_reload(t *testing.T) {
+	tr := NewTracker("")
+	var fail error
+	srv := httptest.NewServer(Handler(tr, func(context.Context) (ReloadResult, error) {
+		if fail != nil {
+			return ReloadResult{}, fail
+		}
+		return ReloadResult{Changed: []string{"log_level", "status_addr"}, RestartRequired: []string{"status_addr"}}, nil
+	}, ""))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res ReloadResult
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("reload = %d, %v", resp.StatusCode, err)
+	}
+	if len(res.Changed) != 2 || len(res.RestartRequired) != 1 {
+		t.Errorf("reload result = %+v", res)
+	}
+
+	fail = errors.New("poll_interval (file): must be positive")
+	resp, err = http.Post(srv.URL+"/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("invalid reload = %d, want 422", resp.StatusCode)
+	}
+
+	if resp, err := http.Get(srv.URL + "/reload"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("GET /reload = %d, want 405", resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_reloadToken(t *testing.T) {
+	reloads := 0
+	srv := httptest.NewServer(Handler(NewTracker(""), func(context.Context) (ReloadResult, error) {
+		reloads++
+		return ReloadResult{}, nil
+	}, "s3cret"))
+	defer srv.Close()
+
+	tests := []struct {
+		name, auth string
+		want       int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer guess", http.StatusUnauthorized},
+		{"not bearer", "Basic s3cret", http.StatusUnauthorized},
+		{"token", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/reload", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: reload = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+	if reloads != 1 {
+		t.Errorf("reloads = %d, want only the authorized one", reloads)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:9090": true,
+		"[::1]:9090":     true,
+		"localhost:9090": true,
+		":9090":          false,
+		"0.0.0.0:9090":   false,
+		"10.0.0.5:9090":  false,
+		"off":            false,
+	}
+	for addr, want := range tests {
+		if got := Loopback(addr); got != want {
+			t.Errorf("Loopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestTracker_endpoints(t *testing.T) {
+	tr := NewTracker("")
+	nas := tr.Endpoint("nas", "/stacks/nas.yml")
+	pi := tr.Endpoint("pi", "/stacks/pi.yml")
+	if rep := tr.Report(); rep.State != StateStarting || len(rep.Endpoints) != 2 {
+		t.Fatalf("initial report = %+v", rep)
+	}
+	nas.DiscoveryOK([]string{"vpn"})
+	pi.DiscoveryFailed(errors.New("connection refused"))
+	rep := tr.Report()
+	if rep.State != StateDegraded || rep.Problem() != "pi: connection refused" {
+		t.Errorf("report = %q %q, want degraded by pi", rep.State, rep.Problem())
+	}
+	if rep.Endpoints[0].State != StateRunning || rep.Endpoints[0].Parents[0] != "vpn" {
+		t.Errorf("nas = %+v, want running despite pi", rep.Endpoints[0])
+	}
+
+	var b strings.Builder
+	writeMetrics(&b, rep)
+	for _, want := range []string{`watchdog_degraded{host="nas"} 0`, `watchdog_degraded{host="pi"} 1`, `watchdog_parents{host="nas"} 1`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, b.String())
+		}
+	}
+
+	pi.DiscoveryOK(nil)
+	if rep := tr.Report(); rep.State != StateRunning {
+		t.Errorf("state after pi recovered = %q, want running", rep.State)
+	}
+}
@@ -0,0 +1,86 @@
+package supervisor
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// cooldowns tracks last recovery time and in-flight recovery per parent
+// to avoid re-running recovery on duplicate events (stop + die) or overlapping runs.
+type cooldowns struct {
+	mu       sync.Mutex
+	last     map[string]time.Time
+	inFlight map[string]bool
+}
+
+// start checks cooldown and in-flight for parentName. If allowed, marks the parent in-flight and
+// sets its last recovery time to now, then returns true. Caller must call end when recovery
+// finishes (e.g. defer after start returns true).
+func (c *cooldowns) start(parentName string, now time.Time, cooldown time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		c.last = make(map[string]time.Time)
+	}
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]bool)
+	}
+	if c.inFlight[parentName] {
+		return false
+	}
+	if t, ok := c.last[parentName]; ok && now.Sub(t) < cooldown {
+		return false
+	}
+	c.inFlight[parentName] = true
+	c.last[parentName] = now
+	return true
+}
+
+// restore seeds last recovery times (e.g. from the journal at startup) so the recovery cooldown
+// still applies to parents recovered shortly before watch-dog restarted.
+func (c *cooldowns) restore(last map[string]time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		c.last = make(map[string]time.Time)
+	}
+	for parent, t := range last {
+		if t.After(c.last[parent]) {
+			c.last[parent] = t
+		}
+	}
+}
+
+// hold marks parentName in-flight without starting its cooldown, so events for the parent do not
+// start a recovery while its interrupted sequence is resumed. It returns false when a recovery is
+// already in flight. Call end when done.
+func (c *cooldowns) hold(parentName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]bool)
+	}
+	if c.inFlight[parentName] {
+		return false
+	}
+	c.inFlight[parentName] = true
+	return true
+}
+
+// end clears the in-flight mark for parentName. Call when recovery for that parent finishes.
+func (c *cooldowns) end(parentName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight != nil {
+		delete(c.inFlight, parentName)
+	}
+}
+
+// inFlightNames returns the parents marked in-flight, in name order.
+func (c *cooldowns) inFlightNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Sorted(maps.Keys(c.inFlight))
+}
@@ -0,0 +1,75 @@
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"watch-dog/internal/discovery"
+)
+
+// discoveryRetryMax caps the backoff between discovery retries while degraded.
+const discoveryRetryMax = time.Minute
+
+// Source discovers the dependency graph of the containers a Supervisor supervises.
+type Source interface {
+	Graph(ctx context.Context, rt Runtime) (*Graph, error)
+}
+
+// ComposeFile discovers the graph from the depends_on (and network_mode) relations of a compose
+// file, mapped to the running containers by their compose labels.
+type ComposeFile struct {
+	// Path returns the compose file's path. It is called on every discovery, so it can follow
+	// configuration reloads; "" discovers no parents.
+	Path func() string
+}
+
+// Graph builds the graph from the compose file (see discovery.BuildGraphFromCompose).
+func (c ComposeFile) Graph(ctx context.Context, rt Runtime) (*Graph, error) {
+	return discovery.BuildGraphFromCompose(ctx, rt, c.Path())
+}
+
+// String returns the compose file's path, for logging.
+func (c ComposeFile) String() string {
+	return c.Path()
+}
+
+// Discover builds the dependency graph now and records the result in the Supervisor's status.
+// The transition into the degraded state (e.g. compose file missing or half-edited, or the host
+// unreachable) and out of it is logged once. Run calls it on every event and poll; call it to pick
+// up a changed compose file right away.
+func (s *Supervisor) Discover(ctx context.Context) (*Graph, error) {
+	g, err := s.source.Graph(ctx, s.cli)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if s.status.DiscoveryFailed(err) {
+			s.log.Warn("discovery failed, running degraded until it succeeds", "source", s.source, "error", err)
+		} else {
+			s.log.Debug("discovery still failing", "error", err)
+		}
+		return nil, err
+	}
+	s.lastGraph.Store(g)
+	if s.status.DiscoveryOK(g.ParentNames()) {
+		s.log.Info("discovery succeeded, leaving degraded state", "parents", g.ParentNames())
+	}
+	return g, nil
+}
+
+// waitForDiscovery retries Discover with exponential backoff (2s, doubling up to
+// discoveryRetryMax) until it succeeds. Returns nil when ctx is done first.
+func (s *Supervisor) waitForDiscovery(ctx context.Context) *Graph {
+	backoff := 2 * time.Second
+	for {
+		if g, err := s.Discover(ctx); err == nil {
+			return g
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.clock.After(backoff):
+			backoff = min(backoff*2, discoveryRetryMax)
+		}
+	}
+}
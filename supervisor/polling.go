@@ -0,0 +1,158 @@
+package supervisor
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
+)
+
+// pollScheduler picks the interval until the next poll: fast while any parent needs attention,
+// the base interval while the event stream is down, and otherwise doubling up to max.
+type pollScheduler struct {
+	base, fast, max time.Duration
+	current         time.Duration
+}
+
+// next returns the delay before the next poll. attention is true when the last poll saw a parent
+// that is unhealthy, starting or not running; streamUp reports whether the event stream is active.
+func (s *pollScheduler) next(attention, streamUp bool) time.Duration {
+	switch {
+	case attention:
+		s.current = s.fast
+	case !streamUp || s.current < s.base:
+		s.current = s.base
+	default:
+		s.current = min(s.current*2, s.max)
+	}
+	return s.current
+}
+
+// recoveryTarget is a parent found by scanParents that needs recovery.
+type recoveryTarget struct {
+	id, name string
+	// reason is the container state (e.g. "exited") or "unhealthy".
+	reason string
+}
+
+// scanParents lists containers and inspects running parents, at most concurrency at a time.
+// It returns the parents that are stopped or unhealthy (sorted by name) and whether any parent
+// needs attention (also true for health "starting"). Inspect errors are logged to log, prefixed
+// with the trigger of the scan (e.g. "polling" or "startup"), and skipped.
+func scanParents(ctx context.Context, cli engine.Runtime, log *docker.Logger, m discovery.ParentToDependents, concurrency int, trigger string) (targets []recoveryTarget, attention bool, err error) {
+	containers, err := cli.ListContainers(ctx, true)
+	if err != nil {
+		return nil, false, err
+	}
+	nameToID, nameToState := buildContainerMaps(containers)
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(concurrency, 1))
+	)
+	for parentName := range m {
+		id, ok := nameToID[parentName]
+		if !ok {
+			continue
+		}
+		if state := nameToState[parentName]; state != "running" {
+			targets = append(targets, recoveryTarget{id: id, name: parentName, reason: state})
+			attention = true
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			st, err := cli.Inspect(ctx, id)
+			if err != nil {
+				log.Debug(trigger+": inspect failed", "parent", name, "error", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch st.Health {
+			case "unhealthy":
+				targets = append(targets, recoveryTarget{id: id, name: name, reason: "unhealthy"})
+				attention = true
+			case "starting":
+				attention = true
+			}
+		}(id, parentName)
+	}
+	wg.Wait()
+	slices.SortFunc(targets, func(a, b recoveryTarget) int { return strings.Compare(a.name, b.name) })
+	return targets, attention, nil
+}
+
+// runPollingFallback periodically rechecks parent health and triggers recovery if unhealthy.
+// The interval adapts (see pollScheduler); recovery runs only after the initial discovery phase
+// is complete, see Recovering.
+func (s *Supervisor) runPollingFallback(ctx context.Context) {
+	p := s.Policy()
+	sched := &pollScheduler{current: p.PollInterval}
+	timer := s.clock.NewTimer(p.PollInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+		}
+		attention := false
+		if s.Recovering() {
+			attention = s.pollOnce(ctx)
+		}
+		// Intervals are re-read each time so a policy change applies from the next poll.
+		p := s.Policy()
+		sched.base, sched.fast, sched.max = p.PollInterval, p.PollIntervalFast, p.PollIntervalMax
+		d := sched.next(attention, s.eventStreamUp.Load())
+		s.log.Debug("polling: next poll scheduled", "in", d.String(), "attention", attention)
+		timer.Reset(d)
+	}
+}
+
+// pollOnce rebuilds discovery, scans parents and runs recovery for those that need it.
+// Returns whether any parent needed attention.
+func (s *Supervisor) pollOnce(ctx context.Context) bool {
+	graph, err := s.Discover(ctx)
+	if err != nil {
+		return false
+	}
+	targets, attention, err := scanParents(ctx, s.cli, s.log, graph.ParentToDependents, s.Policy().PollConcurrency, "polling")
+	if err != nil {
+		return false
+	}
+	for _, t := range targets {
+		s.tryRecoverParent(t.id, t.name, t.reason, shortID(t.id), "polling", graph)
+	}
+	s.startAllHeld(graph, targets)
+	return attention
+}
+
+// startAllHeld starts the dependents held stopped by parents in graph that are not among the
+// failed targets of a scan, if those parents are healthy now (see startHeld).
+func (s *Supervisor) startAllHeld(graph *Graph, targets []recoveryTarget) {
+	for _, parent := range graph.ParentNames() {
+		if !slices.ContainsFunc(targets, func(t recoveryTarget) bool { return t.name == parent }) {
+			s.startHeld(parent, graph)
+		}
+	}
+}
+
+// buildContainerMaps builds name→ID and name→state maps from the given containers.
+func buildContainerMaps(containers []engine.ContainerInfo) (nameToID, nameToState map[string]string) {
+	nameToID = make(map[string]string)
+	nameToState = make(map[string]string)
+	for _, c := range containers {
+		nameToID[c.Name] = c.ID
+		nameToState[c.Name] = c.State
+	}
+	return nameToID, nameToState
+}
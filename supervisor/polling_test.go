@@ -0,0 +1,28 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollScheduler_next(t *testing.T) {
+	s := &pollScheduler{base: time.Minute, fast: 10 * time.Second, max: 5 * time.Minute, current: time.Minute}
+	steps := []struct {
+		attention, streamUp bool
+		want                time.Duration
+	}{
+		{false, true, 2 * time.Minute},  // calm, stream up: back off
+		{false, true, 4 * time.Minute},  // keep backing off
+		{false, true, 5 * time.Minute},  // capped at max
+		{true, true, 10 * time.Second},  // parent unhealthy/starting: fast
+		{true, false, 10 * time.Second}, // still fast regardless of stream
+		{false, true, time.Minute},      // calm again: back to base first
+		{false, false, time.Minute},     // stream down: stay at base
+		{false, true, 2 * time.Minute},
+	}
+	for i, st := range steps {
+		if got := s.next(st.attention, st.streamUp); got != st.want {
+			t.Errorf("step %d next(%v, %v) = %v, want %v", i, st.attention, st.streamUp, got, st.want)
+		}
+	}
+}
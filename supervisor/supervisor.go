@@ -0,0 +1,506 @@
+// Package supervisor supervises the containers of one container host: it discovers which
+// containers depend on which, watches health-status events with a polling fallback, and when a
+// parent becomes unhealthy or stops, runs recovery (restart the parent, wait until it is healthy,
+// then restart its dependents). It is the core of the watch-dog binary, which runs one Supervisor
+// per configured endpoint, and can be embedded in other programs the same way:
+//
+//	s := supervisor.New(supervisor.Options{
+//		Runtime: rt,
+//		Source:  supervisor.ComposeFile{Path: func() string { return "/srv/docker-compose.yml" }},
+//	})
+//	s.Run(ctx)
+//
+// No recovery runs during an initial discovery phase after Run starts (Policy.InitialDiscoveryWait);
+// see specs/004-child-deps-initial-restart/contracts/initial-discovery-behavior.md.
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"watch-dog/internal/clock"
+	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/recovery"
+	"watch-dog/internal/status"
+)
+
+// Options configure a Supervisor. Runtime and Source are required.
+type Options struct {
+	// Name names the supervised host; it labels log lines (host=<name>) and journal entries.
+	// Empty when there is a single host.
+	Name string
+	// Runtime is the container runtime of the host. The Supervisor does not close it.
+	Runtime Runtime
+	// Source discovers the dependency graph; it is rebuilt on every event and poll.
+	Source Source
+	// Policy is the initial policy (see SetPolicy).
+	Policy Policy
+	// Clock times the initial discovery phase, cooldowns, waits and polling (nil = the real clock).
+	Clock Clock
+	// Journal, when non-nil, records every recovery attempt, step and outcome (e.g. a *journal.Journal).
+	Journal Recorder
+	// Notifier, when non-nil, is told about the same entries as Journal.
+	Notifier Notifier
+	// Leader, when non-nil, gates recovery: only while it reports leadership are containers
+	// recovered (e.g. a *leader.Elector). Nil means always leader.
+	Leader Leader
+	// Status, when non-nil, receives the discovery state (e.g. for the status API); otherwise the
+	// Supervisor keeps its own, see Status.
+	Status *status.Tracker
+	// SelfName is the container name of the program embedding the Supervisor, restarted last when
+	// it is a dependent ("" disables this).
+	SelfName string
+}
+
+// Policy is the part of a Supervisor's configuration that can be replaced while it runs.
+type Policy struct {
+	// InitialDiscoveryWait is how long after Run starts no recovery runs (0 = recover right away).
+	// It is read once, when Run starts.
+	InitialDiscoveryWait time.Duration
+	// RecoveryCooldown is the minimum time between recoveries of the same parent (0 = none).
+	RecoveryCooldown time.Duration
+	// PollInterval is the base interval of the polling fallback (0 = 60s).
+	PollInterval time.Duration
+	// PollIntervalFast is the interval while a parent needs attention (0 or more than
+	// PollInterval = PollInterval).
+	PollIntervalFast time.Duration
+	// PollIntervalMax caps the interval while the event stream is up (less than PollInterval =
+	// PollInterval).
+	PollIntervalMax time.Duration
+	// PollConcurrency is how many parents are inspected at a time per poll (0 = 4).
+	PollConcurrency int
+	// Recovery configures the recovery sequences (see recovery.Policy).
+	Recovery RecoveryPolicy
+}
+
+// withDefaults returns p with the polling settings that must be set filled in.
+func (p Policy) withDefaults() Policy {
+	if p.PollInterval <= 0 {
+		p.PollInterval = time.Minute
+	}
+	if p.PollIntervalFast <= 0 || p.PollIntervalFast > p.PollInterval {
+		p.PollIntervalFast = p.PollInterval
+	}
+	p.PollIntervalMax = max(p.PollIntervalMax, p.PollInterval)
+	if p.PollConcurrency <= 0 {
+		p.PollConcurrency = 4
+	}
+	return p
+}
+
+// Notifier is told about every recovery attempt, step and outcome, e.g. to forward them to a chat
+// or paging system. Notify runs on the recovery path, so it must not block for long.
+type Notifier interface {
+	Notify(e Entry)
+}
+
+// Leader reports whether this replica may recover containers (implemented by *leader.Elector).
+type Leader interface {
+	IsLeader() bool
+	// Holder returns the ID of the replica that is leader, for logging.
+	Holder() string
+}
+
+// alwaysLeader is the Leader of a Supervisor without leader election.
+type alwaysLeader struct{}
+
+func (alwaysLeader) IsLeader() bool { return true }
+func (alwaysLeader) Holder() string { return "" }
+
+// Supervisor supervises the containers of one host. Create it with New; it is safe for
+// concurrent use.
+type Supervisor struct {
+	name     string
+	cli      Runtime
+	source   Source
+	flow     *recovery.Flow
+	cooldown *cooldowns
+	status   *status.Tracker
+	log      *docker.Logger
+	clock    clock.Clock
+	leader   Leader
+	selfName string
+	policy   atomic.Pointer[Policy]
+
+	// pending are the dependents that interrupted sequences left to restart (see resumePending).
+	// It is emptied once the leader resumes them.
+	pendingMu sync.Mutex
+	pending   map[string][]string
+
+	// recoveryCtx is the context recovery sequences run under. Unlike Run's context it is only
+	// canceled by Abort, so running sequences can finish their current step (see Drain).
+	recoveryCtx      context.Context
+	cancelRecoveries context.CancelFunc
+
+	// phaseEnd is the end of the initial discovery phase, set when Run starts.
+	phaseEnd atomic.Pointer[time.Time]
+	// lastGraph is the graph of the last successful discovery.
+	lastGraph atomic.Pointer[Graph]
+	// eventStreamUp is true while the event subscription is active; polling backs off while it is.
+	eventStreamUp atomic.Bool
+}
+
+// New returns a Supervisor for opts. It does not connect to the runtime; call Run.
+func New(opts Options) *Supervisor {
+	s := &Supervisor{
+		name:     opts.Name,
+		cli:      opts.Runtime,
+		source:   opts.Source,
+		cooldown: &cooldowns{},
+		status:   opts.Status,
+		log:      docker.HostLogger(opts.Name),
+		clock:    clock.Or(opts.Clock),
+		leader:   opts.Leader,
+		selfName: opts.SelfName,
+	}
+	if s.status == nil {
+		s.status = status.NewTracker("")
+	}
+	if s.leader == nil {
+		s.leader = alwaysLeader{}
+	}
+	s.flow = &recovery.Flow{Client: opts.Runtime, Host: opts.Name, Clock: s.clock}
+	switch {
+	case opts.Notifier != nil:
+		s.flow.Journal = notifyingRecorder{journal: opts.Journal, notifier: opts.Notifier}
+	case opts.Journal != nil:
+		s.flow.Journal = opts.Journal
+	}
+	s.recoveryCtx, s.cancelRecoveries = context.WithCancel(context.Background())
+	s.SetPolicy(opts.Policy)
+	return s
+}
+
+// notifyingRecorder appends entries to the journal (if any) and passes them to the notifier.
+type notifyingRecorder struct {
+	journal  Recorder
+	notifier Notifier
+}
+
+func (r notifyingRecorder) Append(e Entry) error {
+	var err error
+	if r.journal != nil {
+		err = r.journal.Append(e)
+	}
+	r.notifier.Notify(e)
+	return err
+}
+
+// Name returns the supervised host's name (Options.Name).
+func (s *Supervisor) Name() string {
+	return s.name
+}
+
+// Runtime returns the supervised host's container engine.
+func (s *Supervisor) Runtime() Runtime {
+	return s.cli
+}
+
+// Status returns the tracker of the Supervisor's discovery state.
+func (s *Supervisor) Status() *status.Tracker {
+	return s.status
+}
+
+// Graph returns the dependency graph of the last successful discovery, or nil before one succeeded.
+func (s *Supervisor) Graph() *Graph {
+	return s.lastGraph.Load()
+}
+
+// EventStreamUp reports whether the health-status event subscription is active.
+func (s *Supervisor) EventStreamUp() bool {
+	return s.eventStreamUp.Load()
+}
+
+// Recovering reports whether the initial discovery phase is over, i.e. whether unhealthy or stopped
+// parents are recovered. It is false before Run.
+func (s *Supervisor) Recovering() bool {
+	end := s.phaseEnd.Load()
+	return end != nil && !s.clock.Now().Before(*end)
+}
+
+// InFlight returns the parents whose recovery is in progress, in name order.
+func (s *Supervisor) InFlight() []string {
+	return s.cooldown.inFlightNames()
+}
+
+// Policy returns the current policy.
+func (s *Supervisor) Policy() Policy {
+	return *s.policy.Load()
+}
+
+// SetPolicy atomically replaces the policy (e.g. on a configuration reload). Cooldowns, recoveries
+// in progress and the event subscription are left alone; polling picks up new intervals from the
+// next poll and running sequences the new recovery policy from their next step.
+func (s *Supervisor) SetPolicy(p Policy) {
+	p = p.withDefaults()
+	s.policy.Store(&p)
+	s.flow.SetPolicy(p.Recovery)
+}
+
+// Restore seeds the Supervisor with the state of a previous run read from the recovery journal
+// (see journal.Restore): recovery and dependent cooldowns still apply after a restart, the
+// dependents that interrupted sequences did not get to are restarted once discovery succeeds, and
+// dependents a stop-first recovery left stopped stay held until their parent is healthy. Call
+// it before Run, with the entries of this host only (see journal.ForHost).
+func (s *Supervisor) Restore(state JournalState) {
+	s.pendingMu.Lock()
+	s.pending = state.Pending
+	s.pendingMu.Unlock()
+	s.cooldown.restore(state.LastRecovery)
+	s.flow.RestoreDependentRestarts(state.LastDependentRestart)
+	s.flow.RestoreHeldStopped(state.Held)
+}
+
+// Drain stops the Supervisor from starting recoveries and lets running ones stop after their
+// current step, journaling the dependents they did not get to. It returns once none is running, or
+// with ctx's error when ctx ends first; call Abort then. Run's context is independent: cancel it to
+// stop watching events and polling.
+func (s *Supervisor) Drain(ctx context.Context) error {
+	return s.flow.Drain(ctx)
+}
+
+// Abort cancels the recoveries still running, cutting their current step short.
+func (s *Supervisor) Abort() {
+	s.cancelRecoveries()
+}
+
+// Run supervises the host until ctx is done: first discovery, resuming interrupted sequences,
+// startup reconciliation once the initial discovery phase is over, then health-status events and
+// the polling fallback. It returns when all of them have stopped. Run must be called only once.
+func (s *Supervisor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Initial discovery phase: no recovery until first discovery + wait has elapsed (specs/004-child-deps-initial-restart).
+	wait := s.Policy().InitialDiscoveryWait
+	end := s.clock.Now().Add(wait)
+	s.phaseEnd.Store(&end)
+	s.log.Info("initial discovery started", "wait", wait.String())
+
+	// A missing or broken compose file does not stop the Supervisor: it starts degraded and keeps
+	// retrying discovery in the background until the file can be read.
+	graph, err := s.Discover(ctx)
+	switch {
+	case err != nil:
+		s.log.Warn("started degraded, waiting for a readable compose file", "source", s.source)
+		wg.Go(func() { s.waitForDiscovery(ctx) })
+	case len(graph.ParentNames()) == 0:
+		s.log.Warn("no parents discovered; set WATCHDOG_COMPOSE_PATH and mount the compose file", "source", s.source)
+	default:
+		s.log.Info("watch-dog started", "parents", graph.ParentNames())
+	}
+
+	// Sequences interrupted by the last shutdown (or by watch-dog restarting itself) are completed
+	// as soon as discovery succeeds, without waiting for the initial discovery phase.
+	if s.hasPending() {
+		wg.Go(func() {
+			if built := s.waitForDiscovery(ctx); built != nil {
+				s.resumePending(built)
+			}
+		})
+	}
+
+	// Run startup reconciliation exactly once when initial discovery phase ends (not at startup).
+	// Post-phase: no cascade—reconciliation runs once; cooldown/in-flight prevent duplicate runs (contracts/initial-discovery-behavior.md).
+	wg.Go(func() {
+		select {
+		case <-ctx.Done():
+			s.log.Info("shutdown during initial discovery wait, skipping startup reconciliation")
+			return
+		case <-s.clock.After(s.clock.Until(end)):
+		}
+		s.log.Info("initial discovery complete, recovery enabled")
+		// While degraded, reconciliation waits (with backoff) for discovery to succeed.
+		if built := s.waitForDiscovery(ctx); built != nil {
+			s.reconcile(ctx, built, "startup")
+		}
+	})
+
+	wg.Go(func() { s.runPollingFallback(ctx) })
+	s.watchEvents(ctx)
+}
+
+// watchEvents subscribes to the host's health-status events and recovers parents that become
+// unhealthy or stop. When the event stream ends (e.g. the daemon restarted or the host is
+// unreachable) it subscribes again with backoff; the polling fallback covers the gap.
+func (s *Supervisor) watchEvents(ctx context.Context) {
+	backoff := 2 * time.Second
+	for {
+		healthCh := make(chan HealthEvent, 8)
+		s.cli.SubscribeHealthStatus(ctx, healthCh)
+		s.eventStreamUp.Store(true)
+		subscribed := s.clock.Now()
+		for ev := range healthCh {
+			s.handleEvent(ctx, ev)
+		}
+		s.eventStreamUp.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if s.clock.Since(subscribed) > discoveryRetryMax {
+			backoff = 2 * time.Second
+		}
+		s.log.Warn("docker event stream ended, subscribing again", "in", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(backoff):
+			backoff = min(backoff*2, discoveryRetryMax)
+		}
+	}
+}
+
+// handleEvent recovers the event's container if it is a parent and the initial discovery phase is
+// over, or, when a parent becomes healthy, starts the dependents its recovery left stopped.
+func (s *Supervisor) handleEvent(ctx context.Context, ev HealthEvent) {
+	if !s.Recovering() {
+		return
+	}
+	healthy := ev.Status == engine.EventHealthy
+	if healthy && len(s.flow.HeldDependents(ev.ContainerName)) == 0 {
+		return
+	}
+	graph, err := s.Discover(ctx)
+	if err != nil {
+		// While degraded, keep supervising the parents of the last successful discovery.
+		if graph = s.lastGraph.Load(); graph == nil {
+			return
+		}
+	}
+	if !graph.IsParent(ev.ContainerName) {
+		return
+	}
+	if healthy {
+		s.startHeld(ev.ContainerName, graph)
+		return
+	}
+	s.tryRecoverParent(ev.ContainerID, ev.ContainerName, ev.Status, shortID(ev.ContainerID), "event", graph)
+}
+
+// tryRecoverParent runs recovery for a parent if cooldown allows: start, then defer end, then RunFullSequence.
+// The sequence runs under recoveryCtx, so a shutdown lets it finish its current step (see Drain).
+// reason describes why recovery was triggered (e.g. "stop", "unhealthy"). idShort is the short container ID for logging.
+// trigger is "event", "startup", "takeover" or "polling". Only the leader recovers (see Options.Leader).
+// INFO recovery log is emitted only when recovery actually runs (after cooldown check).
+func (s *Supervisor) tryRecoverParent(parentID, parentName, reason, idShort, trigger string, graph *Graph) {
+	if !s.leader.IsLeader() {
+		s.log.Debug("skipping recovery, not the leader", "parent", parentName, "id", parentID, "leader", s.leader.Holder())
+		return
+	}
+	if s.flow.HeldStopped(parentName) {
+		s.log.Debug("skipping recovery, stopped by stop-first recovery of its parent", "parent", parentName, "id", parentID)
+		return
+	}
+	if !s.cooldown.start(parentName, s.clock.Now(), s.Policy().RecoveryCooldown) {
+		s.log.Debug("skipping recovery, in cooldown or in flight", "parent", parentName, "id", parentID)
+		return
+	}
+	defer s.cooldown.end(parentName)
+	s.log.InfoRecovery(fmt.Sprintf("recovery: attempting recovery for parent %q (reason: %s, trigger: %s)", parentName, reason, trigger), "parent", parentName, "reason", reason, "id_short", idShort, "trigger", trigger)
+	s.flow.RunFullSequence(s.recoveryCtx, parentID, parentName, reason, trigger, graph, s.selfName)
+}
+
+// startHeld starts the dependents of parentName that a stop-first recovery left stopped, if the
+// parent is healthy now (see recovery.Flow.StartHeld). Like a recovery, it only runs on the leader
+// and not while a recovery of the parent is in flight.
+func (s *Supervisor) startHeld(parentName string, graph *Graph) {
+	if len(s.flow.HeldDependents(parentName)) == 0 || !s.leader.IsLeader() {
+		return
+	}
+	if !s.cooldown.hold(parentName) {
+		return
+	}
+	defer s.cooldown.end(parentName)
+	s.flow.StartHeld(s.recoveryCtx, parentName, graph, s.selfName)
+}
+
+// TakeOver is called when this replica becomes leader: it resumes the sequences interrupted before
+// watch-dog started, which a standby leaves alone (waiting for discovery to succeed if it has not
+// yet), then recovers the parents that failed meanwhile (see Reconcile).
+func (s *Supervisor) TakeOver(ctx context.Context) {
+	if s.hasPending() {
+		graph := s.lastGraph.Load()
+		if graph == nil {
+			graph = s.waitForDiscovery(ctx)
+		}
+		if graph != nil {
+			s.resumePending(graph)
+		}
+	}
+	s.Reconcile(ctx, "takeover")
+}
+
+// Reconcile recovers the parents of the last discovered graph that are already unhealthy or
+// stopped, e.g. when this replica just became leader (trigger "takeover"). It does nothing during
+// the initial discovery phase, which ends with such a pass, or before discovery succeeded.
+func (s *Supervisor) Reconcile(ctx context.Context, trigger string) {
+	if !s.Recovering() {
+		return
+	}
+	if graph := s.lastGraph.Load(); graph != nil {
+		s.reconcile(ctx, graph, trigger)
+	}
+}
+
+// reconcile finds parents that are already unhealthy or stopped and runs full recovery.
+// trigger is "startup", or "takeover" when this replica just became leader.
+func (s *Supervisor) reconcile(ctx context.Context, graph *Graph, trigger string) {
+	targets, _, err := scanParents(ctx, s.cli, s.log, graph.ParentToDependents, s.Policy().PollConcurrency, trigger)
+	if err != nil {
+		s.log.Error(trigger+": list containers", "error", err)
+		return
+	}
+	for _, t := range targets {
+		s.tryRecoverParent(t.id, t.name, t.reason, shortID(t.id), trigger, graph)
+	}
+	s.startAllHeld(graph, targets)
+}
+
+// resumePending restarts the dependents that sequences aborted at the last shutdown or cut off
+// by a crash did not get to (see recovery.Flow.ResumeDependents), parent by parent in name order.
+// A parent with a recovery already in flight is skipped; that recovery restarts its dependents.
+// A standby keeps them for when it takes over (see TakeOver); the leader resumes them only once.
+func (s *Supervisor) resumePending(graph *Graph) {
+	s.pendingMu.Lock()
+	pending := s.pending
+	if !s.leader.IsLeader() {
+		s.pendingMu.Unlock()
+		if len(pending) > 0 {
+			s.log.Info("not resuming interrupted recoveries, not the leader", "parents", slices.Sorted(maps.Keys(pending)), "leader", s.leader.Holder())
+		}
+		return
+	}
+	s.pending = nil
+	s.pendingMu.Unlock()
+	for _, parent := range slices.Sorted(maps.Keys(pending)) {
+		if s.recoveryCtx.Err() != nil {
+			return
+		}
+		if !s.cooldown.hold(parent) {
+			continue
+		}
+		s.flow.ResumeDependents(s.recoveryCtx, parent, pending[parent], graph, s.selfName)
+		s.cooldown.end(parent)
+	}
+}
+
+// hasPending reports whether interrupted sequences are left to resume.
+func (s *Supervisor) hasPending() bool {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	return len(s.pending) > 0
+}
+
+// shortID returns the first 12 characters of a container ID.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,240 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"watch-dog/internal/clock/clocktest"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/engine/enginetest"
+	"watch-dog/internal/journal"
+)
+
+// compose is the compose file of the supervised project: torrent depends on vpn being healthy.
+const compose = `
+services:
+  vpn:
+    image: vpn
+  torrent:
+    image: torrent
+    depends_on:
+      vpn:
+        condition: service_healthy
+`
+
+// epoch is the start time of the fake clock in tests.
+var epoch = time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+
+// startSupervisor runs a supervisor of rt with compose on a fake clock until the test ends,
+// with opts for the other options. recovering sets whether the initial discovery phase is over
+// right away; otherwise it ends after an hour of fake time. It returns once the supervisor is
+// subscribed to events.
+func startSupervisor(t *testing.T, rt *enginetest.Runtime, recovering bool, opts Options) (*Supervisor, *clocktest.Fake) {
+	t.Helper()
+	return startRestored(t, rt, recovering, opts, JournalState{})
+}
+
+// startRestored is startSupervisor with state restored from the journal before Run.
+func startRestored(t *testing.T, rt *enginetest.Runtime, recovering bool, opts Options, state JournalState) (*Supervisor, *clocktest.Fake) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(compose), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := clocktest.New(epoch)
+	p := Policy{InitialDiscoveryWait: time.Hour, RecoveryCooldown: 2 * time.Minute}
+	if recovering {
+		p.InitialDiscoveryWait = 0
+	}
+	opts.Runtime, opts.Source, opts.Policy, opts.Clock = rt, ComposeFile{Path: func() string { return path }}, p, fake
+	s := New(opts)
+	s.Restore(state)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		// Recoveries still waiting on the fake clock when the test ends are canceled.
+		s.Abort()
+		<-done
+	})
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	if err := rt.WaitSubscribers(waitCtx, 1); err != nil {
+		t.Fatalf("supervisor did not subscribe to events: %v", err)
+	}
+	return s, fake
+}
+
+func TestSupervisor_startsHeldDependentsOnceParentIsHealthy(t *testing.T) {
+	// A stop-first recovery of vpn left torrent stopped before watch-dog restarted.
+	torrent := enginetest.Service("torrent", "")
+	torrent.Status = "exited"
+	rt := enginetest.New(enginetest.Service("vpn", "starting"), torrent)
+	s, _ := startRestored(t, rt, true, Options{}, JournalState{Held: map[string]string{"torrent": "vpn"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if got := rt.Calls(); len(got) != 0 {
+		t.Fatalf("calls before vpn is healthy = %v, want none", got)
+	}
+	rt.SetHealth("vpn", "healthy")
+	if err := rt.WaitCall(ctx, "start torrent"); err != nil {
+		t.Fatalf("torrent not started: %v (calls %v)", err, rt.Calls())
+	}
+	s.Reconcile(ctx, "test")
+	if got := rt.Calls(); !slices.Equal(got, []string{"start torrent"}) {
+		t.Errorf("calls = %v, want torrent started once", got)
+	}
+}
+
+// standby is a Leader that is leader once elected is set.
+type standby struct{ elected atomic.Bool }
+
+func (l *standby) IsLeader() bool { return l.elected.Load() }
+func (l *standby) Holder() string { return "other" }
+
+func TestSupervisor_takeOverResumesInterruptedSequences(t *testing.T) {
+	rt := enginetest.New(enginetest.Service("vpn", "healthy"), enginetest.Service("torrent", ""))
+	l := &standby{}
+	s, _ := startRestored(t, rt, true, Options{Leader: l}, JournalState{Pending: map[string][]string{"vpn": {"torrent"}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.resumePending(s.Graph())
+	if got := rt.Calls(); len(got) != 0 {
+		t.Fatalf("calls on a standby = %v, want none", got)
+	}
+	l.elected.Store(true)
+	s.TakeOver(ctx)
+	if err := rt.WaitCall(ctx, "restart torrent"); err != nil {
+		t.Fatalf("torrent not restarted: %v (calls %v)", err, rt.Calls())
+	}
+	s.TakeOver(ctx)
+	if got := rt.Calls(); !slices.Equal(got, []string{"restart torrent"}) {
+		t.Errorf("calls = %v, want torrent restarted once", got)
+	}
+}
+
+func TestSupervisor_noRecoveryDuringInitialDiscovery(t *testing.T) {
+	rt := enginetest.New(enginetest.Service("vpn", "healthy"), enginetest.Service("torrent", ""))
+	rt.OnStart = func(name string) { rt.SetHealth(name, "healthy") }
+	s, fake := startSupervisor(t, rt, false, Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rt.Kill("vpn", 137)
+	s.handleEvent(ctx, engine.HealthEvent{ContainerID: "vpn-id", ContainerName: "vpn", Status: engine.EventDie})
+	if got := rt.Calls(); len(got) != 0 {
+		t.Errorf("calls during initial discovery = %v, want none", got)
+	}
+
+	// Once the phase ends, startup reconciliation recovers the parent that died meanwhile.
+	if err := fake.WaitTimers(ctx, 2); err != nil { // initial discovery wait and first poll
+		t.Fatal(err)
+	}
+	fake.Advance(time.Hour)
+	if err := rt.WaitCall(ctx, "restart torrent"); err != nil {
+		t.Fatalf("torrent not restarted: %v (calls %v)", err, rt.Calls())
+	}
+	if got := rt.Calls(); !slices.Equal(got, []string{"restart vpn", "restart torrent"}) {
+		t.Errorf("calls = %v, want vpn then torrent once", got)
+	}
+}
+
+func TestSupervisor_ignoresContainersThatAreNotParents(t *testing.T) {
+	rt := enginetest.New(enginetest.Service("vpn", "healthy"), enginetest.Service("torrent", ""), enginetest.Service("web", "healthy"))
+	s, _ := startSupervisor(t, rt, true, Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rt.SetHealth("web", "unhealthy")
+	rt.Kill("torrent", 1)
+	s.handleEvent(ctx, engine.HealthEvent{ContainerID: "web-id", ContainerName: "web", Status: engine.EventUnhealthy})
+	s.handleEvent(ctx, engine.HealthEvent{ContainerID: "torrent-id", ContainerName: "torrent", Status: engine.EventDie})
+	if got := rt.Calls(); len(got) != 0 {
+		t.Errorf("calls = %v, want none", got)
+	}
+}
+
+func TestSupervisor_recoveryCooldown(t *testing.T) {
+	rt := enginetest.New(enginetest.Service("vpn", "healthy"), enginetest.Service("torrent", ""))
+	rt.OnStart = func(name string) { rt.SetHealth(name, "healthy") }
+	s, fake := startSupervisor(t, rt, true, Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	unhealthy := engine.HealthEvent{ContainerID: "vpn-id", ContainerName: "vpn", Status: engine.EventUnhealthy}
+
+	s.handleEvent(ctx, unhealthy)
+	if got := rt.Calls(); !slices.Equal(got, []string{"restart vpn", "restart torrent"}) {
+		t.Fatalf("calls = %v, want vpn then torrent", got)
+	}
+	// Within RECOVERY_COOLDOWN (default 2m) the parent is not recovered again.
+	fake.Advance(2*time.Minute - time.Second)
+	s.handleEvent(ctx, unhealthy)
+	if got := len(rt.Calls()); got != 2 {
+		t.Fatalf("recovered again within cooldown: %v", rt.Calls())
+	}
+	fake.Advance(time.Second)
+	s.handleEvent(ctx, unhealthy)
+	if got := rt.Calls(); !slices.Equal(got[2:], []string{"restart vpn", "restart torrent"}) {
+		t.Errorf("calls = %v, want vpn restarted again after the cooldown", got)
+	}
+}
+
+// notifier collects the entries it is told about.
+type notifier struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (n *notifier) Notify(e Entry) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.entries = append(n.entries, e)
+}
+
+func TestSupervisor_notifierAndState(t *testing.T) {
+	rt := enginetest.New(enginetest.Service("vpn", "healthy"), enginetest.Service("torrent", ""))
+	rt.OnStart = func(name string) { rt.SetHealth(name, "healthy") }
+	if s := New(Options{Runtime: rt}); s.Recovering() || s.Graph() != nil || s.Policy().PollInterval != time.Minute {
+		t.Fatalf("before Run: Recovering = %v, Graph = %v, Policy = %+v", s.Recovering(), s.Graph(), s.Policy())
+	}
+	n := &notifier{}
+	s, _ := startSupervisor(t, rt, true, Options{Notifier: n})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !s.Recovering() || !s.Graph().IsParent("vpn") {
+		t.Fatalf("Recovering = %v, Graph = %+v", s.Recovering(), s.Graph())
+	}
+
+	rt.Kill("vpn", 1)
+	s.Reconcile(ctx, "takeover")
+	if got := rt.Calls(); !slices.Equal(got, []string{"restart vpn", "restart torrent"}) {
+		t.Fatalf("calls = %v, want vpn then torrent", got)
+	}
+	if got := s.InFlight(); len(got) != 0 {
+		t.Errorf("InFlight = %v after the recovery", got)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.entries) == 0 {
+		t.Fatal("notifier told nothing")
+	}
+	first, last := n.entries[0], n.entries[len(n.entries)-1]
+	if first.Kind != journal.KindAttempt || first.Parent != "vpn" || first.Trigger != "takeover" {
+		t.Errorf("first entry = %+v, want the takeover attempt for vpn", first)
+	}
+	if last.Kind != journal.KindOutcome || last.Outcome != journal.OutcomeSuccess {
+		t.Errorf("last entry = %+v, want a successful outcome", last)
+	}
+}
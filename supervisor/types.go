@@ -0,0 +1,53 @@
+package supervisor
+
+import (
+	"context"
+
+	"watch-dog/internal/clock"
+	"watch-dog/internal/discovery"
+	"watch-dog/internal/docker"
+	"watch-dog/internal/engine"
+	"watch-dog/internal/journal"
+	"watch-dog/internal/podman"
+	"watch-dog/internal/recovery"
+)
+
+// The types of the Supervisor's API are defined in internal packages; these aliases let programs
+// embedding a Supervisor name them, e.g. to implement a Runtime, Source or Notifier of their own.
+type (
+	// Runtime is a container runtime backend (see NewRuntime).
+	Runtime = engine.Runtime
+	// HealthEvent is a container event delivered by a Runtime.
+	HealthEvent = engine.HealthEvent
+	// ContainerInfo is a container listed by a Runtime.
+	ContainerInfo = engine.ContainerInfo
+	// ContainerState is a container's state as inspected by a Runtime.
+	ContainerState = engine.ContainerState
+	// Graph is a discovered dependency graph, keyed by container name.
+	Graph = discovery.Graph
+	// Clock is the source of time and timers.
+	Clock = clock.Clock
+	// RecoveryPolicy configures recovery sequences.
+	RecoveryPolicy = recovery.Policy
+	// Recorder persists recovery journal entries.
+	Recorder = recovery.Recorder
+	// Entry is a recovery journal entry.
+	Entry = journal.Entry
+	// JournalState is the state of earlier runs restored from the journal (see Supervisor.Restore).
+	JournalState = journal.State
+	// Endpoint is the address of a container host (see NewRuntime).
+	Endpoint = docker.Endpoint
+)
+
+// NewRuntime creates the client of the runtime backend kind ("docker" or "podman") for e. An
+// empty e.Host means the backend's default: DOCKER_HOST for Docker, see podman.DefaultHost.
+func NewRuntime(ctx context.Context, kind string, e Endpoint) (Runtime, error) {
+	switch {
+	case kind == "podman":
+		return podman.NewClient(ctx, e)
+	case e.Host == "":
+		return docker.NewClient(ctx)
+	default:
+		return docker.NewEndpointClient(ctx, e)
+	}
+}